@@ -0,0 +1,99 @@
+package ygo
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+// The user identities of clients are kept in the document itself, in a
+// map under a reserved name from client IDs to users. Every client only
+// writes its own key, so mappings set concurrently never conflict.
+const usersName = "ygo:users"
+
+// BlameRun is a run of visible text inserted by a single client
+type BlameRun struct {
+	Text   string
+	Client int64
+	// User is the user the client belongs to according to
+	// the PermanentUserData, empty if it's unknown
+	User string
+}
+
+// Blame returns the default text as runs of text by the client that
+// inserted them, see YText.Blame
+func (yd *YDoc) Blame() []BlameRun {
+	return yd.GetText(DefaultText).Blame()
+}
+
+// Blame returns the visible text as runs of text by the client that
+// inserted them, in order. Neighboring text by the same client is a
+// single run, embeds are left out.
+func (t *YText) Blame() []BlameRun {
+	users := t.doc.PermanentUserData()
+
+	var runs []BlameRun
+	for blk := t.text.Start; blk != nil; blk = blk.Right {
+		c, ok := blk.Content.(*block.ContentString)
+		if !ok || blk.IsDeleted {
+			continue
+		}
+
+		if n := len(runs); n > 0 && runs[n-1].Client == blk.ID.Client {
+			runs[n-1].Text += c.Str
+			continue
+		}
+		user, _ := users.User(blk.ID.Client)
+		runs = append(runs, BlameRun{Text: c.Str, Client: blk.ID.Client, User: user})
+	}
+	return runs
+}
+
+// PermanentUserData maps client IDs to user identities. Clients get a new
+// random ID every session, the mapping attributes the text of all of them
+// to the same user. It's synced along with the document.
+type PermanentUserData struct {
+	m *YMap
+}
+
+// PermanentUserData returns the user identities stored in the document
+func (yd *YDoc) PermanentUserData() *PermanentUserData {
+	return &PermanentUserData{m: yd.GetMap(usersName)}
+}
+
+// SetUser maps the client of this document to `user`, it's
+// usually called once a session when the user is known
+func (p *PermanentUserData) SetUser(user string) error {
+	return p.SetUserMapping(p.m.doc.Client(), user)
+}
+
+// SetUserMapping maps `client` to `user`
+func (p *PermanentUserData) SetUserMapping(client int64, user string) error {
+	return p.m.Set(strconv.FormatInt(client, 10), user)
+}
+
+// User returns the user `client` belongs to and whether it's known
+func (p *PermanentUserData) User(client int64) (string, bool) {
+	v, ok := p.m.Get(strconv.FormatInt(client, 10))
+	if !ok {
+		return "", false
+	}
+	user, ok := v.(string)
+	return user, ok
+}
+
+// Clients returns the clients that belong to `user`
+func (p *PermanentUserData) Clients(user string) []int64 {
+	var clients []int64
+	for key, v := range p.m.Entries() {
+		if v != user {
+			continue
+		}
+		if client, err := strconv.ParseInt(key, 10, 64); err == nil {
+			clients = append(clients, client)
+		}
+	}
+	slices.Sort(clients)
+	return clients
+}
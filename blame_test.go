@@ -0,0 +1,46 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlame tests attributing text to clients and the users behind them
+func TestBlame(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+	require.NoError(t, docA.PermanentUserData().SetUser("alice"))
+
+	require.NoError(t, docA.InsertText(0, "Hello World"))
+	syncV1(t, docA, docB)
+	require.NoError(t, docB.InsertText(6, "big "))
+	require.NoError(t, docB.DeleteText(0, 1))
+	syncV1(t, docA, docB)
+
+	a, b := docA.Client(), docB.Client()
+	assert.Equal(t, []ygo.BlameRun{
+		{Text: "ello ", Client: a, User: "alice"},
+		{Text: "big ", Client: b},
+		{Text: "World", Client: a, User: "alice"},
+	}, docB.Blame())
+
+	// alice reconnects with a new client ID, the mapping is synced
+	docC := ygo.NewYDoc()
+	syncV1(t, docB, docC)
+	require.NoError(t, docC.PermanentUserData().SetUser("alice"))
+	require.NoError(t, docC.InsertText(0, "H"))
+	syncV1(t, docB, docC)
+
+	blame := docB.Blame()
+	require.Len(t, blame, 4)
+	assert.Equal(t, ygo.BlameRun{Text: "H", Client: docC.Client(), User: "alice"}, blame[0])
+
+	users := docB.PermanentUserData()
+	user, ok := users.User(b)
+	assert.False(t, ok)
+	assert.Empty(t, user)
+	assert.ElementsMatch(t, []int64{a, docC.Client()}, users.Clients("alice"))
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+func inspect(args []string, stdout, stderr io.Writer) error {
+	fs := flags("inspect", stderr)
+	format := fs.String("format", "", "format of the update, detected if empty")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	in, err := readInput(fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	u := in.update
+
+	clients := slices.Sorted(maps.Keys(u.Updates.Updates))
+	blocks := 0
+	for _, client := range clients {
+		blocks += len(u.Updates.Updates[client])
+	}
+	fmt.Fprintf(stdout, "%s update, %d bytes, %d clients, %d blocks\n", in.format, len(in.data), len(clients), blocks)
+
+	for _, client := range clients {
+		bs := u.Updates.Updates[client]
+		if len(bs) == 0 {
+			continue
+		}
+		last := bs[len(bs)-1]
+		fmt.Fprintf(stdout, "\nclient %d, clocks %d-%d\n", client, bs[0].ID.Clock, last.ID.Clock+int64(last.Len()))
+
+		clock := bs[0].ID.Clock
+		for _, blk := range bs {
+			if blk.ID.Clock > clock {
+				fmt.Fprintf(stdout, "  %d:%d  missing %d\n", client, clock, blk.ID.Clock-clock)
+			}
+			fmt.Fprintf(stdout, "  %s\n", describe(blk))
+			clock = blk.ID.Clock + int64(blk.Len())
+		}
+	}
+
+	fmt.Fprintln(stdout, "\ndeletes")
+	if len(u.Deletes.ClientDeletes) == 0 {
+		fmt.Fprintln(stdout, "  none")
+	}
+	for _, cd := range u.Deletes.ClientDeletes {
+		ranges := make([]string, 0, len(cd.DeletedRanges))
+		for _, r := range block.MergeDeleteRanges(cd.DeletedRanges) {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", r.StartClock, r.StartClock+r.DeleteLength))
+		}
+		fmt.Fprintf(stdout, "  client %d: %s\n", cd.Client, strings.Join(ranges, " "))
+	}
+	return nil
+}
+
+// describe returns a line describing the block
+func describe(blk *block.Block) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d  len %d  %s", blk.ID.Client, blk.ID.Clock, blk.Len(), describeContent(blk))
+	if blk.Parent.Lost {
+		return b.String()
+	}
+
+	if blk.LeftOrigin != (block.ID{}) {
+		fmt.Fprintf(&b, "  origin %d:%d", blk.LeftOrigin.Client, blk.LeftOrigin.Clock)
+	}
+	if blk.RightOrigin != (block.ID{}) {
+		fmt.Fprintf(&b, "  right %d:%d", blk.RightOrigin.Client, blk.RightOrigin.Clock)
+	}
+	switch {
+	case blk.Parent.ID != nil:
+		fmt.Fprintf(&b, "  parent %d:%d", blk.Parent.ID.Client, blk.Parent.ID.Clock)
+	case blk.Parent.Root != "":
+		fmt.Fprintf(&b, "  parent %q", blk.Parent.Root)
+	}
+	if blk.ParentSub != "" {
+		fmt.Fprintf(&b, "  key %q", blk.ParentSub)
+	}
+	if blk.IsDeleted {
+		b.WriteString("  deleted")
+	}
+	return b.String()
+}
+
+func describeContent(blk *block.Block) string {
+	if blk.Parent.Lost {
+		return "gc"
+	}
+	return block.Describe(blk.Content)
+}
+
+func merge(args []string, stdout, stderr io.Writer) error {
+	fs := flags("merge", stderr)
+	format := fs.String("format", "", "format of the updates, detected if empty")
+	to := fs.String("to", "", "format to write, the one of the first update if empty")
+	out := fs.String("o", "", "file to write the update to")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	var updates []*block.Updates
+	for _, path := range fs.Args() {
+		in, err := readInput(path, *format)
+		if err != nil {
+			return err
+		}
+		if *to == "" {
+			*to = in.format
+		}
+		updates = append(updates, in.update)
+	}
+
+	data, err := encode(block.MergeUpdates(updates...), *to)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, data, stdout)
+}
+
+func diff(args []string, stdout, stderr io.Writer) error {
+	fs := flags("diff", stderr)
+	format := fs.String("format", "", "format of the update, detected if empty")
+	to := fs.String("to", "", "format to write, the one of the update if empty")
+	out := fs.String("o", "", "file to write the update to")
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	in, err := readInput(fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	sv, err := readStateVector(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if *to == "" {
+		*to = in.format
+	}
+
+	data, err := encode(block.DiffUpdate(in.update, sv), *to)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, data, stdout)
+}
+
+func convert(args []string, stdout, stderr io.Writer) error {
+	fs := flags("convert", stderr)
+	format := fs.String("format", "", "format of the update, detected if empty")
+	to := fs.String("to", "", "format to write: json, v1 or v2")
+	out := fs.String("o", "", "file to write the update to")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	if *to == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	in, err := readInput(fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	data, err := encode(in.update, *to)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, data, stdout)
+}
+
+func text(args []string, stdout, stderr io.Writer) error {
+	fs := flags("text", stderr)
+	format := fs.String("format", "", "format of the updates, detected if empty")
+	name := fs.String("name", ygo.DefaultText, "name of the root type to print")
+	kind := fs.String("type", "text", "kind of the root type: text, map, array or xml")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	doc := ygo.NewYDoc(ygo.WithGC(false))
+	for _, path := range fs.Args() {
+		in, err := readInput(path, *format)
+		if err != nil {
+			return err
+		}
+		// the update is applied in the format it was read
+		switch in.format {
+		case formatJSON:
+			err = doc.ApplyUpdate(in.data)
+		case formatV1:
+			err = doc.ApplyUpdateV1(in.data)
+		case formatV2:
+			err = doc.ApplyUpdateV2(in.data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	pending := 0
+	for _, u := range doc.GetPendingUpdates() {
+		for _, bs := range u.Updates {
+			pending += len(bs)
+		}
+	}
+	if pending > 0 {
+		fmt.Fprintf(stderr, "ygo: %d blocks depend on blocks none of the updates holds, they are left out\n", pending)
+	}
+
+	switch *kind {
+	case "text":
+		fmt.Fprintln(stdout, doc.GetText(*name).Content())
+	case "xml":
+		fmt.Fprintln(stdout, doc.GetXmlFragment(*name).ToString())
+	case "map", "array":
+		var v any = doc.GetMap(*name)
+		if *kind == "array" {
+			v = doc.GetArray(*name)
+		}
+		data, err := json.MarshalIndent(render(v), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(data))
+	default:
+		return fmt.Errorf("unknown type %q", *kind)
+	}
+	return nil
+}
+
+// render turns shared types into plain values that can be printed as JSON
+func render(v any) any {
+	switch v := v.(type) {
+	case *ygo.YText:
+		return v.Content()
+	case *ygo.YXmlText:
+		return v.Content()
+	case *ygo.YXmlFragment:
+		return v.ToString()
+	case *ygo.YXmlElement:
+		return v.ToString()
+	case *ygo.YMap:
+		m := make(map[string]any)
+		for key, value := range v.Entries() {
+			m[key] = render(value)
+		}
+		return m
+	case *ygo.YArray:
+		values := v.ToSlice()
+		for i, value := range values {
+			values[i] = render(value)
+		}
+		return values
+	case *ygo.YDoc:
+		return map[string]any{"guid": v.GUID()}
+	}
+	return v
+}
+
+func stateVector(args []string, stdout, stderr io.Writer) error {
+	fs := flags("sv", stderr)
+	format := fs.String("format", "", "format of the update, detected if empty")
+	out := fs.String("o", "", "file to write the state vector to, encoded like yjs does")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	in, err := readInput(fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	sv := block.StateVector(in.update)
+
+	if *out != "" {
+		return writeOutput(*out, block.EncodeStateVectorV1(sv), stdout)
+	}
+	for _, client := range slices.Sorted(maps.Keys(sv)) {
+		fmt.Fprintf(stdout, "%d: %d\n", client, sv[client])
+	}
+	return nil
+}
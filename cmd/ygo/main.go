@@ -0,0 +1,107 @@
+// Command ygo inspects, merges and converts document updates in the
+// JSON format of EncodeStateAsUpdate and the binary v1 and v2 formats
+// of yjs. The format of every input is detected, -format overrides it.
+//
+// Usage:
+//
+//	ygo inspect FILE             print the blocks and delete ranges of an update
+//	ygo merge [-o OUT] FILE...   combine updates into one
+//	ygo diff [-o OUT] FILE SV    print what a replica with the state vector SV is missing
+//	ygo convert -to F FILE       convert an update to json, v1 or v2
+//	ygo text [-name N] FILE...   apply updates to an empty document and print a type
+//	ygo sv [-o OUT] FILE         print the state vector of an update
+//
+// A FILE of - reads standard input. Updates are written to standard
+// output unless -o is given.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// commands are the subcommands, each parses its own flags
+var commands = map[string]func(args []string, stdout, stderr io.Writer) error{
+	"inspect": inspect,
+	"merge":   merge,
+	"diff":    diff,
+	"convert": convert,
+	"text":    text,
+	"sv":      stateVector,
+}
+
+var usages = map[string]string{
+	"inspect": "inspect [-format F] FILE",
+	"merge":   "merge [-format F] [-to F] [-o OUT] FILE...",
+	"diff":    "diff [-format F] [-to F] [-o OUT] FILE SV",
+	"convert": "convert [-format F] -to F [-o OUT] FILE",
+	"text":    "text [-format F] [-name N] [-type T] FILE...",
+	"sv":      "sv [-format F] [-o OUT] FILE",
+}
+
+// order the commands are listed in by the usage
+var names = []string{"inspect", "merge", "diff", "convert", "text", "sv"}
+
+// errUsage is returned for wrong arguments, the usage was printed already
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "ygo:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return errUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "ygo: unknown command %q\n", args[0])
+		printUsage(stderr)
+		return errUsage
+	}
+	return cmd(args[1:], stdout, stderr)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	for _, name := range names {
+		fmt.Fprintln(w, "  ygo", usages[name])
+	}
+	fmt.Fprintln(w, "formats: json, v1, v2")
+}
+
+// flags creates the flag set of a command, it prints errors and its usage to stderr
+func flags(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ygo", usages[name])
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags and checks the number of remaining arguments,
+// most < 0 allows any number
+func parse(fs *flag.FlagSet, args []string, least, most int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < least || (most >= 0 && fs.NArg() > most) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
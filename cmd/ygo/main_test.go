@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ygoRun runs the command and returns what it printed
+func ygoRun(t *testing.T, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run(args, &stdout, &stderr), stderr.String())
+	return stdout.String()
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.InsertText(0, "hello"))
+	first, err := doc.EncodeStateAsUpdateV1(nil)
+	require.NoError(t, err)
+	sv := doc.EncodeStateVectorV1()
+
+	require.NoError(t, doc.InsertText(5, " world"))
+	require.NoError(t, doc.DeleteText(0, 1))
+	rest, err := doc.EncodeStateAsUpdateV1(sv)
+	require.NoError(t, err)
+
+	firstPath := writeFile(t, dir, "first.bin", first)
+	restPath := writeFile(t, dir, "rest.bin", rest)
+
+	out := ygoRun(t, "inspect", restPath)
+	assert.Contains(t, out, "v1 update")
+	assert.Contains(t, out, `string " world"`)
+	assert.Contains(t, out, "deletes\n  client")
+
+	// the rest alone misses the start of the text
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"text", restPath}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "left out")
+
+	merged := filepath.Join(dir, "merged.bin")
+	ygoRun(t, "merge", "-to", "v2", "-o", merged, restPath, firstPath)
+	assert.Equal(t, "ello world\n", ygoRun(t, "text", merged))
+	assert.Contains(t, ygoRun(t, "inspect", merged), "v2 update")
+
+	// converting back and forth keeps the update
+	json := filepath.Join(dir, "merged.json")
+	ygoRun(t, "convert", "-to", "json", "-o", json, merged)
+	assert.Equal(t, ygoRun(t, "convert", "-to", "v1", merged), ygoRun(t, "convert", "-to", "v1", json))
+
+	assert.Equal(t, ygoRun(t, "sv", merged), ygoRun(t, "sv", json))
+	svPath := filepath.Join(dir, "sv.bin")
+	ygoRun(t, "sv", "-o", svPath, firstPath)
+
+	diff := writeFile(t, dir, "diff.bin", []byte(ygoRun(t, "diff", merged, svPath)))
+	assert.Equal(t, "ello world\n", ygoRun(t, "text", firstPath, diff))
+}
+
+func TestUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.ErrorIs(t, run(nil, &stdout, &stderr), errUsage)
+	assert.ErrorIs(t, run([]string{"nope"}, &stdout, &stderr), errUsage)
+	assert.ErrorIs(t, run([]string{"convert", "update.bin"}, &stdout, &stderr), errUsage)
+	assert.Contains(t, stderr.String(), "ygo convert")
+
+	assert.Error(t, run([]string{"inspect", filepath.Join(t.TempDir(), "missing")}, &stdout, &stderr))
+}
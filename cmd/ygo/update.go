@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/decoder"
+)
+
+// the formats updates are read and written in
+const (
+	formatJSON = "json"
+	formatV1   = "v1"
+	formatV2   = "v2"
+)
+
+// input is a decoded update file
+type input struct {
+	path   string
+	format string
+	data   []byte
+	update *block.Updates
+}
+
+// readInput reads and decodes the update in `path`, its format is
+// detected unless `format` is given
+func readInput(path, format string) (*input, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	in := &input{path: path, format: format, data: data}
+	if in.format == "" {
+		in.format = detect(data)
+	}
+	if in.update, err = decode(data, in.format); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return in, nil
+}
+
+// detect guesses the format of an update. JSON updates are objects, v2
+// updates start with a zero feature flag, which is also how v1 updates
+// without blocks start, so v2 is only assumed if the update decodes as such.
+func detect(data []byte) string {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return formatJSON
+	}
+	if len(data) > 0 && data[0] == 0 {
+		if _, err := decoder.DecodeUpdateV2(data); err == nil {
+			return formatV2
+		}
+	}
+	return formatV1
+}
+
+func decode(data []byte, format string) (*block.Updates, error) {
+	switch format {
+	case formatJSON:
+		return decoder.DecodeUpdate(data)
+	case formatV1:
+		return decoder.DecodeUpdateV1(data)
+	case formatV2:
+		return decoder.DecodeUpdateV2(data)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func encode(u *block.Updates, format string) ([]byte, error) {
+	switch format {
+	case formatJSON:
+		return json.Marshal(u)
+	case formatV1:
+		return u.MarshalV1()
+	case formatV2:
+		return u.MarshalV2()
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// writeOutput writes data to the file `path`, to stdout if it's empty
+func writeOutput(path string, data []byte, stdout io.Writer) error {
+	if path == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// readStateVector reads a state vector, either encoded like yjs
+// does or as a JSON object from client IDs to clocks
+func readStateVector(path string) (map[int64]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var clocks map[string]int64
+	if err := json.Unmarshal(data, &clocks); err == nil {
+		sv := make(map[int64]int64, len(clocks))
+		for client, clock := range clocks {
+			id, err := strconv.ParseInt(client, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: client %q: %w", path, client, err)
+			}
+			sv[id] = clock
+		}
+		return sv, nil
+	}
+
+	sv, err := block.DecodeStateVectorV1(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: decode state vector: %w", path, err)
+	}
+	return sv, nil
+}
@@ -0,0 +1,259 @@
+// Package debug renders the internal state of a document as an HTML page
+// to investigate replicas that diverge: the linked list of blocks of every
+// shared type, the blocks of every client, the state vector, the delete
+// set, the pending queue and the markers. The page is a templ template,
+// run `templ generate` after changing page.templ.
+package debug
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// Option configures a handler
+type Option func(*handler)
+
+// WithLock makes the handler hold l while it reads the document, pass
+// the lock that guards the document against concurrent changes
+func WithLock(l sync.Locker) Option {
+	return func(h *handler) {
+		h.lock = l
+	}
+}
+
+type handler struct {
+	doc  *ygo.YDoc
+	lock sync.Locker
+}
+
+// NewHandler returns a handler serving the state of doc as an HTML page
+// on GET requests. Mount it wherever it's needed, it ignores the path.
+func NewHandler(doc *ygo.YDoc, opts ...Option) http.Handler {
+	h := &handler{doc: doc}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.lock != nil {
+		h.lock.Lock()
+	}
+	p := newPage(h.doc)
+	if h.lock != nil {
+		h.lock.Unlock()
+	}
+
+	var buf bytes.Buffer
+	if err := page(p).Render(r.Context(), &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// Render writes the page showing the state of doc to w
+func Render(ctx context.Context, w io.Writer, doc *ygo.YDoc) error {
+	return page(newPage(doc)).Render(ctx, w)
+}
+
+// pageData is what the page shows, everything is formatted already
+type pageData struct {
+	GUID   string
+	Client string
+	GC     string
+	Types  []typeView
+	// Clients are the blocks of every client, sorted by clock
+	Clients        []clientView
+	StateVector    []entry
+	DeleteSet      []entry
+	Pending        []blockView
+	PendingDeletes []entry
+}
+
+// typeView is the linked list of blocks of a shared type
+type typeView struct {
+	Name    string
+	Length  string
+	Blocks  []blockView
+	Markers []entry
+}
+
+type clientView struct {
+	Client string
+	Blocks []blockView
+}
+
+type blockView struct {
+	ID          string
+	Len         string
+	Content     string
+	Origin      string
+	RightOrigin string
+	Parent      string
+	// Split names the block this one was split from, the
+	// block to its left that ends right before it starts
+	Split string
+	// State is visible, deleted, moved or gc
+	State string
+}
+
+// entry is a row of two columns
+type entry struct {
+	Key   string
+	Value string
+}
+
+func newPage(doc *ygo.YDoc) *pageData {
+	s := doc.GetBlockStore()
+	p := &pageData{
+		GUID:   doc.GUID(),
+		Client: strconv.FormatInt(s.CurrentClientID, 10),
+		GC:     strconv.FormatBool(s.GC),
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.Types)) {
+		p.Types = append(p.Types, newTypeView(strconv.Quote(name), s.Types[name]))
+	}
+	nested := slices.SortedFunc(maps.Keys(s.Nested), compareIDs)
+	for _, id := range nested {
+		p.Types = append(p.Types, newTypeView("nested in "+formatID(id), s.Nested[id]))
+	}
+
+	for _, client := range slices.Sorted(maps.Keys(s.Blocks)) {
+		c := clientView{Client: strconv.FormatInt(client, 10)}
+		for _, blk := range s.Blocks[client] {
+			c.Blocks = append(c.Blocks, newBlockView(blk))
+		}
+		p.Clients = append(p.Clients, c)
+	}
+
+	for _, client := range slices.Sorted(maps.Keys(s.StateVector)) {
+		p.StateVector = append(p.StateVector, entry{strconv.FormatInt(client, 10), strconv.FormatInt(s.StateVector[client], 10)})
+	}
+	for _, client := range slices.Sorted(maps.Keys(s.DeleteSet)) {
+		p.DeleteSet = append(p.DeleteSet, entry{strconv.FormatInt(client, 10), formatRanges(s.DeleteSet[client])})
+	}
+
+	for _, u := range doc.GetPendingUpdates() {
+		for _, client := range slices.Sorted(maps.Keys(u.Updates)) {
+			for _, blk := range u.Updates[client] {
+				p.Pending = append(p.Pending, newBlockView(blk))
+			}
+		}
+	}
+	for _, d := range doc.GetPendingDeletes() {
+		for _, cd := range d.ClientDeletes {
+			p.PendingDeletes = append(p.PendingDeletes, entry{strconv.FormatInt(cd.Client, 10), formatRanges(cd.DeletedRanges)})
+		}
+	}
+	return p
+}
+
+func newTypeView(name string, t *blockstore.Type) typeView {
+	v := typeView{Name: name, Length: strconv.Itoa(t.Length)}
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		v.Blocks = append(v.Blocks, newBlockView(blk))
+	}
+	// the blocks of a map are only linked per key
+	for _, key := range slices.Sorted(maps.Keys(t.Map)) {
+		var chain []*block.Block
+		for blk := t.Map[key]; blk != nil; blk = blk.Left {
+			chain = append(chain, blk)
+		}
+		for _, blk := range slices.Backward(chain) {
+			v.Blocks = append(v.Blocks, newBlockView(blk))
+		}
+	}
+
+	if t.MarkerSystem != nil {
+		for _, m := range t.MarkerSystem.Markers {
+			id := "none"
+			if m.Block != nil {
+				id = formatID(m.Block.ID)
+			}
+			v.Markers = append(v.Markers, entry{strconv.FormatInt(m.Pos, 10), id})
+		}
+	}
+	return v
+}
+
+func newBlockView(blk *block.Block) blockView {
+	v := blockView{
+		ID:    formatID(blk.ID),
+		Len:   strconv.Itoa(blk.Len()),
+		State: "visible",
+	}
+
+	switch {
+	case blk.Parent.Lost:
+		v.Content = "gc"
+		v.State = "gc"
+		return v
+	case blk.IsDeleted:
+		v.State = "deleted"
+	case blk.Moved:
+		v.State = "moved"
+	}
+
+	v.Content = block.Describe(blk.Content)
+	if blk.LeftOrigin != (block.ID{}) {
+		v.Origin = formatID(blk.LeftOrigin)
+	}
+	if blk.RightOrigin != (block.ID{}) {
+		v.RightOrigin = formatID(blk.RightOrigin)
+	}
+
+	switch {
+	case blk.Parent.ID != nil:
+		v.Parent = formatID(*blk.Parent.ID)
+	case blk.Parent.Root != "":
+		v.Parent = strconv.Quote(blk.Parent.Root)
+	}
+	if blk.ParentSub != "" {
+		v.Parent += " key " + strconv.Quote(blk.ParentSub)
+	}
+
+	if left := blk.Left; left != nil && left.ID.Client == blk.ID.Client && left.ID.Clock+int64(left.Len()) == blk.ID.Clock {
+		v.Split = formatID(left.ID)
+	}
+	return v
+}
+
+func formatID(id block.ID) string {
+	return fmt.Sprintf("%d:%d", id.Client, id.Clock)
+}
+
+func compareIDs(a, b block.ID) int {
+	return cmp.Or(cmp.Compare(a.Client, b.Client), cmp.Compare(a.Clock, b.Clock))
+}
+
+// formatRanges formats deleted ranges as start-end pairs, the end is exclusive
+func formatRanges(ranges []block.DeleteRange) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, fmt.Sprintf("%d-%d", r.StartClock, r.StartClock+r.DeleteLength))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,73 @@
+package debug_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/amoghyermalkar123/ygo/debug"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	doc := ygo.NewYDoc(ygo.WithGC(false))
+	require.NoError(t, doc.InsertText(0, "hello"))
+	require.NoError(t, doc.DeleteText(1, 2))
+
+	var mu sync.Mutex
+	srv := httptest.NewServer(debug.NewHandler(doc, debug.WithLock(&mu)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	var body bytes.Buffer
+	_, err = body.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	page := body.String()
+
+	client := doc.GetBlockStore().CurrentClientID
+	// deleting "el" splits the block in three
+	for _, clock := range []int{0, 1, 3} {
+		assert.Contains(t, page, fmt.Sprintf("<td>%d:%d</td>", client, clock))
+	}
+	assert.Contains(t, page, `<tr data-state="deleted">`)
+	assert.Contains(t, page, "<td>string &#34;el&#34;</td>")
+	assert.Contains(t, page, fmt.Sprintf("<tr><td>%d</td><td>5</td></tr>", client))
+	assert.Contains(t, page, fmt.Sprintf("<tr><td>%d</td><td>1-3</td></tr>", client))
+}
+
+func TestHandler_Method(t *testing.T) {
+	rec := httptest.NewRecorder()
+	debug.NewHandler(ygo.NewYDoc()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
+
+func TestRender_Pending(t *testing.T) {
+	source := ygo.NewYDoc()
+	require.NoError(t, source.InsertText(0, "a"))
+	sv := source.EncodeStateVectorV1()
+	require.NoError(t, source.InsertText(1, "b"))
+	update, err := source.EncodeStateAsUpdateV1(sv)
+	require.NoError(t, err)
+
+	// the update misses the block it's inserted after
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.ApplyUpdateV1(update))
+
+	var buf bytes.Buffer
+	require.NoError(t, debug.Render(context.Background(), &buf, doc))
+	assert.Contains(t, buf.String(), `<h2>Pending blocks</h2><table>`)
+	assert.Contains(t, buf.String(), "<td>string &#34;b&#34;</td>")
+}
@@ -0,0 +1,115 @@
+package ygo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+// ErrInvalidDelta is returned for deltas that can't be applied to a text
+var ErrInvalidDelta = errors.New("invalid delta")
+
+// ApplyDelta applies a Quill-style delta to the text in one transaction.
+// Every op either inserts a string or an embed, retains or deletes the
+// given number of positions. Inserts are formatted with exactly their
+// attributes, retains with attributes format the retained text, nil
+// values remove an attribute. The delta is checked before anything is
+// changed, a delta reaching past the end of the text changes nothing.
+func (t *YText) ApplyDelta(delta []TextDelta) error {
+	ops, err := t.checkDelta(delta)
+	if err != nil {
+		return err
+	}
+
+	t.doc.Transact(func() {
+		// a single position walks the text, each op goes on where the last one ended
+		pos := t.findPosition(0)
+		for _, op := range ops {
+			switch {
+			case op.content != nil:
+				t.insertAt(pos, op.content, op.attrs)
+			case op.delete > 0:
+				t.deleteAt(pos, op.delete)
+			case op.attrs != nil:
+				t.formatAt(pos, op.retain, op.attrs)
+			default:
+				t.skip(pos, op.retain)
+			}
+		}
+	})
+	return nil
+}
+
+// deltaOp is a checked op of a delta
+type deltaOp struct {
+	content block.Content
+	retain  int64
+	delete  int64
+	attrs   map[string]any
+}
+
+// checkDelta validates the delta against the length of the text
+// and normalizes its inserts and attributes
+func (t *YText) checkDelta(delta []TextDelta) ([]deltaOp, error) {
+	ops := make([]deltaOp, 0, len(delta))
+	length := t.Length()
+	var index int64
+
+	for i, d := range delta {
+		attrs, err := normalizeAttributes(d.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: op %d: %w", ErrInvalidDelta, i, err)
+		}
+
+		set := 0
+		if d.Insert != nil {
+			set++
+		}
+		if d.Retain != 0 {
+			set++
+		}
+		if d.Delete != 0 {
+			set++
+		}
+		if set != 1 || d.Retain < 0 || d.Delete < 0 {
+			return nil, fmt.Errorf("%w: op %d needs exactly one positive insert, retain or delete", ErrInvalidDelta, i)
+		}
+
+		op := deltaOp{retain: d.Retain, delete: d.Delete, attrs: attrs}
+		switch insert := d.Insert.(type) {
+		case nil:
+		case string:
+			if insert == "" {
+				continue
+			}
+			c := &block.ContentString{Str: insert}
+			op.content = c
+			length += int64(c.Len())
+			index += int64(c.Len())
+		case map[string]any:
+			embed, err := normalizeValue(insert)
+			if err != nil {
+				return nil, fmt.Errorf("%w: op %d: %w", ErrInvalidDelta, i, err)
+			}
+			op.content = &block.ContentEmbed{Embed: embed}
+			length++
+			index++
+		default:
+			return nil, fmt.Errorf("%w: op %d inserts a %T, not a string or an embed", ErrInvalidDelta, i, insert)
+		}
+		if op.content != nil && op.attrs == nil {
+			// inserts without attributes aren't formatted at all
+			op.attrs = map[string]any{}
+		}
+
+		if index += op.retain + op.delete; index > length {
+			return nil, fmt.Errorf("%w: op %d reaches position %d past the end %d", ErrInvalidDelta, i, index, length)
+		}
+		length -= op.delete
+		index -= op.delete
+
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
@@ -0,0 +1,131 @@
+package ygo
+
+import (
+	"slices"
+
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// sharedType is implemented by every shared type handed out by a YDoc
+type sharedType interface {
+	// emit calls the observers of the type with an event describing
+	// what the transaction changed in it and returns the event,
+	// nil if nothing observable changed
+	emit(txn *blockstore.Transaction, keys map[string]struct{}) any
+}
+
+// DeepEvent is passed to deep observers for every change in the
+// observed type or any type nested in it
+type DeepEvent struct {
+	// Path leads from the observed type to the changed one, map keys
+	// are strings and array indexes int64. It's empty for changes of
+	// the observed type itself.
+	Path []any
+	// Event is the *YMapEvent, *YArrayEvent, *YTextEvent or *YXmlEvent
+	// of the changed type
+	Event any
+}
+
+// observers is a list of callbacks for events of type E
+type observers[E any] struct {
+	next    int
+	entries []observer[E]
+}
+
+type observer[E any] struct {
+	id int
+	fn func(E)
+}
+
+// add registers fn and returns a function removing it again
+func (o *observers[E]) add(fn func(E)) func() {
+	id := o.next
+	o.next++
+	o.entries = append(o.entries, observer[E]{id: id, fn: fn})
+
+	return func() {
+		o.entries = slices.DeleteFunc(o.entries, func(e observer[E]) bool {
+			return e.id == id
+		})
+	}
+}
+
+// call calls every observer, observers added or removed
+// by a callback only take effect for the next event
+func (o *observers[E]) call(e E) {
+	for _, entry := range slices.Clone(o.entries) {
+		entry.fn(e)
+	}
+}
+
+// emit hands every type changed by the transaction to its shared type in
+// the order they were changed in, then the events bubble up to the deep
+// observers of the types above
+func (yd *YDoc) emit(txn *blockstore.Transaction) {
+	deep := make(map[*blockstore.Type][]DeepEvent)
+	var observed []*blockstore.Type
+
+	for _, t := range txn.ChangedTypes {
+		keys := txn.Changed[t]
+		st, ok := yd.types[t]
+		if !ok && t.Item != nil {
+			st = yd.shared(t)
+		}
+		if st == nil {
+			continue
+		}
+
+		e := st.emit(txn, keys)
+		if e == nil {
+			continue
+		}
+
+		var path []any
+		for ancestor := t; ancestor != nil; ancestor = yd.blockStore.ParentType(ancestor) {
+			if yd.deepObservers[ancestor] != nil {
+				if _, ok := deep[ancestor]; !ok {
+					observed = append(observed, ancestor)
+				}
+				deep[ancestor] = append(deep[ancestor], DeepEvent{Path: slices.Clone(path), Event: e})
+			}
+			if ancestor.Item != nil {
+				path = append([]any{yd.pathSegment(ancestor)}, path...)
+			}
+		}
+	}
+
+	for _, t := range observed {
+		events := deep[t]
+		// like yjs, events closer to the observed type come first
+		slices.SortStableFunc(events, func(a, b DeepEvent) int {
+			return len(a.Path) - len(b.Path)
+		})
+		yd.deepObservers[t].call(events)
+	}
+}
+
+// observeDeep registers fn as deep observer of `t`
+func (yd *YDoc) observeDeep(t *blockstore.Type, fn func([]DeepEvent)) (unobserve func()) {
+	o, ok := yd.deepObservers[t]
+	if !ok {
+		o = &observers[[]DeepEvent]{}
+		yd.deepObservers[t] = o
+	}
+	return o.add(fn)
+}
+
+// pathSegment returns how the nested type `t` is
+// addressed in its parent: by a key or by an index
+func (yd *YDoc) pathSegment(t *blockstore.Type) any {
+	if t.Item.ParentSub != "" {
+		return t.Item.ParentSub
+	}
+
+	var index int64
+	for blk := yd.blockStore.ParentType(t).Start; blk != nil && blk != t.Item; blk = blk.Right {
+		if blk.Visible() {
+			index += int64(blk.Len())
+		}
+	}
+	return index
+}
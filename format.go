@@ -0,0 +1,284 @@
+package ygo
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+// Formatting works like in yjs: a format marker opens an attribute with a
+// value and everything to its right carries that value until another marker
+// for the same key changes it. A marker with a nil value closes the attribute.
+
+// textPosition is a position in a text along with the
+// formatting attributes in effect at that position
+type textPosition struct {
+	*block.BlockTextListPosition
+	attrs map[string]any
+}
+
+// forward moves the position over its right block
+func (p *textPosition) forward() {
+	if f, ok := p.Right.Content.(*block.ContentFormat); ok && !p.Right.IsDeleted {
+		updateAttributes(p.attrs, f)
+	}
+	p.Forward()
+}
+
+// findPosition walks the text from its start up to `index` so
+// the attributes in effect there are known
+func (t *YText) findPosition(index int64) *textPosition {
+	pos := &textPosition{
+		BlockTextListPosition: &block.BlockTextListPosition{Right: t.text.Start},
+		attrs:                 make(map[string]any),
+	}
+	t.skip(pos, index)
+	return pos
+}
+
+// skip moves the position `n` positions to the right
+func (t *YText) skip(pos *textPosition, n int64) {
+	for pos.Right != nil && n > 0 {
+		if pos.Right.Visible() {
+			if n < int64(pos.Right.Len()) {
+				t.doc.blockStore.GetItemCleanStart(block.ID{
+					Client: pos.Right.ID.Client,
+					Clock:  pos.Right.ID.Clock + n,
+				})
+			}
+			n -= int64(pos.Right.Len())
+		}
+		pos.forward()
+	}
+}
+
+// insert inserts content at `index` formatted with `attrs`,
+// nil attributes continue the formatting found at `index`
+func (t *YText) insert(index int64, content block.Content, attrs map[string]any) error {
+	// without any formatting around the markers find the position faster
+	if !t.text.HasFormatting && len(attrs) == 0 {
+		if str, ok := content.(*block.ContentString); ok {
+			return t.doc.blockStore.Insert(t.text, index, str.Str)
+		}
+		return t.doc.blockStore.InsertContent(t.text, index, content)
+	}
+
+	t.insertAt(t.findPosition(index), content, attrs)
+	return nil
+}
+
+// insertAt inserts content at the position and moves the position past it
+func (t *YText) insertAt(pos *textPosition, content block.Content, attrs map[string]any) {
+	if attrs == nil {
+		attrs = maps.Clone(pos.attrs)
+	}
+	// attributes in effect but not asked for are closed
+	for key := range pos.attrs {
+		if _, ok := attrs[key]; !ok {
+			attrs[key] = nil
+		}
+	}
+
+	t.minimizeAttributeChanges(pos, attrs)
+	negated := t.insertAttributes(pos, attrs)
+
+	t.doc.blockStore.InsertAt(t.text, pos.BlockTextListPosition, content)
+	pos.forward()
+
+	t.insertNegatedAttributes(pos, negated)
+}
+
+// format sets `attrs` on `length` positions starting at `index`
+func (t *YText) format(index, length int64, attrs map[string]any) {
+	t.formatAt(t.findPosition(index), length, attrs)
+}
+
+// formatAt sets `attrs` on `length` positions starting at the
+// position and moves the position past them
+func (t *YText) formatAt(pos *textPosition, length int64, attrs map[string]any) {
+	t.minimizeAttributeChanges(pos, attrs)
+	negated := t.insertAttributes(pos, attrs)
+
+	// markers inside the range setting one of the attributes are replaced
+	// by the new value, what they set is restored at the end of the range.
+	// Markers right after the range are looked at as well so no redundant
+	// markers are added there.
+loop:
+	for pos.Right != nil && (length > 0 || (len(negated) > 0 && (pos.Right.IsDeleted || isFormat(pos.Right)))) {
+		if !pos.Right.IsDeleted {
+			switch c := pos.Right.Content.(type) {
+			case *block.ContentFormat:
+				if attr, ok := attrs[c.Key]; ok {
+					if equalAttr(attr, c.Value) {
+						delete(negated, c.Key)
+					} else {
+						if length == 0 {
+							break loop
+						}
+						negated[c.Key] = c.Value
+					}
+					t.doc.blockStore.MarkDeleted(pos.Right)
+				}
+			default:
+				if pos.Right.Visible() {
+					if length < int64(pos.Right.Len()) {
+						t.doc.blockStore.GetItemCleanStart(block.ID{
+							Client: pos.Right.ID.Client,
+							Clock:  pos.Right.ID.Clock + length,
+						})
+					}
+					length -= int64(pos.Right.Len())
+				}
+			}
+		}
+		pos.forward()
+	}
+
+	t.insertNegatedAttributes(pos, negated)
+}
+
+// deleteAt deletes `length` positions starting at the position, the format
+// markers in between are left to cleanupFormatting
+func (t *YText) deleteAt(pos *textPosition, length int64) {
+	for pos.Right != nil && length > 0 {
+		if pos.Right.Visible() {
+			if length < int64(pos.Right.Len()) {
+				t.doc.blockStore.GetItemCleanStart(block.ID{
+					Client: pos.Right.ID.Client,
+					Clock:  pos.Right.ID.Clock + length,
+				})
+			}
+			length -= int64(pos.Right.Len())
+			t.doc.blockStore.MarkDeleted(pos.Right)
+		}
+		pos.forward()
+	}
+}
+
+// minimizeAttributeChanges skips markers at the position that
+// already set the attributes to the wanted values
+func (t *YText) minimizeAttributeChanges(pos *textPosition, attrs map[string]any) {
+	for pos.Right != nil {
+		if !pos.Right.IsDeleted {
+			f, ok := pos.Right.Content.(*block.ContentFormat)
+			if !ok || !equalAttr(attrs[f.Key], f.Value) {
+				return
+			}
+		}
+		pos.forward()
+	}
+}
+
+// insertAttributes inserts a marker for every attribute not yet in effect
+// at the position and returns the values they replaced
+func (t *YText) insertAttributes(pos *textPosition, attrs map[string]any) map[string]any {
+	negated := make(map[string]any)
+	for _, key := range slices.Sorted(maps.Keys(attrs)) {
+		current := pos.attrs[key]
+		if equalAttr(current, attrs[key]) {
+			continue
+		}
+
+		negated[key] = current
+		t.doc.blockStore.InsertAt(t.text, pos.BlockTextListPosition, &block.ContentFormat{Key: key, Value: attrs[key]})
+		pos.forward()
+	}
+	return negated
+}
+
+// insertNegatedAttributes restores the attributes replaced by insertAttributes,
+// markers at the position already restoring one are reused
+func (t *YText) insertNegatedAttributes(pos *textPosition, negated map[string]any) {
+	for pos.Right != nil {
+		if !pos.Right.IsDeleted {
+			f, ok := pos.Right.Content.(*block.ContentFormat)
+			if !ok {
+				break
+			}
+			value, ok := negated[f.Key]
+			if !ok || !equalAttr(value, f.Value) {
+				break
+			}
+			delete(negated, f.Key)
+		}
+		pos.forward()
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(negated)) {
+		t.doc.blockStore.InsertAt(t.text, pos.BlockTextListPosition, &block.ContentFormat{Key: key, Value: negated[key]})
+		pos.forward()
+	}
+}
+
+// cleanupFormatting deletes markers that don't change the formatting.
+// Concurrent formatting of the same text and deleting the text between
+// markers both leave such markers behind.
+func (t *YText) cleanupFormatting() {
+	var redundant []*block.Block
+
+	// gap holds the markers since the last visible block,
+	// `start` the attributes in effect before them
+	var gap []*block.Block
+	start := make(map[string]any)
+	current := make(map[string]any)
+
+	flush := func() {
+		last := make(map[string]*block.Block)
+		for _, blk := range gap {
+			last[blk.Content.(*block.ContentFormat).Key] = blk
+		}
+		for _, blk := range gap {
+			f := blk.Content.(*block.ContentFormat)
+			// only the last marker of a key in the gap matters
+			// and only if it actually changes the value
+			if last[f.Key] != blk || equalAttr(start[f.Key], f.Value) {
+				redundant = append(redundant, blk)
+			}
+		}
+		gap = gap[:0]
+	}
+
+	for blk := t.text.Start; blk != nil; blk = blk.Right {
+		if blk.IsDeleted {
+			continue
+		}
+		if f, ok := blk.Content.(*block.ContentFormat); ok {
+			gap = append(gap, blk)
+			updateAttributes(current, f)
+		} else if blk.Visible() {
+			flush()
+			start = maps.Clone(current)
+		}
+	}
+	flush()
+
+	if len(redundant) == 0 {
+		return
+	}
+	t.doc.Transact(func() {
+		for _, blk := range redundant {
+			t.doc.blockStore.MarkDeleted(blk)
+		}
+	})
+}
+
+// updateAttributes applies a format marker to the attributes in effect
+func updateAttributes(attrs map[string]any, f *block.ContentFormat) {
+	if f.Value == nil {
+		delete(attrs, f.Key)
+	} else {
+		attrs[f.Key] = f.Value
+	}
+}
+
+// equalAttr compares attribute values, missing attributes are nil
+func equalAttr(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func isFormat(blk *block.Block) bool {
+	_, ok := blk.Content.(*block.ContentFormat)
+	return ok
+}
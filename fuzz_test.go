@@ -0,0 +1,145 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxEdits bounds the number of edits FuzzEdits runs
+const maxEdits = 256
+
+// FuzzApplyUpdate applies arbitrary updates to an empty document
+func FuzzApplyUpdate(f *testing.F) {
+	f.Fuzz(func(t *testing.T, update []byte) {
+		doc := ygo.NewYDoc(ygo.WithGC(false))
+		if err := doc.ApplyUpdate(update); err != nil {
+			return
+		}
+		content := doc.Content()
+
+		// applying it again changes nothing
+		require.NoError(t, doc.ApplyUpdate(update))
+		assert.Equal(t, content, doc.Content())
+
+		checkRoundTrip(t, doc)
+	})
+}
+
+// FuzzApplyUpdateV1 applies arbitrary yjs v1 updates to an empty document
+func FuzzApplyUpdateV1(f *testing.F) {
+	f.Fuzz(func(t *testing.T, update []byte) {
+		doc := ygo.NewYDoc(ygo.WithGC(false))
+		if err := doc.ApplyUpdateV1(update); err != nil {
+			return
+		}
+		content := doc.Content()
+
+		require.NoError(t, doc.ApplyUpdateV1(update))
+		assert.Equal(t, content, doc.Content())
+
+		state, err := doc.EncodeStateAsUpdateV1(nil)
+		require.NoError(t, err)
+		fresh := ygo.NewYDoc(ygo.WithGC(false))
+		require.NoError(t, fresh.ApplyUpdateV1(state))
+		assert.Equal(t, content, fresh.Content())
+	})
+}
+
+// FuzzApplyUpdateV2 applies arbitrary yjs v2 updates to an empty document
+func FuzzApplyUpdateV2(f *testing.F) {
+	f.Fuzz(func(t *testing.T, update []byte) {
+		doc := ygo.NewYDoc(ygo.WithGC(false))
+		if err := doc.ApplyUpdateV2(update); err != nil {
+			return
+		}
+		content := doc.Content()
+
+		require.NoError(t, doc.ApplyUpdateV2(update))
+		assert.Equal(t, content, doc.Content())
+
+		state, err := doc.EncodeStateAsUpdateV2(nil)
+		require.NoError(t, err)
+		fresh := ygo.NewYDoc(ygo.WithGC(false))
+		require.NoError(t, fresh.ApplyUpdateV2(state))
+		assert.Equal(t, content, fresh.Content())
+	})
+}
+
+// FuzzEdits runs edits on two documents and exchanges their updates.
+// Every three bytes of the input are an edit: the first picks the
+// document, insert or delete and the letter inserted, the second is
+// the position and the third the number of characters.
+func FuzzEdits(f *testing.F) {
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		// long inputs only make runs slower, not more interesting
+		ops = ops[:min(len(ops), 3*maxEdits)]
+		docs := []*ygo.YDoc{
+			ygo.NewYDoc(ygo.WithClientID(1), ygo.WithGC(false)),
+			ygo.NewYDoc(ygo.WithClientID(2), ygo.WithGC(false)),
+		}
+		texts := make([]string, len(docs))
+
+		for ; len(ops) >= 3; ops = ops[3:] {
+			i := int(ops[0] & 1)
+			text := texts[i]
+			n := int(ops[2]%4) + 1
+
+			if ops[0]&2 == 0 {
+				pos := int(ops[1]) % (len(text) + 1)
+				s := string(rune('a' + ops[0]>>2%26))
+				for range n - 1 {
+					s += s[:1]
+				}
+				require.NoError(t, docs[i].InsertText(int64(pos), s))
+				texts[i] = text[:pos] + s + text[pos:]
+			} else if len(text) > 0 {
+				pos := int(ops[1]) % len(text)
+				n = min(n, len(text)-pos)
+				require.NoError(t, docs[i].DeleteText(int64(pos), int64(n)))
+				texts[i] = text[:pos] + text[pos+n:]
+			}
+			require.Equal(t, texts[i], docs[i].Content())
+		}
+
+		a, err := docs[0].EncodeStateAsUpdate()
+		require.NoError(t, err)
+		b, err := docs[1].EncodeStateAsUpdate()
+		require.NoError(t, err)
+
+		// the updates apply in either order
+		ab := ygo.NewYDoc(ygo.WithGC(false))
+		require.NoError(t, ab.ApplyUpdate(a))
+		require.NoError(t, ab.ApplyUpdate(b))
+		ba := ygo.NewYDoc(ygo.WithGC(false))
+		require.NoError(t, ba.ApplyUpdate(b))
+		require.NoError(t, ba.ApplyUpdate(a))
+		assert.Equal(t, ab.Content(), ba.Content())
+
+		// and again, which changes nothing
+		require.NoError(t, ab.ApplyUpdate(a))
+		require.NoError(t, ab.ApplyUpdate(b))
+		assert.Equal(t, ba.Content(), ab.Content())
+
+		// the documents that made them converge as well
+		require.NoError(t, docs[0].ApplyUpdate(b))
+		require.NoError(t, docs[1].ApplyUpdate(a))
+		assert.Equal(t, ab.Content(), docs[0].Content())
+		assert.Equal(t, ab.Content(), docs[1].Content())
+
+		checkRoundTrip(t, ab)
+	})
+}
+
+// checkRoundTrip checks that the state of doc makes an equal document
+func checkRoundTrip(t *testing.T, doc *ygo.YDoc) {
+	t.Helper()
+	state, err := doc.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	fresh := ygo.NewYDoc(ygo.WithGC(false))
+	require.NoError(t, fresh.ApplyUpdate(state))
+	assert.Equal(t, doc.Content(), fresh.Content())
+}
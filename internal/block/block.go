@@ -1,31 +1,142 @@
 package block
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type ID struct {
 	Clock  int64
 	Client int64
 }
 
+// Parent identifies the shared type a block belongs to,
+// the equivalent of the parent info of an item in yjs.
+type Parent struct {
+	// Root is the name of the root type in the document
+	Root string `json:"root,omitempty"`
+	// ID is the block holding the type when it is nested
+	// in another type, nil for root types
+	ID *ID `json:"id,omitempty"`
+	// Lost is set for blocks whose type is gone, yjs replicas send
+	// them once they garbage collected a deleted nested type
+	Lost bool `json:"lost,omitempty"`
+}
+
+// Equal reports whether both refer to the same type
+func (p Parent) Equal(o Parent) bool {
+	if (p.ID == nil) != (o.ID == nil) || (p.ID != nil && *p.ID != *o.ID) {
+		return false
+	}
+	return p.Root == o.Root && p.Lost == o.Lost
+}
+
 type Block struct {
 	ID          ID
-	Content     string
+	Content     Content
 	IsDeleted   bool
 	LeftOrigin  ID
 	RightOrigin ID
-	Left        *Block
-	Right       *Block
+	Parent      Parent
+	// ParentSub is the map key the block is stored under,
+	// empty for blocks that are part of a sequence
+	ParentSub string
+	Left      *Block
+	Right     *Block
+	// Moved is set while the elements of the block are shown at the
+	// position of a ContentMove instead, or for a ContentMove whose
+	// elements are shown elsewhere. It's derived from the moves of
+	// the type and never encoded.
+	Moved bool
 }
 
-// NewBlock creates a block with content and ID.
+// NewBlock creates a block with text content and ID.
 func NewBlock(id ID, content string) *Block {
 	return &Block{
 		ID:      id,
-		Content: content,
+		Content: &ContentString{Str: content},
 	}
 }
 
+// Len returns the number of clocks the block covers
+func (b *Block) Len() int {
+	return b.Content.Len()
+}
+
+// Visible reports whether the block takes up positions in its type
+func (b *Block) Visible() bool {
+	return !b.IsDeleted && !b.Moved && b.Content.Countable()
+}
+
+// MarkDeleted turns the block into a tombstone. The content is kept
+// so the block keeps covering its whole clock range, otherwise
+// origins pointing into it could not be resolved anymore.
 func (b *Block) MarkDeleted() {
 	b.IsDeleted = true
-	b.Content = ""
+}
+
+// blockJSON is the JSON layout of a block, the neighbor pointers are
+// left out and the content is tagged with its reference
+type blockJSON struct {
+	ID          ID
+	ContentRef  uint8
+	Content     json.RawMessage
+	IsDeleted   bool
+	LeftOrigin  ID
+	RightOrigin ID
+	Parent      Parent
+	ParentSub   string `json:",omitempty"`
+}
+
+func (b *Block) MarshalJSON() ([]byte, error) {
+	content, err := json.Marshal(b.Content)
+	if err != nil {
+		return nil, fmt.Errorf("encode content: %w", err)
+	}
+
+	return json.Marshal(blockJSON{
+		ID:          b.ID,
+		ContentRef:  b.Content.Ref(),
+		Content:     content,
+		IsDeleted:   b.IsDeleted,
+		LeftOrigin:  b.LeftOrigin,
+		RightOrigin: b.RightOrigin,
+		Parent:      b.Parent,
+		ParentSub:   b.ParentSub,
+	})
+}
+
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var raw blockJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	// blocks encoded before contents had types carry plain text
+	if raw.ContentRef == 0 {
+		raw.ContentRef = RefString
+	}
+
+	content, err := newContent(raw.ContentRef)
+	if err != nil {
+		return err
+	}
+	if len(raw.Content) > 0 {
+		if err := json.Unmarshal(raw.Content, content); err != nil {
+			return fmt.Errorf("decode content: %w", err)
+		}
+	}
+
+	*b = Block{
+		ID:          raw.ID,
+		Content:     content,
+		IsDeleted:   raw.IsDeleted,
+		LeftOrigin:  raw.LeftOrigin,
+		RightOrigin: raw.RightOrigin,
+		Parent:      raw.Parent,
+		ParentSub:   raw.ParentSub,
+	}
+	return nil
 }
 
 type BlockTextListPosition struct {
@@ -35,8 +146,8 @@ type BlockTextListPosition struct {
 }
 
 func (l *BlockTextListPosition) Forward() {
-	if !l.Right.IsDeleted {
-		l.Index += int64(len(l.Right.Content))
+	if l.Right.Visible() {
+		l.Index += int64(l.Right.Len())
 	}
 	l.Left = l.Right
 	l.Right = l.Right.Right
@@ -0,0 +1,528 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/amoghyermalkar123/ygo/internal/encoding"
+)
+
+// Content references, they match the numbers yjs uses on the wire
+const (
+	RefDeleted uint8 = 1
+	RefJSON    uint8 = 2
+	RefBinary  uint8 = 3
+	RefString  uint8 = 4
+	RefEmbed   uint8 = 5
+	RefFormat  uint8 = 6
+	RefType    uint8 = 7
+	RefAny     uint8 = 8
+	RefDoc     uint8 = 9
+	// RefMove is specific to ygo, released versions of yjs don't know it
+	RefMove uint8 = 11
+)
+
+// Content is the payload carried by a block
+type Content interface {
+	// Ref returns the content reference used to encode the content
+	Ref() uint8
+	// Len returns the number of clocks the content covers
+	Len() int
+	// Countable reports whether the content takes up positions
+	// in its type, formatting markers for example don't
+	Countable() bool
+	// Splice keeps the first `offset` clocks of the content
+	// and returns the rest as new content
+	Splice(offset int) Content
+	// Merge appends `right` to the content if both can be
+	// represented as one, it reports whether it did
+	Merge(right Content) bool
+	// Encode writes the content in the yjs v1 format
+	Encode(enc *encoding.Encoder) error
+}
+
+// DecodeContent reads content with the given reference written by Content.Encode
+func DecodeContent(ref uint8, dec *encoding.Decoder) (Content, error) {
+	switch ref {
+	case RefDeleted:
+		n, err := readLength(dec)
+		return &ContentDeleted{Length: int(n)}, err
+	case RefJSON:
+		// the legacy way yjs stored values, they are read into ContentAny
+		n, err := readCount(dec)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			str, err := dec.ReadVarString()
+			if err != nil {
+				return nil, err
+			}
+			var v any
+			if str != "undefined" {
+				if err := json.Unmarshal([]byte(str), &v); err != nil {
+					return nil, fmt.Errorf("decode json content: %w", err)
+				}
+			}
+			values = append(values, v)
+		}
+		return &ContentAny{Values: values}, nil
+	case RefBinary:
+		data, err := dec.ReadVarBytes()
+		return &ContentBinary{Data: data}, err
+	case RefString:
+		str, err := dec.ReadVarString()
+		return &ContentString{Str: str}, err
+	case RefEmbed:
+		embed, err := readJSON(dec)
+		return &ContentEmbed{Embed: embed}, err
+	case RefFormat:
+		key, err := dec.ReadVarString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := readJSON(dec)
+		return &ContentFormat{Key: key, Value: value}, err
+	case RefType:
+		typeRef, err := dec.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		c := &ContentType{TypeRef: uint8(typeRef)}
+		if c.hasName() {
+			c.Name, err = dec.ReadVarString()
+		}
+		return c, err
+	case RefAny:
+		n, err := dec.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(dec.Remaining()) {
+			return nil, encoding.ErrUnexpectedEOF
+		}
+		values := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			v, err := dec.ReadAny()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return &ContentAny{Values: values}, nil
+	case RefDoc:
+		guid, err := dec.ReadVarString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := dec.ReadAny()
+		if err != nil {
+			return nil, err
+		}
+		opts, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("decode doc content: options are a %T", v)
+		}
+		return &ContentDoc{GUID: guid, Opts: opts}, nil
+	case RefMove:
+		return decodeContentMove(dec)
+	default:
+		return nil, fmt.Errorf("unknown content reference %d", ref)
+	}
+}
+
+// newContent allocates empty content for the reference, it's
+// what the JSON encoding of a block is decoded into
+func newContent(ref uint8) (Content, error) {
+	switch ref {
+	case RefDeleted:
+		return &ContentDeleted{}, nil
+	case RefBinary:
+		return &ContentBinary{}, nil
+	case RefString:
+		return &ContentString{}, nil
+	case RefEmbed:
+		return &ContentEmbed{}, nil
+	case RefFormat:
+		return &ContentFormat{}, nil
+	case RefType:
+		return &ContentType{}, nil
+	case RefAny:
+		return &ContentAny{}, nil
+	case RefDoc:
+		return &ContentDoc{}, nil
+	case RefMove:
+		return &ContentMove{}, nil
+	default:
+		return nil, fmt.Errorf("unknown content reference %d", ref)
+	}
+}
+
+// SliceContent returns a copy of the content without its first
+// `offset` clocks, `c` itself is left untouched
+func SliceContent(c Content, offset int) Content {
+	switch c := c.(type) {
+	case *ContentString:
+		cp := *c
+		return cp.Splice(offset)
+	case *ContentDeleted:
+		cp := *c
+		return cp.Splice(offset)
+	case *ContentAny:
+		cp := *c
+		return cp.Splice(offset)
+	case *ContentMove:
+		cp := *c
+		return cp.Splice(offset)
+	default:
+		return c.Splice(offset)
+	}
+}
+
+// ContentString is a piece of text. Like in yjs its length
+// is counted in UTF-16 code units, not in bytes.
+type ContentString struct {
+	Str string
+}
+
+func (c *ContentString) Ref() uint8      { return RefString }
+func (c *ContentString) Countable() bool { return true }
+func (c *ContentString) String() string  { return c.Str }
+
+func (c *ContentString) Len() int {
+	n := 0
+	for _, r := range c.Str {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+func (c *ContentString) Splice(offset int) Content {
+	units := 0
+	for i, r := range c.Str {
+		if units == offset {
+			right := &ContentString{Str: c.Str[i:]}
+			c.Str = c.Str[:i]
+			return right
+		}
+
+		units += utf16.RuneLen(r)
+		if units > offset {
+			// cutting a surrogate pair in half would leave both halves
+			// invalid, replace them the same way yjs does
+			_, size := utf8.DecodeRuneInString(c.Str[i:])
+			right := &ContentString{Str: string(utf8.RuneError) + c.Str[i+size:]}
+			c.Str = c.Str[:i] + string(utf8.RuneError)
+			return right
+		}
+	}
+	return &ContentString{}
+}
+
+func (c *ContentString) Merge(right Content) bool {
+	r, ok := right.(*ContentString)
+	if ok {
+		c.Str += r.Str
+	}
+	return ok
+}
+
+func (c *ContentString) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarString(c.Str)
+	return nil
+}
+
+// MarshalJSON encodes the text as a plain JSON string, that's
+// how the content of blocks was encoded before it had a type
+func (c *ContentString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Str)
+}
+
+func (c *ContentString) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &c.Str)
+}
+
+// ContentDeleted is what remains of deleted content once it has
+// been garbage collected, it only remembers how many clocks it covered
+type ContentDeleted struct {
+	Length int
+}
+
+func (c *ContentDeleted) Ref() uint8      { return RefDeleted }
+func (c *ContentDeleted) Len() int        { return c.Length }
+func (c *ContentDeleted) Countable() bool { return false }
+
+func (c *ContentDeleted) Splice(offset int) Content {
+	right := &ContentDeleted{Length: c.Length - offset}
+	c.Length = offset
+	return right
+}
+
+func (c *ContentDeleted) Merge(right Content) bool {
+	r, ok := right.(*ContentDeleted)
+	if ok {
+		c.Length += r.Length
+	}
+	return ok
+}
+
+func (c *ContentDeleted) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarUint(uint64(c.Length))
+	return nil
+}
+
+// ContentAny holds JSON-encodable values, every value takes up a single position
+type ContentAny struct {
+	Values []any
+}
+
+func (c *ContentAny) Ref() uint8      { return RefAny }
+func (c *ContentAny) Len() int        { return len(c.Values) }
+func (c *ContentAny) Countable() bool { return true }
+
+func (c *ContentAny) Splice(offset int) Content {
+	right := &ContentAny{Values: c.Values[offset:]}
+	c.Values = c.Values[:offset:offset]
+	return right
+}
+
+func (c *ContentAny) Merge(right Content) bool {
+	r, ok := right.(*ContentAny)
+	if ok {
+		c.Values = append(c.Values, r.Values...)
+	}
+	return ok
+}
+
+func (c *ContentAny) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarUint(uint64(len(c.Values)))
+	for _, v := range c.Values {
+		if err := enc.WriteAny(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContentBinary is an opaque blob taking up a single position
+type ContentBinary struct {
+	Data []byte
+}
+
+func (c *ContentBinary) Ref() uint8                { return RefBinary }
+func (c *ContentBinary) Len() int                  { return 1 }
+func (c *ContentBinary) Countable() bool           { return true }
+func (c *ContentBinary) Splice(offset int) Content { panic("ContentBinary can't be spliced") }
+func (c *ContentBinary) Merge(right Content) bool  { return false }
+
+func (c *ContentBinary) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarBytes(c.Data)
+	return nil
+}
+
+// ContentEmbed is an object embedded in text, taking up a single position
+type ContentEmbed struct {
+	Embed any
+}
+
+func (c *ContentEmbed) Ref() uint8                { return RefEmbed }
+func (c *ContentEmbed) Len() int                  { return 1 }
+func (c *ContentEmbed) Countable() bool           { return true }
+func (c *ContentEmbed) Splice(offset int) Content { panic("ContentEmbed can't be spliced") }
+func (c *ContentEmbed) Merge(right Content) bool  { return false }
+
+func (c *ContentEmbed) Encode(enc *encoding.Encoder) error {
+	return writeJSON(enc, c.Embed)
+}
+
+// ContentFormat marks the start or end of a formatting attribute in text.
+// A nil value ends the attribute. It takes up a clock but no position.
+type ContentFormat struct {
+	Key   string
+	Value any
+}
+
+func (c *ContentFormat) Ref() uint8                { return RefFormat }
+func (c *ContentFormat) Len() int                  { return 1 }
+func (c *ContentFormat) Countable() bool           { return false }
+func (c *ContentFormat) Splice(offset int) Content { panic("ContentFormat can't be spliced") }
+func (c *ContentFormat) Merge(right Content) bool  { return false }
+
+func (c *ContentFormat) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarString(c.Key)
+	return writeJSON(enc, c.Value)
+}
+
+// Type references of nested shared types, they match the numbers of yjs
+const (
+	TypeArray uint8 = iota
+	TypeMap
+	TypeText
+	TypeXmlElement
+	TypeXmlFragment
+	TypeXmlHook
+	TypeXmlText
+)
+
+// ContentType holds a nested shared type, the blocks of the
+// nested type refer to the block carrying this content as parent
+type ContentType struct {
+	TypeRef uint8
+	// Name is the node name of xml elements and hooks
+	Name string `json:",omitempty"`
+}
+
+func (c *ContentType) Ref() uint8                { return RefType }
+func (c *ContentType) Len() int                  { return 1 }
+func (c *ContentType) Countable() bool           { return true }
+func (c *ContentType) Splice(offset int) Content { panic("ContentType can't be spliced") }
+func (c *ContentType) Merge(right Content) bool  { return false }
+
+func (c *ContentType) hasName() bool {
+	return c.TypeRef == TypeXmlElement || c.TypeRef == TypeXmlHook
+}
+
+func (c *ContentType) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarUint(uint64(c.TypeRef))
+	if c.hasName() {
+		enc.WriteVarString(c.Name)
+	}
+	return nil
+}
+
+// ContentDoc references a subdocument by its GUID, the content
+// of the subdocument itself is synced on its own
+type ContentDoc struct {
+	GUID string
+	// Opts are the options the subdocument is created with,
+	// like autoLoad and gc
+	Opts map[string]any `json:",omitempty"`
+}
+
+func (c *ContentDoc) Ref() uint8                { return RefDoc }
+func (c *ContentDoc) Len() int                  { return 1 }
+func (c *ContentDoc) Countable() bool           { return true }
+func (c *ContentDoc) Splice(offset int) Content { panic("ContentDoc can't be spliced") }
+func (c *ContentDoc) Merge(right Content) bool  { return false }
+
+func (c *ContentDoc) Encode(enc *encoding.Encoder) error {
+	enc.WriteVarString(c.GUID)
+	opts := c.Opts
+	if opts == nil {
+		opts = map[string]any{}
+	}
+	return enc.WriteAny(opts)
+}
+
+// ContentMove takes the place of array elements moved here, one clock
+// per element. Every element ends up at the move with the highest
+// (Priority, client, clock), the other moves and the element's original
+// position are skipped. Priority is a Lamport clock, a move made after
+// seeing another one always wins over it.
+type ContentMove struct {
+	// Targets are the IDs of the moved elements where they were inserted
+	Targets  []ID
+	Priority int64
+}
+
+func (c *ContentMove) Ref() uint8      { return RefMove }
+func (c *ContentMove) Len() int        { return len(c.Targets) }
+func (c *ContentMove) Countable() bool { return true }
+
+func (c *ContentMove) Splice(offset int) Content {
+	right := &ContentMove{Targets: c.Targets[offset:], Priority: c.Priority}
+	c.Targets = c.Targets[:offset:offset]
+	return right
+}
+
+func (c *ContentMove) Merge(right Content) bool {
+	r, ok := right.(*ContentMove)
+	if !ok || r.Priority != c.Priority {
+		return false
+	}
+	c.Targets = append(c.Targets, r.Targets...)
+	return true
+}
+
+// Encode writes the targets as runs of consecutive IDs
+func (c *ContentMove) Encode(enc *encoding.Encoder) error {
+	var runs [][2]int
+	for i, id := range c.Targets {
+		if n := len(runs); n > 0 {
+			start := c.Targets[runs[n-1][0]]
+			if id.Client == start.Client && id.Clock == start.Clock+int64(runs[n-1][1]) {
+				runs[n-1][1]++
+				continue
+			}
+		}
+		runs = append(runs, [2]int{i, 1})
+	}
+
+	enc.WriteVarUint(uint64(c.Priority))
+	enc.WriteVarUint(uint64(len(runs)))
+	for _, run := range runs {
+		writeID(enc, c.Targets[run[0]])
+		enc.WriteVarUint(uint64(run[1]))
+	}
+	return nil
+}
+
+func decodeContentMove(dec *encoding.Decoder) (Content, error) {
+	priority, err := readNumber(dec)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := readCount(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ContentMove{Priority: priority}
+	for i := 0; i < runs; i++ {
+		start, err := readID(dec)
+		if err != nil {
+			return nil, err
+		}
+		n, err := readLength(dec)
+		if err != nil {
+			return nil, err
+		}
+		if n > int64(dec.Remaining())+maxMoveRun {
+			return nil, fmt.Errorf("%w: move of %d elements", ErrInvalidUpdate, n)
+		}
+		for j := int64(0); j < n; j++ {
+			c.Targets = append(c.Targets, ID{Client: start.Client, Clock: start.Clock + j})
+		}
+	}
+	return c, nil
+}
+
+// maxMoveRun bounds the elements a single run of a move can
+// expand to beyond the size of the update it came in
+const maxMoveRun = 1 << 16
+
+// writeJSON writes v as a JSON string, the way the yjs v1 encoder writes json
+func writeJSON(enc *encoding.Encoder, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	enc.WriteVarString(string(data))
+	return nil
+}
+
+func readJSON(dec *encoding.Decoder) (any, error) {
+	str, err := dec.ReadVarString()
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(str), &v); err != nil {
+		return nil, fmt.Errorf("decode json content: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,90 @@
+package block
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo/internal/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentString_LenCountsUTF16(t *testing.T) {
+	assert.Equal(t, 5, (&ContentString{Str: "héllo"}).Len())
+	assert.Equal(t, 2, (&ContentString{Str: "😀"}).Len())
+}
+
+func TestContentString_Splice(t *testing.T) {
+	left := &ContentString{Str: "héllo"}
+	right := left.Splice(2)
+
+	assert.Equal(t, "hé", left.Str)
+	assert.Equal(t, "llo", right.(*ContentString).Str)
+}
+
+func TestContentString_SpliceSurrogatePair(t *testing.T) {
+	left := &ContentString{Str: "a😀b"}
+	right := left.Splice(2)
+
+	// both halves keep their length so clocks still add up
+	assert.Equal(t, "a�", left.Str)
+	assert.Equal(t, "�b", right.(*ContentString).Str)
+}
+
+func TestContent_Merge(t *testing.T) {
+	str := &ContentString{Str: "ab"}
+	assert.True(t, str.Merge(&ContentString{Str: "cd"}))
+	assert.Equal(t, "abcd", str.Str)
+	assert.False(t, str.Merge(&ContentAny{Values: []any{1.0}}))
+
+	deleted := &ContentDeleted{Length: 2}
+	assert.True(t, deleted.Merge(&ContentDeleted{Length: 3}))
+	assert.Equal(t, 5, deleted.Len())
+
+	assert.False(t, (&ContentEmbed{}).Merge(&ContentEmbed{}))
+}
+
+func TestContent_EncodeDecode(t *testing.T) {
+	contents := []Content{
+		&ContentDeleted{Length: 3},
+		&ContentBinary{Data: []byte{1, 2}},
+		&ContentString{Str: "hello"},
+		&ContentEmbed{Embed: map[string]any{"image": "a.png"}},
+		&ContentFormat{Key: "bold", Value: true},
+		&ContentType{TypeRef: TypeXmlElement, Name: "p"},
+		&ContentType{TypeRef: TypeMap},
+		&ContentAny{Values: []any{"a", 1.0, nil}},
+		&ContentDoc{GUID: "page-1", Opts: map[string]any{"autoLoad": true}},
+		&ContentMove{Targets: []ID{{Client: 1, Clock: 4}, {Client: 1, Clock: 5}, {Client: 2, Clock: 0}}, Priority: 3},
+	}
+
+	for _, c := range contents {
+		enc := encoding.NewEncoder()
+		require.NoError(t, c.Encode(enc))
+
+		got, err := DecodeContent(c.Ref(), encoding.NewDecoder(enc.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, c, got)
+	}
+}
+
+func TestBlock_JSONRoundTrip(t *testing.T) {
+	blk := &Block{
+		ID:        ID{Clock: 1, Client: 2},
+		Content:   &ContentAny{Values: []any{"x", 2.0}},
+		ParentSub: "key",
+	}
+
+	data, err := json.Marshal(blk)
+	require.NoError(t, err)
+
+	var got Block
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, *blk, got)
+}
+
+func TestBlock_UnmarshalUntypedContent(t *testing.T) {
+	var got Block
+	require.NoError(t, json.Unmarshal([]byte(`{"ID":{"Clock":0,"Client":1},"Content":"hi"}`), &got))
+	assert.Equal(t, &ContentString{Str: "hi"}, got.Content)
+}
@@ -0,0 +1,67 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// the names of nested types by their type reference
+var typeNames = map[uint8]string{
+	TypeArray:       "array",
+	TypeMap:         "map",
+	TypeText:        "text",
+	TypeXmlElement:  "xml element",
+	TypeXmlFragment: "xml fragment",
+	TypeXmlHook:     "xml hook",
+	TypeXmlText:     "xml text",
+}
+
+// Describe returns a short human readable description of the content
+// for debugging tools, long strings are cut short
+func Describe(content Content) string {
+	switch c := content.(type) {
+	case *ContentString:
+		return "string " + quote(c.Str)
+	case *ContentDeleted:
+		return "deleted content"
+	case *ContentAny:
+		return "any " + toJSON(c.Values)
+	case *ContentBinary:
+		return fmt.Sprintf("binary %d bytes", len(c.Data))
+	case *ContentEmbed:
+		return "embed " + toJSON(c.Embed)
+	case *ContentFormat:
+		return fmt.Sprintf("format %s=%s", c.Key, toJSON(c.Value))
+	case *ContentType:
+		name, ok := typeNames[c.TypeRef]
+		if !ok {
+			name = fmt.Sprintf("type %d", c.TypeRef)
+		}
+		if c.Name != "" {
+			name += " <" + c.Name + ">"
+		}
+		return name
+	case *ContentDoc:
+		return "doc " + quote(c.GUID)
+	case *ContentMove:
+		return fmt.Sprintf("move %d elements, priority %d", len(c.Targets), c.Priority)
+	}
+	return fmt.Sprintf("content %d", content.Ref())
+}
+
+// quote quotes s, long strings are cut short
+func quote(s string) string {
+	const limit = 40
+	if r := []rune(s); len(r) > limit {
+		return fmt.Sprintf("%q...", string(r[:limit]))
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+func toJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
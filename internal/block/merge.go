@@ -0,0 +1,131 @@
+package block
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// MergeUpdates combines updates into a single one holding the blocks and
+// deletes of all of them, without a document to apply them to. Blocks
+// contained in several updates are kept once. Unlike applying the updates
+// to a document, it works for updates that depend on blocks none of them
+// holds, the result depends on them just the same.
+func MergeUpdates(updates ...*Updates) *Updates {
+	blocks := make(map[int64][]*Block)
+	ranges := make(map[int64][]DeleteRange)
+	for _, u := range updates {
+		for client, bs := range u.Updates.Updates {
+			blocks[client] = append(blocks[client], bs...)
+		}
+		for _, cd := range u.Deletes.ClientDeletes {
+			ranges[cd.Client] = append(ranges[cd.Client], cd.DeletedRanges...)
+		}
+	}
+
+	merged := &Updates{Updates: Update{Updates: make(map[int64][]*Block, len(blocks))}}
+	for client, bs := range blocks {
+		// the longest of the blocks starting at the same clock covers the others
+		slices.SortStableFunc(bs, func(a, b *Block) int {
+			return cmp.Or(cmp.Compare(a.ID.Clock, b.ID.Clock), cmp.Compare(b.Len(), a.Len()))
+		})
+
+		var kept []*Block
+		var end int64
+		for _, blk := range bs {
+			blkEnd := blk.ID.Clock + int64(blk.Len())
+			if len(kept) > 0 && blkEnd <= end {
+				continue
+			}
+			if len(kept) > 0 && blk.ID.Clock < end {
+				blk = SliceBlock(blk, end)
+			}
+			kept = append(kept, blk)
+			end = blkEnd
+		}
+		merged.Updates.Updates[client] = kept
+	}
+
+	merged.Deletes = deleteUpdate(ranges)
+	return merged
+}
+
+// DiffUpdate returns the part of the update a replica with the state
+// vector `sv` is missing. The delete set is kept whole, the state vector
+// doesn't say which deletes the replica knows.
+func DiffUpdate(u *Updates, sv map[int64]int64) *Updates {
+	diff := &Updates{Updates: Update{Updates: make(map[int64][]*Block)}}
+	for client, bs := range u.Updates.Updates {
+		known := sv[client]
+
+		var missing []*Block
+		for _, blk := range bs {
+			if blk.ID.Clock+int64(blk.Len()) <= known {
+				continue
+			}
+			if blk.ID.Clock < known {
+				blk = SliceBlock(blk, known)
+			}
+			missing = append(missing, blk)
+		}
+		if len(missing) > 0 {
+			diff.Updates.Updates[client] = missing
+		}
+	}
+
+	ranges := make(map[int64][]DeleteRange)
+	for _, cd := range u.Deletes.ClientDeletes {
+		ranges[cd.Client] = append(ranges[cd.Client], cd.DeletedRanges...)
+	}
+	diff.Deletes = deleteUpdate(ranges)
+	return diff
+}
+
+// StateVector returns the state vector a replica has after applying
+// only the update: the clocks up to which the blocks of every client
+// follow each other without a gap from the start
+func StateVector(u *Updates) map[int64]int64 {
+	sv := make(map[int64]int64)
+	for client, bs := range u.Updates.Updates {
+		var clock int64
+		for _, blk := range bs {
+			if blk.ID.Clock > clock {
+				break
+			}
+			clock = max(clock, blk.ID.Clock+int64(blk.Len()))
+		}
+		if clock > 0 {
+			sv[client] = clock
+		}
+	}
+	return sv
+}
+
+// SliceBlock returns a copy of the block starting at `clock`, the part
+// before it is cut off. The copy is inserted right after the part cut off.
+func SliceBlock(b *Block, clock int64) *Block {
+	cp := *b
+	cp.Left, cp.Right = nil, nil
+	offset := clock - b.ID.Clock
+	cp.ID.Clock = clock
+	cp.Content = SliceContent(b.Content, int(offset))
+	if !b.Parent.Lost {
+		cp.LeftOrigin = ID{Client: b.ID.Client, Clock: clock - 1}
+	}
+	return &cp
+}
+
+// deleteUpdate turns the deleted ranges of every client into a
+// DeleteUpdate, merging the ranges of each client
+func deleteUpdate(ranges map[int64][]DeleteRange) DeleteUpdate {
+	d := DeleteUpdate{ClientDeletes: []ClientDeletes{}}
+	for _, client := range slices.Sorted(maps.Keys(ranges)) {
+		merged := MergeDeleteRanges(ranges[client])
+		if len(merged) == 0 {
+			continue
+		}
+		d.ClientDeletes = append(d.ClientDeletes, ClientDeletes{Client: client, DeletedRanges: merged})
+	}
+	d.NumClients = int64(len(d.ClientDeletes))
+	return d
+}
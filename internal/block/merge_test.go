@@ -0,0 +1,69 @@
+package block
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func textBlock(client, clock int64, str string) *Block {
+	blk := NewBlock(ID{Client: client, Clock: clock}, str)
+	if clock > 0 {
+		blk.LeftOrigin = ID{Client: client, Clock: clock - 1}
+	} else {
+		blk.Parent = Parent{Root: "text"}
+	}
+	return blk
+}
+
+func update(deletes []ClientDeletes, blocks ...*Block) *Updates {
+	u := &Updates{Updates: Update{Updates: make(map[int64][]*Block)}}
+	for _, blk := range blocks {
+		u.Updates.Updates[blk.ID.Client] = append(u.Updates.Updates[blk.ID.Client], blk)
+	}
+	u.Deletes = DeleteUpdate{NumClients: int64(len(deletes)), ClientDeletes: deletes}
+	return u
+}
+
+func TestMergeUpdates(t *testing.T) {
+	a := update([]ClientDeletes{{Client: 1, DeletedRanges: []DeleteRange{{StartClock: 0, DeleteLength: 2}}}},
+		textBlock(1, 0, "abc"), textBlock(2, 0, "x"))
+	// overlaps the blocks of a and leaves a gap
+	b := update([]ClientDeletes{{Client: 1, DeletedRanges: []DeleteRange{{StartClock: 2, DeleteLength: 1}}}},
+		textBlock(1, 1, "bcde"), textBlock(1, 8, "z"))
+
+	merged := MergeUpdates(a, b)
+
+	client1 := merged.Updates.Updates[1]
+	require.Len(t, client1, 3)
+	assert.Equal(t, "abc", client1[0].Content.(*ContentString).Str)
+	assert.Equal(t, ID{Client: 1, Clock: 3}, client1[1].ID)
+	assert.Equal(t, "de", client1[1].Content.(*ContentString).Str)
+	assert.Equal(t, ID{Client: 1, Clock: 2}, client1[1].LeftOrigin)
+	assert.Equal(t, ID{Client: 1, Clock: 8}, client1[2].ID)
+	assert.Len(t, merged.Updates.Updates[2], 1)
+
+	// the inputs are left untouched
+	assert.Equal(t, "bcde", b.Updates.Updates[1][0].Content.(*ContentString).Str)
+
+	assert.Equal(t, []ClientDeletes{{Client: 1, DeletedRanges: []DeleteRange{{StartClock: 0, DeleteLength: 3}}}}, merged.Deletes.ClientDeletes)
+	assert.Equal(t, map[int64]int64{1: 5, 2: 1}, StateVector(merged))
+
+	// the gap survives encoding
+	data, err := merged.MarshalV2()
+	require.NoError(t, err)
+	var decoded Updates
+	require.NoError(t, decoded.UnmarshalV2(data))
+	assert.Equal(t, ID{Client: 1, Clock: 8}, decoded.Updates.Updates[1][2].ID)
+}
+
+func TestDiffUpdate(t *testing.T) {
+	u := update(nil, textBlock(1, 0, "abc"), textBlock(1, 3, "de"), textBlock(2, 0, "x"))
+
+	diff := DiffUpdate(u, map[int64]int64{1: 4, 2: 1})
+	assert.NotContains(t, diff.Updates.Updates, int64(2))
+	require.Len(t, diff.Updates.Updates[1], 1)
+	assert.Equal(t, ID{Client: 1, Clock: 4}, diff.Updates.Updates[1][0].ID)
+	assert.Equal(t, "e", diff.Updates.Updates[1][0].Content.(*ContentString).Str)
+}
@@ -0,0 +1,464 @@
+package block
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/amoghyermalkar123/ygo/internal/encoding"
+)
+
+// The v1 update format of yjs: the blocks of every client, the client with
+// the highest ID first, followed by the delete set. A block only carries its
+// parent when it has no origins, otherwise it inherits it from its neighbors.
+
+// bits of the info byte written in front of every block
+const (
+	infoOrigin      uint8 = 0b1000_0000
+	infoRightOrigin uint8 = 0b0100_0000
+	infoParentSub   uint8 = 0b0010_0000
+	infoRef         uint8 = 0b0001_1111
+)
+
+// struct references that aren't content
+const (
+	refGC   uint8 = 0
+	refSkip uint8 = 10
+)
+
+// maxLength bounds lengths and clocks read from an update so
+// adding them up can't overflow
+const maxLength = 1 << 53
+
+var ErrInvalidUpdate = errors.New("invalid update")
+
+// MarshalV1 encodes the update in the yjs v1 format. The blocks
+// of every client have to be sorted by clock.
+func (u *Updates) MarshalV1() ([]byte, error) {
+	enc := encoding.NewEncoder()
+
+	clients := make([]int64, 0, len(u.Updates.Updates))
+	for client, blocks := range u.Updates.Updates {
+		if len(blocks) > 0 {
+			clients = append(clients, client)
+		}
+	}
+	slices.SortFunc(clients, func(a, b int64) int { return cmp.Compare(b, a) })
+
+	enc.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		blocks := u.Updates.Updates[client]
+
+		// gaps between the blocks are written as skips
+		structs := len(blocks)
+		for i := 1; i < len(blocks); i++ {
+			if blocks[i].ID.Clock != blocks[i-1].ID.Clock+int64(blocks[i-1].Len()) {
+				structs++
+			}
+		}
+
+		enc.WriteVarUint(uint64(structs))
+		enc.WriteVarUint(uint64(client))
+		enc.WriteVarUint(uint64(blocks[0].ID.Clock))
+
+		clock := blocks[0].ID.Clock
+		for _, blk := range blocks {
+			if blk.ID.Clock < clock {
+				return nil, fmt.Errorf("block %v overlaps the previous block of its client", blk.ID)
+			}
+			if blk.ID.Clock > clock {
+				enc.WriteUint8(refSkip)
+				enc.WriteVarUint(uint64(blk.ID.Clock - clock))
+			}
+			if err := blk.encodeV1(enc); err != nil {
+				return nil, fmt.Errorf("encode block %v: %w", blk.ID, err)
+			}
+			clock = blk.ID.Clock + int64(blk.Len())
+		}
+	}
+
+	u.Deletes.encodeV1(enc)
+
+	return enc.Bytes(), nil
+}
+
+func (b *Block) encodeV1(enc *encoding.Encoder) error {
+	if b.Parent.Lost {
+		enc.WriteUint8(refGC)
+		enc.WriteVarUint(uint64(b.Len()))
+		return nil
+	}
+
+	hasOrigin := b.LeftOrigin != (ID{})
+	hasRightOrigin := b.RightOrigin != (ID{})
+
+	info := b.Content.Ref() & infoRef
+	if hasOrigin {
+		info |= infoOrigin
+	}
+	if hasRightOrigin {
+		info |= infoRightOrigin
+	}
+	if b.ParentSub != "" {
+		info |= infoParentSub
+	}
+	enc.WriteUint8(info)
+
+	if hasOrigin {
+		writeID(enc, b.LeftOrigin)
+	}
+	if hasRightOrigin {
+		writeID(enc, b.RightOrigin)
+	}
+	if !hasOrigin && !hasRightOrigin {
+		if b.Parent.ID != nil {
+			enc.WriteVarUint(0)
+			writeID(enc, *b.Parent.ID)
+		} else {
+			enc.WriteVarUint(1)
+			enc.WriteVarString(b.Parent.Root)
+		}
+		if b.ParentSub != "" {
+			enc.WriteVarString(b.ParentSub)
+		}
+	}
+
+	return b.Content.Encode(enc)
+}
+
+func (d *DeleteUpdate) encodeV1(enc *encoding.Encoder) {
+	ranges := make(map[int64][]DeleteRange)
+	for _, cd := range d.ClientDeletes {
+		ranges[cd.Client] = append(ranges[cd.Client], cd.DeletedRanges...)
+	}
+
+	clients := slices.SortedFunc(maps.Keys(ranges), func(a, b int64) int { return cmp.Compare(b, a) })
+
+	enc.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		merged := MergeDeleteRanges(ranges[client])
+
+		enc.WriteVarUint(uint64(client))
+		enc.WriteVarUint(uint64(len(merged)))
+		for _, r := range merged {
+			enc.WriteVarUint(uint64(r.StartClock))
+			enc.WriteVarUint(uint64(r.DeleteLength))
+		}
+	}
+}
+
+// UnmarshalV1 decodes an update in the yjs v1 format. Blocks with origins
+// come without parent, Integrate takes it from their neighbors.
+func (u *Updates) UnmarshalV1(data []byte) error {
+	dec := encoding.NewDecoder(data)
+
+	numClients, err := readCount(dec)
+	if err != nil {
+		return err
+	}
+
+	u.Updates.Updates = make(map[int64][]*Block, numClients)
+	seen := make(map[int64]bool, numClients)
+	for i := 0; i < numClients; i++ {
+		numStructs, err := readCount(dec)
+		if err != nil {
+			return err
+		}
+		client, err := readNumber(dec)
+		if err != nil {
+			return err
+		}
+		// the blocks of a client are in a single section, one listed
+		// twice would overlap or run backwards
+		if seen[client] {
+			return fmt.Errorf("%w: client %d listed twice", ErrInvalidUpdate, client)
+		}
+		seen[client] = true
+		clock, err := readNumber(dec)
+		if err != nil {
+			return err
+		}
+
+		for j := 0; j < numStructs; j++ {
+			info, err := dec.ReadUint8()
+			if err != nil {
+				return err
+			}
+
+			id := ID{Client: client, Clock: clock}
+			var blk *Block
+
+			switch info & infoRef {
+			case refSkip:
+				n, err := readLength(dec)
+				if err != nil {
+					return err
+				}
+				clock += n
+				continue
+			case refGC:
+				n, err := readLength(dec)
+				if err != nil {
+					return err
+				}
+				blk = &Block{
+					ID:        id,
+					Content:   &ContentDeleted{Length: int(n)},
+					IsDeleted: true,
+					Parent:    Parent{Lost: true},
+				}
+			default:
+				if blk, err = decodeBlockV1(dec, info, id); err != nil {
+					return fmt.Errorf("decode block %v: %w", id, err)
+				}
+			}
+
+			if blk.Len() <= 0 {
+				return fmt.Errorf("%w: empty block %v", ErrInvalidUpdate, id)
+			}
+			clock += int64(blk.Len())
+			if clock > maxLength {
+				return fmt.Errorf("%w: clock overflow", ErrInvalidUpdate)
+			}
+
+			u.Updates.Updates[client] = append(u.Updates.Updates[client], blk)
+		}
+	}
+
+	return u.Deletes.decodeV1(dec)
+}
+
+func decodeBlockV1(dec *encoding.Decoder, info uint8, id ID) (*Block, error) {
+	blk := &Block{ID: id}
+
+	var err error
+	if info&infoOrigin != 0 {
+		if blk.LeftOrigin, err = readID(dec); err != nil {
+			return nil, err
+		}
+	}
+	if info&infoRightOrigin != 0 {
+		if blk.RightOrigin, err = readID(dec); err != nil {
+			return nil, err
+		}
+	}
+
+	if info&(infoOrigin|infoRightOrigin) == 0 {
+		isRoot, err := dec.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		if isRoot == 1 {
+			if blk.Parent.Root, err = dec.ReadVarString(); err != nil {
+				return nil, err
+			}
+		} else {
+			parent, err := readID(dec)
+			if err != nil {
+				return nil, err
+			}
+			blk.Parent.ID = &parent
+		}
+
+		if info&infoParentSub != 0 {
+			if blk.ParentSub, err = dec.ReadVarString(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if blk.Content, err = DecodeContent(info&infoRef, dec); err != nil {
+		return nil, err
+	}
+	// deleted content only ever exists in deleted blocks
+	_, blk.IsDeleted = blk.Content.(*ContentDeleted)
+
+	return blk, nil
+}
+
+func (d *DeleteUpdate) decodeV1(dec *encoding.Decoder) error {
+	numClients, err := readCount(dec)
+	if err != nil {
+		return err
+	}
+
+	d.NumClients = int64(numClients)
+	d.ClientDeletes = make([]ClientDeletes, 0, numClients)
+	for i := 0; i < numClients; i++ {
+		client, err := readNumber(dec)
+		if err != nil {
+			return err
+		}
+		numRanges, err := readCount(dec)
+		if err != nil {
+			return err
+		}
+
+		cd := ClientDeletes{Client: client, DeletedRanges: make([]DeleteRange, 0, numRanges)}
+		for j := 0; j < numRanges; j++ {
+			start, err := readNumber(dec)
+			if err != nil {
+				return err
+			}
+			length, err := readNumber(dec)
+			if err != nil {
+				return err
+			}
+			cd.DeletedRanges = append(cd.DeletedRanges, DeleteRange{StartClock: start, DeleteLength: length})
+		}
+		d.ClientDeletes = append(d.ClientDeletes, cd)
+	}
+
+	return nil
+}
+
+// EncodeStateVectorV1 encodes a state vector the way yjs does
+func EncodeStateVectorV1(sv map[int64]int64) []byte {
+	enc := encoding.NewEncoder()
+	writeStateVector(enc, sv)
+	return enc.Bytes()
+}
+
+// DecodeStateVectorV1 decodes a state vector written by yjs or EncodeStateVectorV1
+func DecodeStateVectorV1(data []byte) (map[int64]int64, error) {
+	return readStateVector(encoding.NewDecoder(data))
+}
+
+// EncodeSnapshotV1 encodes a delete set and a state vector
+// the way the encodeSnapshot of yjs does
+func EncodeSnapshotV1(ds map[int64][]DeleteRange, sv map[int64]int64) []byte {
+	enc := encoding.NewEncoder()
+
+	d := DeleteUpdate{}
+	for client, ranges := range ds {
+		d.ClientDeletes = append(d.ClientDeletes, ClientDeletes{Client: client, DeletedRanges: ranges})
+	}
+	d.encodeV1(enc)
+	writeStateVector(enc, sv)
+	return enc.Bytes()
+}
+
+// DecodeSnapshotV1 decodes a snapshot written by yjs or EncodeSnapshotV1,
+// the delete ranges of every client come back merged
+func DecodeSnapshotV1(data []byte) (map[int64][]DeleteRange, map[int64]int64, error) {
+	dec := encoding.NewDecoder(data)
+
+	var d DeleteUpdate
+	if err := d.decodeV1(dec); err != nil {
+		return nil, nil, err
+	}
+	sv, err := readStateVector(dec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ds := make(map[int64][]DeleteRange, len(d.ClientDeletes))
+	for _, cd := range d.ClientDeletes {
+		ds[cd.Client] = MergeDeleteRanges(append(ds[cd.Client], cd.DeletedRanges...))
+	}
+	return ds, sv, nil
+}
+
+func writeStateVector(enc *encoding.Encoder, sv map[int64]int64) {
+	clients := slices.SortedFunc(maps.Keys(sv), func(a, b int64) int { return cmp.Compare(b, a) })
+
+	enc.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		enc.WriteVarUint(uint64(client))
+		enc.WriteVarUint(uint64(sv[client]))
+	}
+}
+
+func readStateVector(dec *encoding.Decoder) (map[int64]int64, error) {
+	n, err := readCount(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	sv := make(map[int64]int64, n)
+	for i := 0; i < n; i++ {
+		client, err := readNumber(dec)
+		if err != nil {
+			return nil, err
+		}
+		if sv[client], err = readNumber(dec); err != nil {
+			return nil, err
+		}
+	}
+	return sv, nil
+}
+
+// MergeDeleteRanges sorts the ranges and merges the ones that
+// overlap or touch, the ranges passed in are left untouched
+func MergeDeleteRanges(ranges []DeleteRange) []DeleteRange {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b DeleteRange) int { return cmp.Compare(a.StartClock, b.StartClock) })
+
+	merged := sorted[:0]
+	for _, r := range sorted {
+		if r.DeleteLength <= 0 {
+			continue
+		}
+		if n := len(merged); n > 0 && r.StartClock <= merged[n-1].StartClock+merged[n-1].DeleteLength {
+			end := max(merged[n-1].StartClock+merged[n-1].DeleteLength, r.StartClock+r.DeleteLength)
+			merged[n-1].DeleteLength = end - merged[n-1].StartClock
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func writeID(enc *encoding.Encoder, id ID) {
+	enc.WriteVarUint(uint64(id.Client))
+	enc.WriteVarUint(uint64(id.Clock))
+}
+
+func readID(dec *encoding.Decoder) (ID, error) {
+	client, err := readNumber(dec)
+	if err != nil {
+		return ID{}, err
+	}
+	clock, err := readNumber(dec)
+	return ID{Client: client, Clock: clock}, err
+}
+
+// readNumber reads a client ID or a clock
+func readNumber(dec *encoding.Decoder) (int64, error) {
+	v, err := dec.ReadVarUint()
+	if err != nil {
+		return 0, err
+	}
+	if v > maxLength {
+		return 0, fmt.Errorf("%w: number %d out of range", ErrInvalidUpdate, v)
+	}
+	return int64(v), nil
+}
+
+// readLength reads the length of a struct or a deleted range
+func readLength(dec *encoding.Decoder) (int64, error) {
+	n, err := readNumber(dec)
+	if err == nil && n == 0 {
+		err = fmt.Errorf("%w: zero length", ErrInvalidUpdate)
+	}
+	return n, err
+}
+
+// readCount reads the number of entries that follow, every
+// entry takes at least a byte which bounds the count
+func readCount(dec *encoding.Decoder) (int, error) {
+	n, err := dec.ReadVarUint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(dec.Remaining()) {
+		return 0, encoding.ErrUnexpectedEOF
+	}
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: count %d out of range", ErrInvalidUpdate, n)
+	}
+	return int(n), nil
+}
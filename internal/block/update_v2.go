@@ -0,0 +1,625 @@
+package block
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/amoghyermalkar123/ygo/internal/encoding"
+)
+
+// The v2 update format of yjs holds the same blocks and delete set as v1,
+// but splits their fields into columns: the client IDs, the clocks of
+// left and right origins, the info bytes, the strings and so on are each
+// written one after the other, so runs compress well. What doesn't fit a
+// column, like the number of blocks and the values of ContentAny, goes
+// to the rest that follows the columns.
+
+// updateEncoderV2 holds the columns of a v2 update
+type updateEncoderV2 struct {
+	keyClock   *encoding.IntDiffOptRleEncoder
+	client     *encoding.UintOptRleEncoder
+	leftClock  *encoding.IntDiffOptRleEncoder
+	rightClock *encoding.IntDiffOptRleEncoder
+	info       *encoding.RleEncoder
+	str        *encoding.StringEncoder
+	parentInfo *encoding.RleEncoder
+	typeRef    *encoding.UintOptRleEncoder
+	length     *encoding.UintOptRleEncoder
+	rest       *encoding.Encoder
+
+	// keys is the number of keys written so far, yjs never reuses
+	// them, every key is written as a new one
+	keys int64
+}
+
+// MarshalV2 encodes the update in the yjs v2 format. The blocks
+// of every client have to be sorted by clock.
+func (u *Updates) MarshalV2() ([]byte, error) {
+	e := &updateEncoderV2{
+		keyClock:   encoding.NewIntDiffOptRleEncoder(),
+		client:     encoding.NewUintOptRleEncoder(),
+		leftClock:  encoding.NewIntDiffOptRleEncoder(),
+		rightClock: encoding.NewIntDiffOptRleEncoder(),
+		info:       encoding.NewRleEncoder(),
+		str:        encoding.NewStringEncoder(),
+		parentInfo: encoding.NewRleEncoder(),
+		typeRef:    encoding.NewUintOptRleEncoder(),
+		length:     encoding.NewUintOptRleEncoder(),
+		rest:       encoding.NewEncoder(),
+	}
+
+	clients := make([]int64, 0, len(u.Updates.Updates))
+	for client, blocks := range u.Updates.Updates {
+		if len(blocks) > 0 {
+			clients = append(clients, client)
+		}
+	}
+	slices.SortFunc(clients, func(a, b int64) int { return cmp.Compare(b, a) })
+
+	e.rest.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		blocks := u.Updates.Updates[client]
+
+		// gaps between the blocks are written as skips
+		structs := len(blocks)
+		for i := 1; i < len(blocks); i++ {
+			if blocks[i].ID.Clock != blocks[i-1].ID.Clock+int64(blocks[i-1].Len()) {
+				structs++
+			}
+		}
+
+		e.rest.WriteVarUint(uint64(structs))
+		e.client.Write(uint64(client))
+		e.rest.WriteVarUint(uint64(blocks[0].ID.Clock))
+
+		clock := blocks[0].ID.Clock
+		for _, blk := range blocks {
+			if blk.ID.Clock < clock {
+				return nil, fmt.Errorf("block %v overlaps the previous block of its client", blk.ID)
+			}
+			if blk.ID.Clock > clock {
+				e.info.Write(refSkip)
+				e.rest.WriteVarUint(uint64(blk.ID.Clock - clock))
+			}
+			if err := e.writeBlock(blk); err != nil {
+				return nil, fmt.Errorf("encode block %v: %w", blk.ID, err)
+			}
+			clock = blk.ID.Clock + int64(blk.Len())
+		}
+	}
+
+	u.Deletes.encodeV2(e.rest)
+
+	out := encoding.NewEncoder()
+	// feature flag, yjs doesn't use it yet
+	out.WriteVarUint(0)
+	for _, column := range [][]byte{
+		e.keyClock.Bytes(),
+		e.client.Bytes(),
+		e.leftClock.Bytes(),
+		e.rightClock.Bytes(),
+		e.info.Bytes(),
+		e.str.Bytes(),
+		e.parentInfo.Bytes(),
+		e.typeRef.Bytes(),
+		e.length.Bytes(),
+	} {
+		out.WriteVarBytes(column)
+	}
+	// the rest isn't prefixed with its length
+	return append(out.Bytes(), e.rest.Bytes()...), nil
+}
+
+func (e *updateEncoderV2) writeBlock(b *Block) error {
+	if b.Parent.Lost {
+		e.info.Write(refGC)
+		e.length.Write(uint64(b.Len()))
+		return nil
+	}
+
+	hasOrigin := b.LeftOrigin != (ID{})
+	hasRightOrigin := b.RightOrigin != (ID{})
+
+	info := b.Content.Ref() & infoRef
+	if hasOrigin {
+		info |= infoOrigin
+	}
+	if hasRightOrigin {
+		info |= infoRightOrigin
+	}
+	if b.ParentSub != "" {
+		info |= infoParentSub
+	}
+	e.info.Write(info)
+
+	if hasOrigin {
+		e.client.Write(uint64(b.LeftOrigin.Client))
+		e.leftClock.Write(b.LeftOrigin.Clock)
+	}
+	if hasRightOrigin {
+		e.client.Write(uint64(b.RightOrigin.Client))
+		e.rightClock.Write(b.RightOrigin.Clock)
+	}
+	if !hasOrigin && !hasRightOrigin {
+		if b.Parent.ID != nil {
+			e.parentInfo.Write(0)
+			e.client.Write(uint64(b.Parent.ID.Client))
+			e.leftClock.Write(b.Parent.ID.Clock)
+		} else {
+			e.parentInfo.Write(1)
+			e.str.Write(b.Parent.Root)
+		}
+		if b.ParentSub != "" {
+			e.str.Write(b.ParentSub)
+		}
+	}
+
+	return e.writeContent(b.Content)
+}
+
+func (e *updateEncoderV2) writeKey(key string) {
+	e.keyClock.Write(e.keys)
+	e.keys++
+	e.str.Write(key)
+}
+
+func (e *updateEncoderV2) writeContent(content Content) error {
+	switch c := content.(type) {
+	case *ContentDeleted:
+		e.length.Write(uint64(c.Length))
+	case *ContentString:
+		e.str.Write(c.Str)
+	case *ContentEmbed:
+		return e.rest.WriteAny(c.Embed)
+	case *ContentFormat:
+		e.writeKey(c.Key)
+		return e.rest.WriteAny(c.Value)
+	case *ContentType:
+		e.typeRef.Write(uint64(c.TypeRef))
+		if c.hasName() {
+			e.writeKey(c.Name)
+		}
+	case *ContentAny:
+		e.length.Write(uint64(len(c.Values)))
+		for _, v := range c.Values {
+			if err := e.rest.WriteAny(v); err != nil {
+				return err
+			}
+		}
+	case *ContentBinary:
+		e.rest.WriteVarBytes(c.Data)
+	case *ContentDoc:
+		e.str.Write(c.GUID)
+		opts := c.Opts
+		if opts == nil {
+			opts = map[string]any{}
+		}
+		return e.rest.WriteAny(opts)
+	default:
+		// content yjs doesn't know, like moves, is written like in v1
+		return c.Encode(e.rest)
+	}
+	return nil
+}
+
+// the delete set of v2 writes every clock as the difference to the
+// end of the range before and lengths minus one
+func (d *DeleteUpdate) encodeV2(enc *encoding.Encoder) {
+	ranges := make(map[int64][]DeleteRange)
+	for _, cd := range d.ClientDeletes {
+		ranges[cd.Client] = append(ranges[cd.Client], cd.DeletedRanges...)
+	}
+
+	clients := slices.SortedFunc(maps.Keys(ranges), func(a, b int64) int { return cmp.Compare(b, a) })
+
+	enc.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		merged := MergeDeleteRanges(ranges[client])
+
+		enc.WriteVarUint(uint64(client))
+		enc.WriteVarUint(uint64(len(merged)))
+		var end int64
+		for _, r := range merged {
+			enc.WriteVarUint(uint64(r.StartClock - end))
+			enc.WriteVarUint(uint64(r.DeleteLength - 1))
+			end = r.StartClock + r.DeleteLength
+		}
+	}
+}
+
+// updateDecoderV2 reads the columns of a v2 update
+type updateDecoderV2 struct {
+	keyClock   *encoding.IntDiffOptRleDecoder
+	client     *encoding.UintOptRleDecoder
+	leftClock  *encoding.IntDiffOptRleDecoder
+	rightClock *encoding.IntDiffOptRleDecoder
+	info       *encoding.RleDecoder
+	str        *encoding.StringDecoder
+	parentInfo *encoding.RleDecoder
+	typeRef    *encoding.UintOptRleDecoder
+	length     *encoding.UintOptRleDecoder
+	rest       *encoding.Decoder
+
+	keys []string
+	// size is the size of the update, structs the number
+	// of blocks it may still hold, see readStructCount
+	size    int
+	structs int
+}
+
+// UnmarshalV2 decodes an update in the yjs v2 format. Blocks with origins
+// come without parent, Integrate takes it from their neighbors.
+func (u *Updates) UnmarshalV2(data []byte) error {
+	d, err := newUpdateDecoderV2(data)
+	if err != nil {
+		return err
+	}
+
+	numClients, err := readCount(d.rest)
+	if err != nil {
+		return err
+	}
+
+	u.Updates.Updates = make(map[int64][]*Block, numClients)
+	seen := make(map[int64]bool, numClients)
+	for i := 0; i < numClients; i++ {
+		numStructs, err := d.readStructCount()
+		if err != nil {
+			return err
+		}
+		client, err := d.readNumber(d.client)
+		if err != nil {
+			return err
+		}
+		// the blocks of a client are in a single section, one listed
+		// twice would overlap or run backwards
+		if seen[client] {
+			return fmt.Errorf("%w: client %d listed twice", ErrInvalidUpdate, client)
+		}
+		seen[client] = true
+		clock, err := readNumber(d.rest)
+		if err != nil {
+			return err
+		}
+
+		for j := 0; j < numStructs; j++ {
+			info, err := d.info.Read()
+			if err != nil {
+				return err
+			}
+
+			id := ID{Client: client, Clock: clock}
+			var blk *Block
+
+			switch info & infoRef {
+			case refSkip:
+				n, err := readLength(d.rest)
+				if err != nil {
+					return err
+				}
+				clock += n
+				continue
+			case refGC:
+				n, err := d.readLength()
+				if err != nil {
+					return err
+				}
+				blk = &Block{
+					ID:        id,
+					Content:   &ContentDeleted{Length: int(n)},
+					IsDeleted: true,
+					Parent:    Parent{Lost: true},
+				}
+			default:
+				if blk, err = d.readBlock(info, id); err != nil {
+					return fmt.Errorf("decode block %v: %w", id, err)
+				}
+			}
+
+			if blk.Len() <= 0 {
+				return fmt.Errorf("%w: empty block %v", ErrInvalidUpdate, id)
+			}
+			clock += int64(blk.Len())
+			if clock > maxLength {
+				return fmt.Errorf("%w: clock overflow", ErrInvalidUpdate)
+			}
+
+			u.Updates.Updates[client] = append(u.Updates.Updates[client], blk)
+		}
+	}
+
+	return u.Deletes.decodeV2(d.rest)
+}
+
+func newUpdateDecoderV2(data []byte) (*updateDecoderV2, error) {
+	dec := encoding.NewDecoder(data)
+	// feature flag, yjs doesn't use it yet
+	if _, err := dec.ReadVarUint(); err != nil {
+		return nil, err
+	}
+
+	columns := make([][]byte, 9)
+	for i := range columns {
+		var err error
+		if columns[i], err = dec.ReadVarBytes(); err != nil {
+			return nil, err
+		}
+	}
+	str, err := encoding.NewStringDecoder(columns[5])
+	if err != nil {
+		return nil, err
+	}
+
+	return &updateDecoderV2{
+		keyClock:   encoding.NewIntDiffOptRleDecoder(columns[0]),
+		client:     encoding.NewUintOptRleDecoder(columns[1]),
+		leftClock:  encoding.NewIntDiffOptRleDecoder(columns[2]),
+		rightClock: encoding.NewIntDiffOptRleDecoder(columns[3]),
+		info:       encoding.NewRleDecoder(columns[4]),
+		str:        str,
+		parentInfo: encoding.NewRleDecoder(columns[6]),
+		typeRef:    encoding.NewUintOptRleDecoder(columns[7]),
+		length:     encoding.NewUintOptRleDecoder(columns[8]),
+		rest:       dec,
+		size:       len(data),
+		structs:    8 * len(data),
+	}, nil
+}
+
+// readStructCount reads the number of structs of a client. Runs let a
+// handful of bytes stand for any number of equal blocks, yet every
+// block takes at least a bit of the update.
+func (d *updateDecoderV2) readStructCount() (int, error) {
+	n, err := d.rest.ReadVarUint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(d.structs) {
+		return 0, fmt.Errorf("%w: %d structs in an update of %d bytes", ErrInvalidUpdate, n, d.size)
+	}
+	d.structs -= int(n)
+	return int(n), nil
+}
+
+// readNumber reads a client ID or a clock from a column
+func (d *updateDecoderV2) readNumber(column *encoding.UintOptRleDecoder) (int64, error) {
+	v, err := column.Read()
+	if err != nil {
+		return 0, err
+	}
+	if v > maxLength {
+		return 0, fmt.Errorf("%w: number %d out of range", ErrInvalidUpdate, v)
+	}
+	return int64(v), nil
+}
+
+// readClock reads a clock from a column of differences
+func (d *updateDecoderV2) readClock(column *encoding.IntDiffOptRleDecoder) (int64, error) {
+	v, err := column.Read()
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > maxLength {
+		return 0, fmt.Errorf("%w: clock %d out of range", ErrInvalidUpdate, v)
+	}
+	return v, nil
+}
+
+// readLength reads the length of a struct or content from the length column
+func (d *updateDecoderV2) readLength() (int64, error) {
+	n, err := d.readNumber(d.length)
+	if err == nil && n == 0 {
+		err = fmt.Errorf("%w: zero length", ErrInvalidUpdate)
+	}
+	return n, err
+}
+
+func (d *updateDecoderV2) readID(clocks *encoding.IntDiffOptRleDecoder) (ID, error) {
+	client, err := d.readNumber(d.client)
+	if err != nil {
+		return ID{}, err
+	}
+	clock, err := d.readClock(clocks)
+	return ID{Client: client, Clock: clock}, err
+}
+
+func (d *updateDecoderV2) readKey() (string, error) {
+	clock, err := d.keyClock.Read()
+	if err != nil {
+		return "", err
+	}
+	if clock >= 0 && clock < int64(len(d.keys)) {
+		return d.keys[clock], nil
+	}
+	key, err := d.str.Read()
+	if err != nil {
+		return "", err
+	}
+	d.keys = append(d.keys, key)
+	return key, nil
+}
+
+func (d *updateDecoderV2) readBlock(info uint8, id ID) (*Block, error) {
+	blk := &Block{ID: id}
+
+	var err error
+	if info&infoOrigin != 0 {
+		if blk.LeftOrigin, err = d.readID(d.leftClock); err != nil {
+			return nil, err
+		}
+	}
+	if info&infoRightOrigin != 0 {
+		if blk.RightOrigin, err = d.readID(d.rightClock); err != nil {
+			return nil, err
+		}
+	}
+
+	if info&(infoOrigin|infoRightOrigin) == 0 {
+		isRoot, err := d.parentInfo.Read()
+		if err != nil {
+			return nil, err
+		}
+		if isRoot == 1 {
+			if blk.Parent.Root, err = d.str.Read(); err != nil {
+				return nil, err
+			}
+		} else {
+			parent, err := d.readID(d.leftClock)
+			if err != nil {
+				return nil, err
+			}
+			blk.Parent.ID = &parent
+		}
+
+		if info&infoParentSub != 0 {
+			if blk.ParentSub, err = d.str.Read(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if blk.Content, err = d.readContent(info & infoRef); err != nil {
+		return nil, err
+	}
+	// deleted content only ever exists in deleted blocks
+	_, blk.IsDeleted = blk.Content.(*ContentDeleted)
+
+	return blk, nil
+}
+
+func (d *updateDecoderV2) readContent(ref uint8) (Content, error) {
+	switch ref {
+	case RefDeleted:
+		n, err := d.readLength()
+		return &ContentDeleted{Length: int(n)}, err
+	case RefJSON:
+		n, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		// every value takes at least a character of the strings
+		if n > int64(d.size) {
+			return nil, encoding.ErrUnexpectedEOF
+		}
+		values := make([]any, 0, n)
+		for i := int64(0); i < n; i++ {
+			str, err := d.str.Read()
+			if err != nil {
+				return nil, err
+			}
+			var v any
+			if str != "undefined" {
+				if err := json.Unmarshal([]byte(str), &v); err != nil {
+					return nil, fmt.Errorf("decode json content: %w", err)
+				}
+			}
+			values = append(values, v)
+		}
+		return &ContentAny{Values: values}, nil
+	case RefBinary:
+		data, err := d.rest.ReadVarBytes()
+		return &ContentBinary{Data: data}, err
+	case RefString:
+		str, err := d.str.Read()
+		return &ContentString{Str: str}, err
+	case RefEmbed:
+		embed, err := d.rest.ReadAny()
+		return &ContentEmbed{Embed: embed}, err
+	case RefFormat:
+		key, err := d.readKey()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.rest.ReadAny()
+		return &ContentFormat{Key: key, Value: value}, err
+	case RefType:
+		typeRef, err := d.typeRef.Read()
+		if err != nil {
+			return nil, err
+		}
+		c := &ContentType{TypeRef: uint8(typeRef)}
+		if c.hasName() {
+			c.Name, err = d.readKey()
+		}
+		return c, err
+	case RefAny:
+		n, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if n > int64(d.rest.Remaining()) {
+			return nil, encoding.ErrUnexpectedEOF
+		}
+		values := make([]any, 0, n)
+		for i := int64(0); i < n; i++ {
+			v, err := d.rest.ReadAny()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return &ContentAny{Values: values}, nil
+	case RefDoc:
+		guid, err := d.str.Read()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.rest.ReadAny()
+		if err != nil {
+			return nil, err
+		}
+		opts, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("decode doc content: options are a %T", v)
+		}
+		return &ContentDoc{GUID: guid, Opts: opts}, nil
+	case RefMove:
+		return decodeContentMove(d.rest)
+	default:
+		return nil, fmt.Errorf("unknown content reference %d", ref)
+	}
+}
+
+func (d *DeleteUpdate) decodeV2(dec *encoding.Decoder) error {
+	numClients, err := readCount(dec)
+	if err != nil {
+		return err
+	}
+
+	d.NumClients = int64(numClients)
+	d.ClientDeletes = make([]ClientDeletes, 0, numClients)
+	for i := 0; i < numClients; i++ {
+		client, err := readNumber(dec)
+		if err != nil {
+			return err
+		}
+		numRanges, err := readCount(dec)
+		if err != nil {
+			return err
+		}
+
+		cd := ClientDeletes{Client: client, DeletedRanges: make([]DeleteRange, 0, numRanges)}
+		var end int64
+		for j := 0; j < numRanges; j++ {
+			diff, err := readNumber(dec)
+			if err != nil {
+				return err
+			}
+			length, err := readNumber(dec)
+			if err != nil {
+				return err
+			}
+			r := DeleteRange{StartClock: end + diff, DeleteLength: length + 1}
+			if end = r.StartClock + r.DeleteLength; end > maxLength {
+				return fmt.Errorf("%w: clock overflow", ErrInvalidUpdate)
+			}
+			cd.DeletedRanges = append(cd.DeletedRanges, r)
+		}
+		d.ClientDeletes = append(d.ClientDeletes, cd)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package blockstore
+
+import (
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+// slot is a single clock of a move, the place one element is moved to
+type slot struct {
+	id       block.ID
+	priority int64
+}
+
+// wins reports whether the slot wins over `other` for the same element
+func (s slot) wins(other slot) bool {
+	if s.priority != other.priority {
+		return s.priority > other.priority
+	}
+	if s.id.Client != other.id.Client {
+		return s.id.Client > other.id.Client
+	}
+	return s.id.Clock > other.id.Clock
+}
+
+// ResolveMoves decides where every moved element of `t` is shown and sets
+// the Moved flag of all blocks accordingly: an element shows up at the
+// winning move for it, or at its original position if there is none.
+// Blocks are split wherever elements of one block end up differently.
+func (s *BlockStore) ResolveMoves(t *Type) {
+	winners := make(map[block.ID]slot)
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		m, ok := blk.Content.(*block.ContentMove)
+		if !ok || blk.IsDeleted {
+			continue
+		}
+		for i, target := range m.Targets {
+			candidate := slot{id: block.ID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(i)}, priority: m.Priority}
+			if w, ok := winners[target]; !ok || candidate.wins(w) {
+				winners[target] = candidate
+			}
+		}
+	}
+
+	// moved reports whether the i-th element of blk is shown elsewhere
+	moved := func(blk *block.Block, i int) bool {
+		id := block.ID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(i)}
+		if m, ok := blk.Content.(*block.ContentMove); ok {
+			target := m.Targets[i]
+			return winners[target].id != id || s.isDeletedID(target)
+		}
+		_, ok := winners[id]
+		return ok
+	}
+
+	changed := false
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		if blk.IsDeleted || !blk.Content.Countable() {
+			continue
+		}
+
+		m := moved(blk, 0)
+		for i := 1; i < blk.Len(); i++ {
+			if moved(blk, i) != m {
+				// the rest is looked at as the next block
+				s.PreciseBlockCut(blk, i)
+				break
+			}
+		}
+		if blk.Moved == m {
+			continue
+		}
+
+		if m {
+			t.adjustLength(-blk.Len())
+		} else {
+			t.adjustLength(blk.Len())
+		}
+		if s.Txn != nil {
+			s.Txn.setMoved(blk, m)
+			s.Txn.addChanged(t, "")
+		} else {
+			blk.Moved = m
+		}
+		changed = true
+	}
+
+	if changed {
+		t.MarkerSystem.DestroyMarkers()
+		t.Version++
+	}
+}
+
+// MovePriority returns the priority for a new move in `t`,
+// higher than the priority of every move it already has
+func (t *Type) MovePriority() int64 {
+	var priority int64
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		if m, ok := blk.Content.(*block.ContentMove); ok && m.Priority > priority {
+			priority = m.Priority
+		}
+	}
+	return priority + 1
+}
+
+// GetBlock returns the block containing id without splitting it,
+// nil if there is no such block
+func (s *BlockStore) GetBlock(id block.ID) *block.Block {
+	if !s.HasBlock(id) {
+		return nil
+	}
+	blk := s.Blocks[id.Client][s.FindIndexInBlockArrayByID(s.Blocks[id.Client], id)]
+	if id.Clock < blk.ID.Clock || id.Clock >= blk.ID.Clock+int64(blk.Len()) {
+		return nil
+	}
+	return blk
+}
+
+// DeleteElement deletes the single element with the given ID
+func (s *BlockStore) DeleteElement(id block.ID) {
+	blk := s.GetItemCleanStart(id)
+	if blk == nil || blk.IsDeleted {
+		return
+	}
+	if blk.Len() > 1 {
+		s.PreciseBlockCut(blk, 1)
+	}
+	s.deleteBlock(blk)
+}
+
+func (s *BlockStore) isDeletedID(id block.ID) bool {
+	blk := s.GetBlock(id)
+	return blk == nil || blk.IsDeleted
+}
@@ -0,0 +1,75 @@
+package blockstore
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+// Snapshot is the state of a document at some point: every block below
+// the state vector existed then and was visible unless it's in the
+// delete set. Rendering a snapshot needs the content of deleted blocks,
+// so it only works with garbage collection turned off.
+type Snapshot struct {
+	StateVector map[int64]int64
+	// DeleteSet holds the merged deleted ranges of every client
+	DeleteSet map[int64][]block.DeleteRange
+}
+
+// Snapshot returns the current state of the store
+func (s *BlockStore) Snapshot() *Snapshot {
+	ds := make(map[int64][]block.DeleteRange, len(s.DeleteSet))
+	for client, ranges := range s.DeleteSet {
+		if merged := block.MergeDeleteRanges(ranges); len(merged) > 0 {
+			ds[client] = merged
+		}
+	}
+	return &Snapshot{StateVector: maps.Clone(s.StateVector), DeleteSet: ds}
+}
+
+// Visible reports whether the character `id` was visible in the snapshot
+func (sn *Snapshot) Visible(id block.ID) bool {
+	if id.Clock >= sn.StateVector[id.Client] {
+		return false
+	}
+	return !sn.deleted(id)
+}
+
+func (sn *Snapshot) deleted(id block.ID) bool {
+	ranges := sn.DeleteSet[id.Client]
+	// the first range starting after the clock, the one before may contain it
+	i, _ := slices.BinarySearchFunc(ranges, id.Clock, func(r block.DeleteRange, clock int64) int {
+		if r.StartClock <= clock {
+			return -1
+		}
+		return 1
+	})
+	return i > 0 && id.Clock < ranges[i-1].StartClock+ranges[i-1].DeleteLength
+}
+
+// Cuts returns the offsets inside blk, in ascending order, where its
+// visibility in the snapshot may change. All characters between two
+// cuts are either visible or not.
+func (sn *Snapshot) Cuts(blk *block.Block) []int {
+	start, end := blk.ID.Clock, blk.ID.Clock+int64(blk.Len())
+
+	var cuts []int
+	add := func(clock int64) {
+		if clock > start && clock < end {
+			cuts = append(cuts, int(clock-start))
+		}
+	}
+
+	add(sn.StateVector[blk.ID.Client])
+	for _, r := range sn.DeleteSet[blk.ID.Client] {
+		if r.StartClock >= end {
+			break
+		}
+		add(r.StartClock)
+		add(r.StartClock + r.DeleteLength)
+	}
+
+	slices.Sort(cuts)
+	return slices.Compact(cuts)
+}
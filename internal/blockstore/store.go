@@ -2,6 +2,7 @@ package blockstore
 
 import (
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"sort"
 
@@ -9,47 +10,157 @@ import (
 	markers "github.com/amoghyermalkar123/ygo/internal/marker"
 	"github.com/amoghyermalkar123/ygo/internal/utils"
 	"github.com/amoghyermalkar123/ygo/logger"
-
-	"go.uber.org/zap"
 )
 
+// Type is the list of blocks making up a single shared type.
+// Every root type of a document owns one, so positions in one
+// type never interfere with positions in another.
+type Type struct {
+	Name         string
+	Start        *block.Block
+	Length       int
+	MarkerSystem *markers.MarkerSystem
+	// Map points to the rightmost block of every key, its
+	// value is the current value of the key unless it's deleted
+	Map map[string]*block.Block
+	// HasFormatting is set once a format marker was integrated into
+	// the type, until then its text can be edited without tracking
+	// formatting attributes
+	HasFormatting bool
+	// HasMoves is set once a move was integrated into the type,
+	// from then on ResolveMoves has to run after every change
+	HasMoves bool
+	// Item is the block holding a nested type, nil for root types
+	Item *block.Block
+	// Version is bumped by every change of the type,
+	// values derived from the type can be cached by it
+	Version uint64
+}
+
 type BlockStore struct {
-	Start  *block.Block
-	Length int
+	// Types holds the root types of the document by name, see Root
+	Types map[string]*Type
+	// Nested holds the nested types by the ID of the block holding them
+	Nested map[block.ID]*Type
 	Clock  int64
 	Blocks map[int64][]*block.Block
 	// SV always stores the next expected clock for a client
 	StateVector     map[int64]int64
-	MarkerSystem    *markers.MarkerSystem
 	CurrentClientID int64
-	// lists all deletions performed by the CurentClientID
+	// lists all deleted clock ranges by the client that created the blocks
 	DeleteSet map[int64][]block.DeleteRange
+	// Txn is the running transaction, nil when there is none
+	Txn *Transaction
+	// GC drops the content of deleted blocks at the end of every
+	// transaction, only their length is remembered
+	GC bool
+	// Log receives debug output, it discards everything by default
+	Log *slog.Logger
 }
 
 // NewStore initializes a new BlockStore.
 func NewStore() *BlockStore {
 	b := &BlockStore{
+		Types:           make(map[string]*Type),
+		Nested:          make(map[block.ID]*Type),
 		Blocks:          make(map[int64][]*block.Block),
 		StateVector:     make(map[int64]int64),
-		MarkerSystem:    markers.NewSystem(),
-		CurrentClientID: rand.Int63(),
+		CurrentClientID: int64(rand.Uint32()),
 		DeleteSet:       make(map[int64][]block.DeleteRange),
+		Log:             logger.Discard(),
 	}
 
 	return b
 }
 
-func (s *BlockStore) adjustLength(content string) {
-	s.Length += len(content)
+// Root returns the root type with the given name, creating it on first use.
+// Remote blocks create their root type the same way, so a type may exist
+// before anyone locally asked for it.
+func (s *BlockStore) Root(name string) *Type {
+	if t, ok := s.Types[name]; ok {
+		return t
+	}
+
+	t := newType()
+	t.Name = name
+	s.Types[name] = t
+
+	return t
+}
+
+// NestedType returns the type held by `blk`, a block with ContentType
+// content. Like root types it's created on first use.
+func (s *BlockStore) NestedType(blk *block.Block) *Type {
+	if t, ok := s.Nested[blk.ID]; ok {
+		return t
+	}
+
+	t := newType()
+	t.Item = blk
+	s.Nested[blk.ID] = t
+
+	return t
+}
+
+// ParentType returns the type holding the nested type `t`, nil for root types
+func (s *BlockStore) ParentType(t *Type) *Type {
+	if t.Item == nil {
+		return nil
+	}
+	return s.typeOf(t.Item)
+}
+
+func newType() *Type {
+	return &Type{
+		MarkerSystem: markers.NewSystem(),
+		Map:          make(map[string]*block.Block),
+	}
+}
+
+// typeOf returns the type the block belongs to, nil if its type is
+// gone or its parent names a block that doesn't hold a type
+func (s *BlockStore) typeOf(blk *block.Block) *Type {
+	if blk.Parent.Lost {
+		return nil
+	}
+	if blk.Parent.ID == nil {
+		return s.Root(blk.Parent.Root)
+	}
+	if t, ok := s.Nested[*blk.Parent.ID]; ok {
+		return t
+	}
+
+	item := s.getBlock(*blk.Parent.ID)
+	if item == nil || item.ID != *blk.Parent.ID {
+		return nil
+	}
+	if _, ok := item.Content.(*block.ContentType); !ok {
+		return nil
+	}
+	return s.NestedType(item)
+}
+
+// parent returns what blocks of the type refer to as their parent
+func (t *Type) parent() block.Parent {
+	if t.Item != nil {
+		id := t.Item.ID
+		return block.Parent{ID: &id}
+	}
+	return block.Parent{Root: t.Name}
+}
+
+func (t *Type) adjustLength(delta int) {
+	t.Length += delta
 }
 
 func (s *BlockStore) updateState(block *block.Block) {
+	end := block.ID.Clock + int64(block.Len())
 	if current, ok := s.StateVector[block.ID.Client]; ok {
-		if block.ID.Clock > current {
-			s.StateVector[block.ID.Client] = block.ID.Clock + int64(len(block.Content))
+		if end > current {
+			s.StateVector[block.ID.Client] = end
 		}
 	} else {
-		s.StateVector[block.ID.Client] = block.ID.Clock + int64(len(block.Content))
+		s.StateVector[block.ID.Client] = end
 	}
 }
 
@@ -66,7 +177,7 @@ func (s *BlockStore) GetCurrentClient() int64 {
 
 func (s *BlockStore) GetMissing(blk *block.Block) *int64 {
 	check := func(origin block.ID) *int64 {
-		if (origin != block.ID{} && origin.Client != blk.ID.Client && origin.Clock > s.GetState(origin.Client)) {
+		if (origin != block.ID{} && origin.Client != blk.ID.Client && origin.Clock >= s.GetState(origin.Client)) {
 			return &origin.Client
 		}
 		return nil
@@ -77,31 +188,59 @@ func (s *BlockStore) GetMissing(blk *block.Block) *int64 {
 	if m := check(blk.RightOrigin); m != nil {
 		return m
 	}
+	// the block holding the type has to be there as well,
+	// even when it's a block of the same client
+	if p := blk.Parent.ID; p != nil && p.Clock >= s.GetState(p.Client) {
+		return &p.Client
+	}
 	return nil
 }
 
-// InsertText inserts content at a given position (supports split).
-func (s *BlockStore) Insert(pos int64, content string) error {
-	logger.Info("insert text", zap.Int64("pos", pos), zap.String("content", content))
+// InsertText inserts content into the type `t` at a given position (supports split).
+func (s *BlockStore) Insert(t *Type, pos int64, content string) error {
+	s.Log.Debug("insert text", slog.String("type", t.Name), slog.Int64("pos", pos), slog.String("content", content))
+
+	return s.insert(t, pos, &block.Block{Content: &block.ContentString{Str: content}})
+}
+
+// InsertContent inserts any kind of content into the type `t` at a given position.
+func (s *BlockStore) InsertContent(t *Type, pos int64, content block.Content) error {
+	s.Log.Debug("insert content", slog.String("type", t.Name), slog.Int64("pos", pos), slog.Int("ref", int(content.Ref())))
 
+	return s.insert(t, pos, &block.Block{Content: content})
+}
+
+// insert places the brand new block `newBlk` carrying only
+// content at a given position (supports split).
+func (s *BlockStore) insert(t *Type, pos int64, newBlk *block.Block) error {
 	// find the correct position
-	blockPos, err := s.findPositionForNewBlock(pos)
+	blockPos, err := s.findPositionForNewBlock(t, pos)
 	if err != nil {
 		return fmt.Errorf("find position for new block: %w", err)
 	}
 
-	// create a brand new block
-	newBlk := &block.Block{
-		ID:        block.ID{Client: s.CurrentClientID, Clock: s.GetState(s.CurrentClientID)},
-		Content:   content,
-		IsDeleted: false,
-	}
+	s.insertAt(t, blockPos, newBlk)
+
+	return nil
+}
+
+// InsertAt inserts content into the type `t` right between pos.Left and
+// pos.Right, which must be neighbors. Afterwards pos.Right is the new block.
+func (s *BlockStore) InsertAt(t *Type, pos *block.BlockTextListPosition, content block.Content) *block.Block {
+	newBlk := &block.Block{Content: content}
+	s.insertAt(t, pos, newBlk)
+	return newBlk
+}
+
+func (s *BlockStore) insertAt(t *Type, blockPos *block.BlockTextListPosition, newBlk *block.Block) {
+	newBlk.ID = block.ID{Client: s.CurrentClientID, Clock: s.GetState(s.CurrentClientID)}
+	newBlk.Parent = t.parent()
 
 	// if we found a viable left neighbor from findPositionForNewBlock
-	// attach it
+	// attach it, the origin is the last character of the left block
 	if blockPos.Left != nil {
 		newBlk.Left = blockPos.Left
-		newBlk.LeftOrigin = blockPos.Left.ID
+		newBlk.LeftOrigin = lastID(blockPos.Left)
 	}
 
 	// if we found a viable right neighbor from findPositionForNewBlock
@@ -112,42 +251,57 @@ func (s *BlockStore) Insert(pos int64, content string) error {
 	}
 
 	// start integration
-	s.Integrate(newBlk, 0)
+	s.integrate(newBlk, 0)
 
 	blockPos.Right = newBlk
 
-	s.adjustLength(content)
-
-	s.MarkerSystem.Add(newBlk, pos)
+	// format markers and the like take no positions, markers only
+	// need to know about blocks that do
+	if !newBlk.Visible() {
+		return
+	}
 
-	return nil
+	// everything at or after the insertion point moved to the right
+	// blockPos.Index is where the block really landed, `pos` might
+	// have been past the end of the type
+	t.MarkerSystem.UpdateMarkers(blockPos.Index, int64(newBlk.Len()), markers.OpAdd)
+	t.MarkerSystem.Add(newBlk, blockPos.Index)
 }
 
-// DeleteText marks text as deleted starting from `pos`, over `length` characters.
-func (s *BlockStore) Delete(pos, length int64) error {
-	if length > int64(s.Length) {
-		return fmt.Errorf("delete length %d exceeds block store length %d", length, s.Length)
+// DeleteText marks text in the type `t` as deleted starting from `pos`, over `length` characters.
+func (s *BlockStore) Delete(t *Type, pos, length int64) error {
+	if length > int64(t.Length) {
+		return fmt.Errorf("delete length %d exceeds block store length %d", length, t.Length)
 	}
 	// find the correct position
-	blockPos, err := s.findPositionForNewBlock(pos)
+	blockPos, err := s.findPositionForNewBlock(t, pos)
 	if err != nil {
 		return fmt.Errorf("find position for new block: %w", err)
 	}
 
+	// markers inside the deleted range would point to deleted blocks
+	// and the ones after it move to the left
+	t.MarkerSystem.DeleteMarkersInRange(blockPos.Index, blockPos.Index+length)
+	t.MarkerSystem.UpdateMarkers(blockPos.Index+length, length, markers.OpDel)
+
 	// traverse and delete the blocks until `length` is deleted from blockstore
 	for length > 0 && blockPos.Right != nil {
-		if length < int64(len(blockPos.Right.Content)) {
+		// deleted blocks take no space, skip them
+		if !blockPos.Right.Visible() {
+			blockPos.Forward()
+			continue
+		}
+
+		if length < int64(blockPos.Right.Len()) {
 			s.refinePreciseBlock(block.ID{
 				Client: blockPos.Right.ID.Client,
 				Clock:  blockPos.Right.ID.Clock + int64(length),
 			})
 		}
 
-		s.addToDeleteSet(s.CurrentClientID, blockPos.Right.ID.Clock, int64(len(blockPos.Right.Content)))
+		length -= int64(blockPos.Right.Len())
 
-		length -= int64(len(blockPos.Right.Content))
-
-		blockPos.Right.MarkDeleted()
+		s.deleteBlock(blockPos.Right)
 
 		blockPos.Forward()
 	}
@@ -155,6 +309,107 @@ func (s *BlockStore) Delete(pos, length int64) error {
 	return nil
 }
 
+// MarkDeleted deletes a single block, for example on behalf of a remote
+// client. If the block took up positions they changed in ways the markers
+// don't know about so they are dropped.
+func (s *BlockStore) MarkDeleted(blk *block.Block) {
+	if blk.IsDeleted {
+		return
+	}
+	visible := blk.Visible()
+	s.deleteBlock(blk)
+	if visible {
+		s.typeOf(blk).MarkerSystem.DestroyMarkers()
+	}
+}
+
+// deleteBlock marks the block deleted and records it in the delete set
+// and the running transaction
+func (s *BlockStore) deleteBlock(blk *block.Block) {
+	if blk.IsDeleted {
+		return
+	}
+
+	t := s.typeOf(blk)
+	if blk.ParentSub == "" && blk.Visible() {
+		t.adjustLength(-blk.Len())
+	}
+	blk.MarkDeleted()
+	if t != nil {
+		t.Version++
+	}
+
+	s.addToDeleteSet(blk.ID.Client, blk.ID.Clock, int64(blk.Len()))
+	if s.Txn != nil {
+		s.Txn.addDeleted(blk)
+		s.Txn.addChanged(t, blk.ParentSub)
+		if _, ok := blk.Content.(*block.ContentDoc); ok {
+			s.Txn.DocsRemoved = append(s.Txn.DocsRemoved, blk)
+		}
+	}
+
+	// deleting a nested type deletes everything in it
+	if _, ok := blk.Content.(*block.ContentType); ok {
+		nested := s.NestedType(blk)
+		for child := nested.Start; child != nil; child = child.Right {
+			s.deleteBlock(child)
+		}
+		for _, child := range nested.Map {
+			s.deleteBlock(child)
+		}
+		nested.MarkerSystem.DestroyMarkers()
+	}
+}
+
+// SetMapValue sets `key` of the type `t` to `content`. The new block is
+// placed right of the current value of the key which it thereby deletes.
+func (s *BlockStore) SetMapValue(t *Type, key string, content block.Content) {
+	newBlk := &block.Block{
+		ID:        block.ID{Client: s.CurrentClientID, Clock: s.GetState(s.CurrentClientID)},
+		Parent:    t.parent(),
+		ParentSub: key,
+		Content:   content,
+	}
+
+	if left := t.Map[key]; left != nil {
+		newBlk.Left = left
+		newBlk.LeftOrigin = lastID(left)
+	}
+
+	s.integrate(newBlk, 0)
+}
+
+// DeleteMapValue deletes the current value of `key` in the type `t`
+func (s *BlockStore) DeleteMapValue(t *Type, key string) {
+	if blk := t.Map[key]; blk != nil {
+		s.deleteBlock(blk)
+	}
+}
+
+// getBlock returns the block containing the id without splitting it,
+// nil if there is no such block
+func (s *BlockStore) getBlock(id block.ID) *block.Block {
+	blocks := s.Blocks[id.Client]
+	i := searchBlocks(blocks, id.Clock)
+	if i < len(blocks) && blocks[i].ID.Clock <= id.Clock {
+		return blocks[i]
+	}
+	return nil
+}
+
+// searchBlocks returns the index of the first block ending after clock,
+// the blocks of a client are sorted by clock and don't overlap
+func searchBlocks(blocks []*block.Block, clock int64) int {
+	return sort.Search(len(blocks), func(i int) bool {
+		return clock < blocks[i].ID.Clock+int64(blocks[i].Len())
+	})
+}
+
+// lastID returns the ID of the last character in the block
+func lastID(blk *block.Block) block.ID {
+	return block.ID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(blk.Len()) - 1}
+}
+
 func (s *BlockStore) addToDeleteSet(client int64, startClock, length int64) {
 	s.DeleteSet[client] = append(s.DeleteSet[client], block.DeleteRange{
 		StartClock:   startClock,
@@ -175,7 +430,7 @@ func (s *BlockStore) getItemCleanEnd(id block.ID) *block.Block {
 	blk := structs[index]
 
 	// If the ID is not exactly at the end of the block, we need to split
-	if id.Clock != blk.ID.Clock+int64(len(blk.Content))-1 && !blk.IsDeleted {
+	if id.Clock != blk.ID.Clock+int64(blk.Len())-1 {
 		// Calculate the position to split: difference between target ID and block start + 1
 		// here id.Clock and blk.ID.Clock belong to same block
 		// so when we do id.Clock - blk.ID.Clock + 1
@@ -193,32 +448,62 @@ func (s *BlockStore) getItemCleanEnd(id block.ID) *block.Block {
 }
 
 // Integrate integrates a remote block into the local block store.
-// Core logic for CRDT convergence and conflict resolution.
+// The markers of the type don't know where the block landed so
+// they are dropped, local inserts keep them up to date instead.
 func (s *BlockStore) Integrate(newBlk *block.Block, offset int64) {
+	s.integrate(newBlk, offset)
+	if !newBlk.Parent.Lost {
+		s.typeOf(newBlk).MarkerSystem.DestroyMarkers()
+	}
+}
+
+// integrate is the core logic for CRDT convergence and conflict resolution.
+func (s *BlockStore) integrate(newBlk *block.Block, offset int64) {
 	// offset is localClock - remoteClock
 	// if its greater than 0 and less than the length of the block
 	// it means the new blk needs to be added somewhere in between
 	// the current block and hence that block needs a split
 	if offset > 0 {
+		// the whole block is already integrated, nothing left to do
+		if newBlk.Len() <= int(offset) {
+			return
+		}
+
 		// Adjust the clock
 		newBlk.ID.Clock = newBlk.ID.Clock + offset
 
-		// Find or create the left block that ends exactly where this block should start
-		// here newBlk.ID.Clock - 1 indicates the exact end of the left block we want
-		leftBlock := s.getItemCleanEnd(block.ID{Client: newBlk.ID.Client, Clock: newBlk.ID.Clock - 1})
-		newBlk.Left = leftBlock
+		// Trim the content to remove the already integrated part
+		newBlk.Content = newBlk.Content.Splice(int(offset))
 
-		// Update the origin to point to the end of the left block
-		newBlk.LeftOrigin = leftBlock.ID
+		if !newBlk.Parent.Lost {
+			// Find or create the left block that ends exactly where this block should start
+			// here newBlk.ID.Clock - 1 indicates the exact end of the left block we want
+			leftBlock := s.getItemCleanEnd(block.ID{Client: newBlk.ID.Client, Clock: newBlk.ID.Clock - 1})
+			newBlk.Left = leftBlock
 
-		// Trim the content to remove the already integrated part
-		if len(newBlk.Content) > int(offset) {
-			newBlk.Content = newBlk.Content[offset:]
-		} else {
-			newBlk.Content = ""
+			// Update the origin to point to the end of the left block
+			newBlk.LeftOrigin = lastID(leftBlock)
 		}
 	}
 
+	// blocks with an origin don't carry their parent in yjs updates,
+	// they belong wherever their neighbors belong
+	if newBlk.LeftOrigin != (block.ID{}) || newBlk.RightOrigin != (block.ID{}) {
+		if newBlk.Left != nil {
+			newBlk.Parent, newBlk.ParentSub = newBlk.Left.Parent, newBlk.Left.ParentSub
+		} else if newBlk.Right != nil {
+			newBlk.Parent, newBlk.ParentSub = newBlk.Right.Parent, newBlk.Right.ParentSub
+		}
+	}
+
+	// a block without a type is kept like the blocks of a type that's gone
+	t := s.typeOf(newBlk)
+	if t == nil {
+		newBlk.Parent, newBlk.ParentSub = block.Parent{Lost: true}, ""
+		s.integrateLost(newBlk)
+		return
+	}
+
 	// the whole purpose of this branch
 	// is to detect conflict and find the perfect left neighbor for `newBlk`
 	// this means what we find is a perfect conflict-free position
@@ -226,7 +511,7 @@ func (s *BlockStore) Integrate(newBlk *block.Block, offset int64) {
 	// the left and right neighbors of `newBlk` for nailing
 	// the final position of `newBlk` in our BlockStore
 	if (newBlk.Left == nil &&
-		(newBlk.Right == nil || newBlk.Right.Left == nil)) ||
+		(newBlk.Right == nil || newBlk.Right.Left != nil)) ||
 		(newBlk.Left != nil && newBlk.Left.Right != newBlk.Right) {
 
 		// this is the left pointer. We will find the best
@@ -237,25 +522,31 @@ func (s *BlockStore) Integrate(newBlk *block.Block, offset int64) {
 		var left *block.Block
 		left = newBlk.Left
 
-		// Find the first conflict candidate
-		o := s.Start
+		// Find the first conflict candidate, for map values
+		// that's the leftmost block ever written to the key
+		var o *block.Block
 		if left != nil {
 			o = left.Right
+		} else if newBlk.ParentSub != "" {
+			o = t.Map[newBlk.ParentSub]
+			for o != nil && o.Left != nil {
+				o = o.Left
+			}
+		} else {
+			o = t.Start
 		}
 
 		// Sets for conflict detection
-		conflicts := map[block.ID]bool{}
-		seenBefore := map[block.ID]bool{}
+		conflicts := map[*block.Block]bool{}
+		seenBefore := map[*block.Block]bool{}
 		// conflict resolution logic
 		for o != nil && o != newBlk.Right {
 
 			// very first thing, add this to the conflicting block set
 			// and the seenBefore block set. They are cleared once
 			// conflicts are resolved and the appropriate `left` is found
-			conflicts[o.ID] = true
-			if (o.LeftOrigin != block.ID{}) {
-				seenBefore[o.LeftOrigin] = true
-			}
+			conflicts[o] = true
+			seenBefore[o] = true
 			// do the conflicting block and remote block
 			// derive from the same origin?
 			if utils.EqualID(o.LeftOrigin, newBlk.LeftOrigin) {
@@ -263,20 +554,24 @@ func (s *BlockStore) Integrate(newBlk *block.Block, offset int64) {
 				if o.ID.Client < newBlk.ID.Client {
 					left = o
 					// and clear the conflicting items, since we have found new left
-					conflicts = map[block.ID]bool{}
+					conflicts = map[*block.Block]bool{}
 				} else if utils.EqualID(o.RightOrigin, newBlk.RightOrigin) {
 					// if remote block client is greater and we have same right origins
 					// break here since they will naturalyl be in correct order
 					break
 				}
-			} else if (o.LeftOrigin != block.ID{}) {
+			} else if origin := s.getBlock(o.LeftOrigin); (o.LeftOrigin != block.ID{}) && origin != nil && seenBefore[origin] {
 				// if no, check if we have seen the conflicting blocks left origin before
 				// if yes, check if it also conflicts
 				// if no, we have found new left, clear the conflicting items
-				if seenBefore[o.LeftOrigin] && !conflicts[o.LeftOrigin] {
+				if !conflicts[origin] {
 					left = o
-					conflicts = map[block.ID]bool{}
+					conflicts = map[*block.Block]bool{}
 				}
+			} else {
+				// the origin of `o` lies left of our origin, `o` and
+				// everything after it belongs to the right of `newBlk`
+				break
 			}
 			// move ahead one block to the right
 			// since we process one block at a time, left -> right
@@ -292,31 +587,91 @@ func (s *BlockStore) Integrate(newBlk *block.Block, offset int64) {
 	// handles right neighbor when either we have a left from post-conflict resolution
 	// OR we have a left from the original block
 	// handles left neighbor when it's nil then attaches the block at start
+	// of the sequence or of the chain of the map key
 	if newBlk.Left != nil {
 		newBlk.Right = newBlk.Left.Right
 		newBlk.Left.Right = newBlk
+	} else if newBlk.ParentSub != "" {
+		r := t.Map[newBlk.ParentSub]
+		for r != nil && r.Left != nil {
+			r = r.Left
+		}
+		newBlk.Right = r
 	} else {
-		newBlk.Right = s.Start
-		s.Start = newBlk
+		newBlk.Right = t.Start
+		t.Start = newBlk
 	}
 	// final reconnect, handles the right neighbor of the
 	// block to the left, which is the `newBlk` itself
 	if newBlk.Right != nil {
 		newBlk.Right.Left = newBlk
+	} else if newBlk.ParentSub != "" {
+		// the rightmost block of a key holds its value
+		// which means the previous value is gone
+		t.Map[newBlk.ParentSub] = newBlk
+		if newBlk.Left != nil {
+			s.deleteBlock(newBlk.Left)
+		}
+	}
+
+	// map values don't take space in the sequence
+	if newBlk.Visible() && newBlk.ParentSub == "" {
+		t.adjustLength(newBlk.Len())
+	}
+	if _, ok := newBlk.Content.(*block.ContentFormat); ok {
+		t.HasFormatting = true
+	}
+	if _, ok := newBlk.Content.(*block.ContentMove); ok {
+		t.HasMoves = true
 	}
+	t.Version++
 
 	// add the new block to the block store
 	s.addBlock(newBlk)
 	// update our state vector
 	s.updateState(newBlk)
+	// blocks may arrive deleted already, yjs only tells
+	// about them through the delete set
+	if newBlk.IsDeleted {
+		s.addToDeleteSet(newBlk.ID.Client, newBlk.ID.Clock, int64(newBlk.Len()))
+	}
+
+	if s.Txn != nil {
+		s.Txn.addChanged(t, newBlk.ParentSub)
+		if _, ok := newBlk.Content.(*block.ContentDoc); ok && !newBlk.IsDeleted {
+			s.Txn.DocsAdded = append(s.Txn.DocsAdded, newBlk)
+		}
+	}
+
+	// a map value that has a block to its right was overwritten
+	// concurrently by a winning write, and nothing survives in a
+	// nested type that was deleted concurrently
+	if (newBlk.ParentSub != "" && newBlk.Right != nil) || (t.Item != nil && t.Item.IsDeleted) {
+		s.deleteBlock(newBlk)
+	}
+}
+
+// integrateLost keeps a block whose type is gone as a deleted block
+// outside of any type, it's only there to cover its clock range
+func (s *BlockStore) integrateLost(blk *block.Block) {
+	blk.Left, blk.Right = nil, nil
+	blk.IsDeleted = true
+	if _, ok := blk.Content.(*block.ContentDeleted); !ok {
+		blk.Content = &block.ContentDeleted{Length: blk.Len()}
+	}
+
+	s.addBlock(blk)
+	s.updateState(blk)
+	s.addToDeleteSet(blk.ID.Client, blk.ID.Clock, int64(blk.Len()))
 }
 
-func (s *BlockStore) Content() string {
-	curr := s.Start
+// Content returns the visible text of the type
+func (t *Type) Content() string {
+	curr := t.Start
 	content := ""
 	for curr != nil {
-		if !curr.IsDeleted {
-			content += curr.Content
+		if str, ok := curr.Content.(*block.ContentString); ok && !curr.IsDeleted {
+			content += str.Str
 		}
 		curr = curr.Right
 	}
@@ -339,18 +694,24 @@ func (s *BlockStore) addBlock(blk *block.Block) {
 	s.Blocks[blk.ID.Client] = blocks
 }
 
+// FindPosition returns the neighbors of the position `index` in the type,
+// splitting the block containing it if needed
+func (s *BlockStore) FindPosition(t *Type, index int64) (*block.BlockTextListPosition, error) {
+	return s.findPositionForNewBlock(t, index)
+}
+
 // find the next appropriate position for integrating a new block
-func (s *BlockStore) findPositionForNewBlock(index int64) (*block.BlockTextListPosition, error) {
+func (s *BlockStore) findPositionForNewBlock(t *Type, index int64) (*block.BlockTextListPosition, error) {
 	textListPosition := &block.BlockTextListPosition{}
 
 	// find marker
-	marker, _ := s.MarkerSystem.FindMarker(index)
+	marker, _ := t.MarkerSystem.FindMarker(index)
 
-	logger.Debug("marker", marker.Block, nil, zap.Int64("index", index))
+	logger.Debug(s.Log, "marker", marker.Block, nil, slog.Int64("index", index))
 
 	if (marker == markers.Marker{}) {
 		textListPosition = &block.BlockTextListPosition{
-			Right: s.Start,
+			Right: t.Start,
 			Index: 0,
 		}
 	} else {
@@ -362,7 +723,7 @@ func (s *BlockStore) findPositionForNewBlock(index int64) (*block.BlockTextListP
 		}
 	}
 
-	logger.Debug("tlp", nil, textListPosition, zap.Int64("index", index))
+	logger.Debug(s.Log, "tlp", nil, textListPosition, slog.Int64("index", index))
 
 	// marker.Pos always point to the start of the block
 	// so index-marker.Pos is the offset from the start of the block
@@ -373,19 +734,21 @@ func (s *BlockStore) findPositionForNewBlock(index int64) (*block.BlockTextListP
 }
 
 func (s *BlockStore) refineTextListPosition(pos *block.BlockTextListPosition, blockOffset int64) *block.BlockTextListPosition {
-	// no block split at index required
-	// no need to refine the text list position
-	if blockOffset <= 0 {
-		return pos
-	}
-	// find the next position
-	// if necessary, split the block
-	// and then return the proper position
-	if !pos.Right.IsDeleted {
+	// walk right until the offset is used up, a block split at
+	// the index is required only when it falls inside a block
+	// once the offset is 0 there is no need to refine the text list position
+	for blockOffset > 0 && pos.Right != nil {
+		// deleted blocks take no space, step over them
+		if !pos.Right.Visible() {
+			pos.Left = pos.Right
+			pos.Right = pos.Right.Right
+			continue
+		}
+
 		// we deal with the right block
 		// so check if the offset is within the block
 		// if yes, we need a clean start so split the block
-		if blockOffset < int64(len(pos.Right.Content)) {
+		if blockOffset < int64(pos.Right.Len()) {
 			_ = s.refinePreciseBlock(block.ID{
 				Client: pos.Right.ID.Client,
 				Clock:  pos.Right.ID.Clock + int64(blockOffset),
@@ -397,8 +760,8 @@ func (s *BlockStore) refineTextListPosition(pos *block.BlockTextListPosition, bl
 		// since `pos` passed to this function is generally by `findPositionForNewBlock`
 		// where pos.Left is the left of the marker and pos.Right is the marker block itself
 		// while insertion, you will see this works out for us, check `Insert`
-		pos.Index += int64(len(pos.Right.Content))
-		blockOffset -= int64(len(pos.Right.Content))
+		pos.Index += int64(pos.Right.Len())
+		blockOffset -= int64(pos.Right.Len())
 		// move `pos` to the right
 		pos.Left = pos.Right
 		pos.Right = pos.Right.Right
@@ -410,14 +773,14 @@ func (s *BlockStore) refineTextListPosition(pos *block.BlockTextListPosition, bl
 // This is the position where the block is split
 // based on the clock provided in the id.
 func (s *BlockStore) refinePreciseBlock(id block.ID) *block.Block {
-	logger.Debug("refine required", nil, nil, zap.Any("ID", id))
+	logger.Debug(s.Log, "refine required", nil, nil, slog.Any("ID", id))
 
 	index := s.FindIndexInBlockArrayByID(s.Blocks[id.Client], id)
 
 	blk := s.Blocks[id.Client][index]
 
-	if !blk.IsDeleted && blk.ID.Clock <= id.Clock {
-		logger.Debug("refine block", blk, nil)
+	if blk.ID.Clock < id.Clock {
+		logger.Debug(s.Log, "refine block", blk, nil)
 
 		s.PreciseBlockCut(blk, int(id.Clock)-int(blk.ID.Clock))
 		// because we split the block, we deal with the right of the blk
@@ -429,38 +792,39 @@ func (s *BlockStore) refinePreciseBlock(id block.ID) *block.Block {
 }
 
 // equivalent to findIndexSS from yjs
-// this can be optimized to a hasmpa with O(1) lookup
-// but for now we will use a linear search
 func (s *BlockStore) FindIndexInBlockArrayByID(blocks []*block.Block, id block.ID) int {
-	for i, blk := range blocks {
-		if blk.ID.Clock == id.Clock || id.Clock < blk.ID.Clock+int64(len(blk.Content)) {
-			return i
-		}
+	if i := searchBlocks(blocks, id.Clock); i < len(blocks) {
+		return i
 	}
 	panic(fmt.Sprintf("findIndexInBlockArrayByID: no exact match for ID %v", id))
 }
 
 // PreciseBlockCut splits a block at the precise position of the diff provided to it
 func (s *BlockStore) PreciseBlockCut(left *block.Block, diff int) *block.Block {
-	logger.Debug("refining", left, nil, zap.Any("precise point", diff))
+	logger.Debug(s.Log, "refining", left, nil, slog.Int("precise point", diff))
 
-	if diff <= 0 || diff >= len(left.Content) {
-		panic(fmt.Sprintf("PreciseBlockCut: invalid split position %d in block with length %d", diff, len(left.Content)))
+	if diff <= 0 || diff >= left.Len() {
+		panic(fmt.Sprintf("PreciseBlockCut: invalid split position %d in block with length %d", diff, left.Len()))
 	}
 
-	// Create the right block
+	// Create the right block, splicing adjusts the content of the left block
 	right := &block.Block{
 		ID:          block.ID{Client: left.ID.Client, Clock: left.ID.Clock + int64(diff)},
-		Content:     left.Content[diff:],
+		Content:     left.Content.Splice(diff),
 		IsDeleted:   left.IsDeleted,
 		LeftOrigin:  block.ID{Client: left.ID.Client, Clock: left.ID.Clock + int64(diff-1)},
 		RightOrigin: left.RightOrigin,
+		Parent:      left.Parent,
+		ParentSub:   left.ParentSub,
 		Left:        left,
 		Right:       left.Right,
+		Moved:       left.Moved,
+	}
+	if s.Txn != nil {
+		s.Txn.splitMoved(left, right)
 	}
 
 	// Adjust left block
-	left.Content = left.Content[:diff]
 	left.Right = right
 
 	// Fix neighbor pointer if right was non-nil
@@ -468,8 +832,8 @@ func (s *BlockStore) PreciseBlockCut(left *block.Block, diff int) *block.Block {
 		right.Right.Left = right
 	}
 
-	logger.Debug("refined right", right, nil)
-	logger.Debug("refined left", left, nil)
+	logger.Debug(s.Log, "refined right", right, nil)
+	logger.Debug(s.Log, "refined left", left, nil)
 
 	// Insert new block into BlockStore
 	s.addBlock(right)
@@ -487,58 +851,22 @@ func (s *BlockStore) HasBlock(id block.ID) bool {
 	return true
 }
 
-// GetPreciseBlockByID retrieves a block by its ID, splitting a block if necessary
-// at the precise position of the ID.
-func (s *BlockStore) ResolveNeighborByPreciseBlockID(originID block.ID) *block.Block {
-	blocks, ok := s.Blocks[originID.Client]
-	if !ok {
+// GetItemCleanEnd returns the block ending exactly at id, splitting
+// the block containing it if needed. It resolves left origins.
+func (s *BlockStore) GetItemCleanEnd(id block.ID) *block.Block {
+	if !s.HasBlock(id) {
 		return nil
 	}
+	return s.getItemCleanEnd(id)
+}
 
-	for _, b := range blocks {
-		if b.ID.Clock == originID.Clock {
-			return b
-		}
-	}
-
-	// If no exact match, look for a block that contains this ID
-	for _, b := range blocks {
-		blockStart := b.ID.Clock
-		blockEnd := blockStart + int64(len(b.Content)) - 1
-
-		// basically check if the block we want as a neighbor
-		// as suggested by the clock in the `originID` falls somewhere
-		// between an existing block's clock range, if yes that means
-		// that the new block is placed in between the existing block we have
-		// and we need to split this existing block
-		if originID.Clock >= blockStart && originID.Clock <= blockEnd {
-			// We found a block that contains this ID
-			// We need to split it to create the exact block we're looking for
-
-			// If we're looking for the end of a block
-			if originID.Clock == blockEnd {
-				return b
-			}
-
-			// If we're looking for the start of a block
-			if originID.Clock == blockStart {
-				return b
-			}
-
-			// If we're looking for a position in the middle of a block
-			// Split the block at the appropriate position
-			if originID.Clock > blockStart {
-				// Split at id.Clock - blockStart
-				splitPos := int(originID.Clock - blockStart)
-				if splitPos > 0 && splitPos < len(b.Content) {
-					right := s.PreciseBlockCut(b, splitPos)
-					// If we want the start of the right part
-					return right
-				}
-			}
-		}
+// GetItemCleanStart returns the block starting exactly at id, splitting
+// the block containing it if needed. It resolves right origins.
+func (s *BlockStore) GetItemCleanStart(id block.ID) *block.Block {
+	if !s.HasBlock(id) {
+		return nil
 	}
-	return nil
+	return s.refinePreciseBlock(id)
 }
 
 // GetBlocksInRange returns blocks from a specific client within a clock range
@@ -3,110 +3,329 @@ package blockstore
 import (
 	"testing"
 
-	"github.com/amoghyermalkar123/ygo/logger"
+	"github.com/amoghyermalkar123/ygo/internal/block"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func init() {
-	logger.Init()
-}
-
 func TestInsertAtBeginning(t *testing.T) {
 
 	store := NewStore()
-	err := store.Insert(0, "Hello")
+	text := store.Root("")
+	err := store.Insert(text, 0, "Hello")
 	if err != nil {
 		t.Fatalf("insert failed: %v", err)
 	}
-	if got := store.Content(); got != "Hello" {
+	if got := text.Content(); got != "Hello" {
 		t.Errorf("expected Hello, got %q", got)
 	}
 }
 
 func TestInsertAtEnd(t *testing.T) {
 	store := NewStore()
-	err := store.Insert(0, "Hi")
+	text := store.Root("")
+	err := store.Insert(text, 0, "Hi")
 	assert.NoError(t, err)
-	err = store.Insert(2, " there")
+	err = store.Insert(text, 2, " there")
 	assert.NoError(t, err)
 
-	if got := store.Content(); got != "Hi there" {
+	if got := text.Content(); got != "Hi there" {
 		t.Errorf("expected 'Hi there', got %q", got)
 	}
 }
 
 func TestInsertInMiddle(t *testing.T) {
 	store := NewStore()
+	text := store.Root("")
 
-	_ = store.Insert(0, "A")
-	_ = store.Insert(1, "B")
-	_ = store.Insert(1, "X") // Insert in middle →)
+	_ = store.Insert(text, 0, "A")
+	_ = store.Insert(text, 1, "B")
+	_ = store.Insert(text, 1, "X") // Insert in middle →)
 
-	if got := store.Content(); got != "AXB" {
+	if got := text.Content(); got != "AXB" {
 		t.Errorf("expected AXB, got %q", got)
 	}
 }
 
 func TestInsertTriggersSplit(t *testing.T) {
 	store := NewStore()
-	_ = store.Insert(0, "World")
-	_ = store.Insert(2, "X") // Wo|rld → insert "X" at p)
+	text := store.Root("")
+	_ = store.Insert(text, 0, "World")
+	_ = store.Insert(text, 2, "X") // Wo|rld → insert "X" at p)
 
-	if got := store.Content(); got != "WoXrld" {
+	if got := text.Content(); got != "WoXrld" {
 		t.Errorf("expected WoXrld, got %q", got)
 	}
 }
 
 func TestDeleteSingleBlock(t *testing.T) {
 	store := NewStore()
-	_ = store.Insert(0, "A")
+	text := store.Root("")
+	_ = store.Insert(text, 0, "A")
 
-	err := store.Delete(0, 1)
+	err := store.Delete(text, 0, 1)
 	if err != nil {
 		t.Fatalf("delete failed: %v", err)
 	}
-	if got := store.Content(); got != "" {
+	if got := text.Content(); got != "" {
 		t.Errorf("expected empty string, got %q", got)
 	}
 }
 
 func TestDeleteMiddleOfBlock(t *testing.T) {
 	store := NewStore()
+	text := store.Root("")
 
-	_ = store.Insert(0, "Hello")
+	_ = store.Insert(text, 0, "Hello")
 
-	err := store.Delete(1, 3) // Remove "ell"
+	err := store.Delete(text, 1, 3) // Remove "ell"
 	if err != nil {
 		t.Fatalf("delete failed: %v", err)
 	}
-	if got := store.Content(); got != "Ho" {
+	if got := text.Content(); got != "Ho" {
 		t.Errorf("expected Ho, got %q", got)
 	}
 }
 
 func TestDeleteMultipleBlocks(t *testing.T) {
 	store := NewStore()
+	text := store.Root("")
 
-	_ = store.Insert(0, "Hi")
-	_ = store.Insert(2, " there") // "Hi th)
+	_ = store.Insert(text, 0, "Hi")
+	_ = store.Insert(text, 2, " there") // "Hi th)
 
-	err := store.Delete(1, 5) // Remove "i the"
+	err := store.Delete(text, 1, 5) // Remove "i the"
 	if err != nil {
 		t.Fatalf("delete failed: %v", err)
 	}
-	if got := store.Content(); got != "Hre" {
+	if got := text.Content(); got != "Hre" {
 		t.Errorf("expected Hr, got %q", got)
 	}
 }
 
 func TestDeleteOutOfBounds(t *testing.T) {
 	store := NewStore()
-	_ = store.Insert(0, "Yo")
+	text := store.Root("")
+	_ = store.Insert(text, 0, "Yo")
 
-	err := store.Delete(3, 3)
+	err := store.Delete(text, 3, 3)
 
 	if err == nil {
 		t.Fatal("expected error on out-of-bounds delete, got %w", err)
 	}
 }
+
+func TestInsertBeforeExistingBlocks(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+
+	_ = store.Insert(text, 0, "A")
+	_ = store.Insert(text, 0, "B")
+	_ = store.Insert(text, 1, "C")
+	_ = store.Insert(text, 0, "D")
+
+	if got := text.Content(); got != "DBCA" {
+		t.Errorf("expected DBCA, got %q", got)
+	}
+
+	_ = store.Delete(text, 1, 2)
+	_ = store.Insert(text, 1, "X")
+
+	if got := text.Content(); got != "DXA" {
+		t.Errorf("expected DXA, got %q", got)
+	}
+}
+
+func TestInsertAssignsIncreasingClocks(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+
+	_ = store.Insert(text, 0, "AAA")
+	_ = store.Insert(text, 3, "BB")
+	_ = store.Insert(text, 5, "C")
+
+	if got := store.GetState(store.CurrentClientID); got != 6 {
+		t.Errorf("expected state 6, got %d", got)
+	}
+
+	blocks := store.Blocks[store.CurrentClientID]
+	if last := blocks[len(blocks)-1]; last.ID.Clock != 5 || last.LeftOrigin.Clock != 4 {
+		t.Errorf("expected clock 5 with left origin 4, got %v", last)
+	}
+}
+
+func TestRootsAreIndependent(t *testing.T) {
+	store := NewStore()
+	title := store.Root("title")
+	body := store.Root("body")
+
+	_ = store.Insert(title, 0, "Title")
+	_ = store.Insert(body, 0, "Body")
+	_ = store.Insert(body, 0, ">")
+
+	if title.Content() != "Title" || body.Content() != ">Body" {
+		t.Errorf("unexpected contents %q, %q", title.Content(), body.Content())
+	}
+	if store.Root("title") != title {
+		t.Errorf("expected Root to return the existing type")
+	}
+}
+
+// remoteBlock integrates a block of another client into the type
+func remoteBlock(store *BlockStore, t *Type, client int64, content string) *block.Block {
+	blk := &block.Block{
+		ID:      block.ID{Client: client, Clock: store.GetState(client)},
+		Content: &block.ContentString{Str: content},
+		Parent:  block.Parent{Root: t.Name},
+	}
+	store.Integrate(blk, 0)
+	return blk
+}
+
+func TestGetMissingOriginAtState(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+	remoteBlock(store, text, 2, "abc")
+
+	// the state is the next expected clock, an origin at it isn't there yet
+	blk := &block.Block{ID: block.ID{Client: 3}, LeftOrigin: block.ID{Client: 2, Clock: 3}}
+	if missing := store.GetMissing(blk); missing == nil || *missing != 2 {
+		t.Errorf("expected client 2 to be missing, got %v", missing)
+	}
+
+	blk.LeftOrigin.Clock = 2
+	if missing := store.GetMissing(blk); missing != nil {
+		t.Errorf("expected nothing missing, got %v", *missing)
+	}
+}
+
+func TestDeleteKeepsContent(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+	_ = store.Insert(text, 0, "Hello")
+	_ = store.Delete(text, 1, 3)
+
+	// the tombstone still covers clocks 1 to 3
+	blk := store.Blocks[store.CurrentClientID][1]
+	assert.True(t, blk.IsDeleted)
+	assert.Equal(t, &block.ContentString{Str: "ell"}, blk.Content)
+	if text.Length != 2 {
+		t.Errorf("expected length 2, got %d", text.Length)
+	}
+}
+
+func TestDeleteRecordsBlockClient(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+	remoteBlock(store, text, 2, "abc")
+
+	_ = store.Delete(text, 0, 3)
+
+	assert.Equal(t, []block.DeleteRange{{StartClock: 0, DeleteLength: 3}}, store.DeleteSet[2])
+	assert.Empty(t, store.DeleteSet[store.CurrentClientID])
+}
+
+func TestGetItemCleanEndSplitsDeletedBlock(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+	blk := remoteBlock(store, text, 2, "abcd")
+	store.MarkDeleted(blk)
+
+	left := store.getItemCleanEnd(block.ID{Client: 2, Clock: 1})
+
+	assert.Same(t, blk, left)
+	assert.Equal(t, 2, left.Len())
+	if blocks := store.Blocks[2]; len(blocks) != 2 || blocks[1].ID.Clock != 2 || !blocks[1].IsDeleted {
+		t.Errorf("expected a deleted block at clock 2, got %v", blocks)
+	}
+}
+
+func TestRefinePreciseBlock(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+	blk := remoteBlock(store, text, 2, "abcd")
+	store.MarkDeleted(blk)
+
+	// a block starting at the clock is used as is
+	if got := store.refinePreciseBlock(block.ID{Client: 2, Clock: 0}); got != blk || len(store.Blocks[2]) != 1 {
+		t.Errorf("expected the block without a split, got %v", got)
+	}
+
+	// deleted blocks are split like any other
+	right := store.refinePreciseBlock(block.ID{Client: 2, Clock: 2})
+	if right.ID.Clock != 2 || right.Len() != 2 || !right.IsDeleted {
+		t.Errorf("expected deleted block of length 2 at clock 2, got %v", right)
+	}
+}
+
+func TestCleanupMergesSequentialInserts(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+
+	txn := store.Begin(true)
+	for i, s := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Insert(text, int64(i), s))
+	}
+	store.End()
+	store.Cleanup(txn)
+
+	assert.Len(t, store.Blocks[store.CurrentClientID], 1)
+	assert.Equal(t, "abc", text.Content())
+}
+
+func TestCleanupCollectsDeletedContent(t *testing.T) {
+	store := NewStore()
+	store.GC = true
+	text := store.Root("")
+	assert.NoError(t, store.Insert(text, 0, "hello"))
+
+	txn := store.Begin(true)
+	assert.NoError(t, store.Delete(text, 1, 3))
+	store.End()
+	store.Cleanup(txn)
+
+	assert.Equal(t, "ho", text.Content())
+	var collected int
+	for _, blk := range store.Blocks[store.CurrentClientID] {
+		if blk.IsDeleted {
+			assert.IsType(t, &block.ContentDeleted{}, blk.Content)
+			collected += blk.Len()
+		}
+	}
+	assert.Equal(t, 3, collected)
+}
+
+func TestCleanupKeepsDeletedContentByDefault(t *testing.T) {
+	store := NewStore()
+	text := store.Root("")
+	assert.NoError(t, store.Insert(text, 0, "hello"))
+
+	txn := store.Begin(true)
+	assert.NoError(t, store.Delete(text, 1, 3))
+	store.End()
+	store.Cleanup(txn)
+
+	assert.Equal(t, "ho", text.Content())
+	for _, blk := range store.Blocks[store.CurrentClientID] {
+		if blk.IsDeleted {
+			assert.Equal(t, &block.ContentString{Str: "ell"}, blk.Content)
+		}
+	}
+}
+
+// BenchmarkGetBlock looks up blocks of a client holding many of them,
+// every remote block resolves its origins this way
+func BenchmarkGetBlock(b *testing.B) {
+	store := NewStore()
+	text := store.Root("")
+	for range 10000 {
+		remoteBlock(store, text, 2, "a")
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		if store.getBlock(block.ID{Client: 2, Clock: int64(i % 10000)}) == nil {
+			b.Fatal("block not found")
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package blockstore
+
+import (
+	"sort"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+)
+
+// Transaction collects everything that changed while a batch of
+// operations ran against the store, so observers can be told about
+// it once the batch is done.
+type Transaction struct {
+	// Local is true for changes made through the local API
+	// and false for changes coming from ApplyUpdate
+	Local bool
+	// BeforeState is the state vector at the start of the transaction,
+	// blocks with a clock at or past it were added by the transaction
+	BeforeState map[int64]int64
+	// Deleted lists the clock ranges deleted by the transaction
+	Deleted map[int64][]block.DeleteRange
+	// Changed lists the types touched by the transaction along
+	// with the map keys that changed, "" stands for the sequence
+	Changed map[*Type]map[string]struct{}
+	// ChangedTypes lists the keys of Changed in the
+	// order the types were first changed in
+	ChangedTypes []*Type
+	// DocsAdded and DocsRemoved list the blocks holding subdocuments
+	// that were added or deleted by the transaction
+	DocsAdded   []*block.Block
+	DocsRemoved []*block.Block
+	// moved holds the Moved flag blocks had before the
+	// transaction, for the blocks ResolveMoves changed
+	moved map[*block.Block]bool
+}
+
+// Begin starts a transaction on the store. Until End is called every
+// integration and deletion is recorded in it.
+func (s *BlockStore) Begin(local bool) *Transaction {
+	before := make(map[int64]int64, len(s.StateVector))
+	for client, clock := range s.StateVector {
+		before[client] = clock
+	}
+
+	s.Txn = &Transaction{
+		Local:       local,
+		BeforeState: before,
+		Deleted:     make(map[int64][]block.DeleteRange),
+		Changed:     make(map[*Type]map[string]struct{}),
+	}
+
+	return s.Txn
+}
+
+// End finishes the running transaction and returns it
+func (s *BlockStore) End() *Transaction {
+	txn := s.Txn
+	s.Txn = nil
+	return txn
+}
+
+// Adds reports whether the block was created by the transaction
+func (txn *Transaction) Adds(blk *block.Block) bool {
+	return blk.ID.Clock >= txn.BeforeState[blk.ID.Client]
+}
+
+// WasMoved reports whether the block was moved away before the transaction
+func (txn *Transaction) WasMoved(blk *block.Block) bool {
+	if moved, ok := txn.moved[blk]; ok {
+		return moved
+	}
+	return blk.Moved
+}
+
+// setMoved changes the Moved flag of a block, remembering its old state
+func (txn *Transaction) setMoved(blk *block.Block, moved bool) {
+	if _, ok := txn.moved[blk]; !ok {
+		if txn.moved == nil {
+			txn.moved = make(map[*block.Block]bool)
+		}
+		txn.moved[blk] = blk.Moved
+	}
+	blk.Moved = moved
+}
+
+// splitMoved carries the old Moved flag of a split block over to its right half
+func (txn *Transaction) splitMoved(left, right *block.Block) {
+	if moved, ok := txn.moved[left]; ok {
+		txn.moved[right] = moved
+	}
+}
+
+// Deletes reports whether the block was deleted by the transaction
+func (txn *Transaction) Deletes(blk *block.Block) bool {
+	for _, r := range txn.Deleted[blk.ID.Client] {
+		if blk.ID.Clock >= r.StartClock && blk.ID.Clock < r.StartClock+r.DeleteLength {
+			return true
+		}
+	}
+	return false
+}
+
+func (txn *Transaction) addChanged(t *Type, key string) {
+	keys, ok := txn.Changed[t]
+	if !ok {
+		keys = make(map[string]struct{})
+		txn.Changed[t] = keys
+		txn.ChangedTypes = append(txn.ChangedTypes, t)
+	}
+	keys[key] = struct{}{}
+}
+
+func (txn *Transaction) addDeleted(blk *block.Block) {
+	txn.Deleted[blk.ID.Client] = append(txn.Deleted[blk.ID.Client], block.DeleteRange{
+		StartClock:   blk.ID.Clock,
+		DeleteLength: int64(blk.Len()),
+	})
+}
+
+// Cleanup runs once a transaction is over and its observers were called.
+// When GC is enabled the content of blocks deleted by the transaction is
+// dropped, then blocks touched by it are merged with their neighbors
+// wherever the two can be represented as a single block.
+func (s *BlockStore) Cleanup(txn *Transaction) {
+	if s.GC {
+		for client, ranges := range txn.Deleted {
+			for _, r := range ranges {
+				for _, blk := range s.GetBlocksInRange(client, r.StartClock, r.DeleteLength) {
+					s.gc(blk)
+				}
+			}
+		}
+	}
+
+	// the smallest clock of every client the transaction touched,
+	// everything before it can't have become mergeable
+	from := make(map[int64]int64)
+	for client, clock := range s.StateVector {
+		if before, ok := txn.BeforeState[client]; !ok || before < clock {
+			from[client] = before
+		}
+	}
+	for client, ranges := range txn.Deleted {
+		for _, r := range ranges {
+			if clock, ok := from[client]; !ok || r.StartClock < clock {
+				from[client] = r.StartClock
+			}
+		}
+	}
+
+	for client, clock := range from {
+		s.mergeBlocks(client, clock)
+	}
+}
+
+// gc replaces the content of a deleted block with a tombstone.
+// Nested types are kept since their blocks still refer to them.
+func (s *BlockStore) gc(blk *block.Block) {
+	if !blk.IsDeleted {
+		return
+	}
+
+	switch blk.Content.(type) {
+	case *block.ContentDeleted, *block.ContentType:
+		return
+	}
+	blk.Content = &block.ContentDeleted{Length: blk.Len()}
+}
+
+// mergeBlocks merges the blocks of client from the block containing
+// `clock` onwards, each with its predecessor if possible
+func (s *BlockStore) mergeBlocks(client, clock int64) {
+	blocks := s.Blocks[client]
+
+	i := sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].ID.Clock+int64(blocks[i].Len()) > clock
+	})
+	if i == 0 {
+		i = 1
+	}
+
+	for i < len(blocks) {
+		if s.tryMerge(blocks[i-1], blocks[i]) {
+			blocks = append(blocks[:i], blocks[i+1:]...)
+			continue
+		}
+		i++
+	}
+
+	s.Blocks[client] = blocks
+}
+
+// tryMerge appends `right` to `left` if right continues left in every
+// way: same client, consecutive clocks, typed right after left and
+// both still next to each other and equally deleted.
+func (s *BlockStore) tryMerge(left, right *block.Block) bool {
+	if left.Right != right ||
+		left.ID.Client != right.ID.Client ||
+		left.ID.Clock+int64(left.Len()) != right.ID.Clock ||
+		right.LeftOrigin != lastID(left) ||
+		right.RightOrigin != left.RightOrigin ||
+		left.IsDeleted != right.IsDeleted ||
+		left.Moved != right.Moved ||
+		!left.Parent.Equal(right.Parent) ||
+		// the map keeps pointers to the blocks of its keys
+		left.ParentSub != "" || right.ParentSub != "" {
+		return false
+	}
+
+	if !left.Content.Merge(right.Content) {
+		return false
+	}
+
+	left.Right = right.Right
+	if left.Right != nil {
+		left.Right.Left = left
+	}
+
+	// a marker on `right` would point to a block that's gone,
+	// blocks of a type that's gone have no markers
+	if t := s.typeOf(right); t != nil {
+		t.MarkerSystem.DeleteMarkersOfBlock(right)
+	}
+
+	return true
+}
@@ -15,5 +15,56 @@ func DecodeUpdate(update []byte) (*block.Updates, error) {
 		return nil, fmt.Errorf("decode updates: %w", err)
 	}
 
-	return remoteUpdates, nil
+	return remoteUpdates, validate(remoteUpdates)
+}
+
+// DecodeUpdateV1 decodes an update in the yjs v1 format
+func DecodeUpdateV1(update []byte) (*block.Updates, error) {
+	remoteUpdates := &block.Updates{}
+
+	if err := remoteUpdates.UnmarshalV1(update); err != nil {
+		return nil, fmt.Errorf("decode updates: %w", err)
+	}
+
+	return remoteUpdates, validate(remoteUpdates)
+}
+
+// DecodeUpdateV2 decodes an update in the yjs v2 format
+func DecodeUpdateV2(update []byte) (*block.Updates, error) {
+	remoteUpdates := &block.Updates{}
+
+	if err := remoteUpdates.UnmarshalV2(update); err != nil {
+		return nil, fmt.Errorf("decode updates: %w", err)
+	}
+
+	return remoteUpdates, validate(remoteUpdates)
+}
+
+// validate rejects blocks no document can have produced. A type exists
+// before anything is inserted into it and the neighbors of a block exist
+// before it's inserted, so the type and the origins of a block that are
+// blocks of its own client come before it.
+func validate(u *block.Updates) error {
+	for client, blocks := range u.Updates.Updates {
+		for _, blk := range blocks {
+			if blk == nil {
+				return fmt.Errorf("%w: missing block of client %d", block.ErrInvalidUpdate, client)
+			}
+			if p := blk.Parent.ID; p != nil && !before(*p, blk.ID) {
+				return fmt.Errorf("%w: block %v is nested in %v", block.ErrInvalidUpdate, blk.ID, *p)
+			}
+			for _, origin := range []block.ID{blk.LeftOrigin, blk.RightOrigin} {
+				if origin != (block.ID{}) && !before(origin, blk.ID) {
+					return fmt.Errorf("%w: block %v has the origin %v", block.ErrInvalidUpdate, blk.ID, origin)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// before reports whether id can have existed when the block `blk` was
+// created, it's true for the blocks of every other client
+func before(id, blk block.ID) bool {
+	return id.Client != blk.Client || id.Clock < blk.Clock
 }
@@ -0,0 +1,66 @@
+package decoder
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzDecodeUpdate decodes arbitrary input, what decodes has to encode
+// to an update that decodes the same way
+func FuzzDecodeUpdate(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		u, err := DecodeUpdate(data)
+		if err != nil {
+			return
+		}
+
+		encoded, err := json.Marshal(u)
+		require.NoError(t, err)
+		decoded, err := DecodeUpdate(encoded)
+		require.NoError(t, err)
+		again, err := json.Marshal(decoded)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(encoded), string(again))
+	})
+}
+
+// FuzzDecodeUpdateV1 decodes arbitrary input as a yjs v1 update, what
+// decodes has to encode to an update that decodes the same way
+func FuzzDecodeUpdateV1(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		u, err := DecodeUpdateV1(data)
+		if err != nil {
+			return
+		}
+
+		encoded, err := u.MarshalV1()
+		require.NoError(t, err)
+		decoded, err := DecodeUpdateV1(encoded)
+		require.NoError(t, err)
+		again, err := decoded.MarshalV1()
+		require.NoError(t, err)
+		assert.Equal(t, encoded, again)
+	})
+}
+
+// FuzzDecodeUpdateV2 decodes arbitrary input as a yjs v2 update, what
+// decodes has to encode to an update that decodes the same way
+func FuzzDecodeUpdateV2(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		u, err := DecodeUpdateV2(data)
+		if err != nil {
+			return
+		}
+
+		encoded, err := u.MarshalV2()
+		require.NoError(t, err)
+		decoded, err := DecodeUpdateV2(encoded)
+		require.NoError(t, err)
+		again, err := decoded.MarshalV2()
+		require.NoError(t, err)
+		assert.Equal(t, encoded, again)
+	})
+}
@@ -0,0 +1,241 @@
+// Package diff computes the shortest edit script between two sequences
+// with the algorithm of Eugene W. Myers, "An O(ND) Difference Algorithm
+// and Its Variations" (1986).
+package diff
+
+// Op is the kind of an edit
+type Op int
+
+const (
+	// Equal keeps elements that are in both sequences
+	Equal Op = iota
+	// Delete removes elements of the old sequence
+	Delete
+	// Insert adds elements of the new sequence
+	Insert
+)
+
+// Edit is a run of N elements sharing the same Op
+type Edit struct {
+	Op Op
+	N  int
+}
+
+// maxCost bounds the number of steps the search for a middle snake
+// takes in each direction. Parts of the sequences needing more edits
+// than that are split at the furthest point either search reached,
+// like the too_expensive heuristic of GNU diff, which keeps the time
+// spent on very different sequences bounded.
+const maxCost = 1 << 10
+
+// Diff returns the edits turning a into b with as few inserted and
+// deleted elements as possible. Only when parts of them need more than
+// 2*maxCost edits the script may be a little longer than the shortest
+// one. Applying the edits walks both sequences from the start: Equal and
+// Delete consume elements of a, Equal and Insert consume elements of b.
+// Neighboring edits have different ops.
+func Diff[T comparable](a, b []T) []Edit {
+	// the common prefix and suffix don't need the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	d := newDiffer(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	d.compare(0, len(d.ai), 0, len(d.bi))
+
+	var edits []Edit
+	add := func(op Op, n int) {
+		if n == 0 {
+			return
+		}
+		if last := len(edits) - 1; last >= 0 && edits[last].Op == op {
+			edits[last].N += n
+			return
+		}
+		edits = append(edits, Edit{Op: op, N: n})
+	}
+
+	add(Equal, prefix)
+	// the elements left unchanged on both sides pair up in order
+	for i, j := 0, 0; i < len(d.deleted) || j < len(d.inserted); {
+		switch {
+		case i < len(d.deleted) && d.deleted[i]:
+			add(Delete, 1)
+			i++
+		case j < len(d.inserted) && d.inserted[j]:
+			add(Insert, 1)
+			j++
+		default:
+			add(Equal, 1)
+			i, j = i+1, j+1
+		}
+	}
+	add(Equal, suffix)
+	return edits
+}
+
+// differ runs the linear space variant of the algorithm, it splits the
+// sequences at the middle snake of an edit script and recurses on both
+// halves. The furthest reaching paths are only kept for the current step.
+// Like GNU diff it marks the elements that change instead of collecting
+// edits, elements without a match in the other sequence are marked
+// upfront and left out of the search.
+type differ[T comparable] struct {
+	a, b []T
+	// ai and bi are the indices of the elements taking part in the search
+	ai, bi            []int
+	deleted, inserted []bool
+	vf, vb            []int
+}
+
+func newDiffer[T comparable](a, b []T) *differ[T] {
+	d := &differ[T]{
+		a:        a,
+		b:        b,
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+	}
+
+	inA := make(map[T]struct{}, len(a))
+	for _, v := range a {
+		inA[v] = struct{}{}
+	}
+	inB := make(map[T]struct{}, len(b))
+	for _, v := range b {
+		inB[v] = struct{}{}
+	}
+	for i, v := range a {
+		if _, ok := inB[v]; ok {
+			d.ai = append(d.ai, i)
+		} else {
+			d.deleted[i] = true
+		}
+	}
+	for j, v := range b {
+		if _, ok := inA[v]; ok {
+			d.bi = append(d.bi, j)
+		} else {
+			d.inserted[j] = true
+		}
+	}
+	return d
+}
+
+// equal reports whether the x-th element of a and the y-th element
+// of b taking part in the search are the same
+func (d *differ[T]) equal(x, y int) bool {
+	return d.a[d.ai[x]] == d.b[d.bi[y]]
+}
+
+// compare marks the changes turning the elements [xLo, xHi) of a
+// taking part in the search into the elements [yLo, yHi) of b
+func (d *differ[T]) compare(xLo, xHi, yLo, yHi int) {
+	for xLo < xHi && yLo < yHi && d.equal(xLo, yLo) {
+		xLo, yLo = xLo+1, yLo+1
+	}
+	for xHi > xLo && yHi > yLo && d.equal(xHi-1, yHi-1) {
+		xHi, yHi = xHi-1, yHi-1
+	}
+
+	// with one side empty, or a single edit which never survives
+	// stripping the prefix and suffix, there's nothing to search
+	if xLo == xHi || yLo == yHi {
+		for x := xLo; x < xHi; x++ {
+			d.deleted[d.ai[x]] = true
+		}
+		for y := yLo; y < yHi; y++ {
+			d.inserted[d.bi[y]] = true
+		}
+		return
+	}
+
+	x, y, u, v := d.middleSnake(xLo, xHi, yLo, yHi)
+	d.compare(xLo, x, yLo, y)
+	d.compare(u, xHi, v, yHi)
+}
+
+// middleSnake returns the snake from (x, y) to (u, v) in the middle of a
+// shortest edit script of [xLo, xHi) into [yLo, yHi), searching forward
+// from the start and backward from the end at the same time. Once either
+// search took more than maxCost steps it gives up and returns the point
+// furthest from its start either of them reached, as an empty snake.
+// The point is never a corner, both halves are smaller than the whole.
+func (d *differ[T]) middleSnake(xLo, xHi, yLo, yHi int) (x, y, u, v int) {
+	n, m := xHi-xLo, yHi-yLo
+	delta := n - m
+	limit := min((n+m+1)/2, maxCost)
+
+	// vf[off+k] is the furthest x on diagonal k searching forward, vb[off+c]
+	// the furthest distance from the end on diagonal c searching backward.
+	// Diagonal k forward is diagonal delta-k backward.
+	off := limit + 1
+	if size := 2*off + 1; len(d.vf) < size {
+		d.vf, d.vb = make([]int, size), make([]int, size)
+	}
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+
+	// the furthest points reached in the last step of either search
+	var fBest, fx, fy, bBest, bx, by int
+	for step := 0; step <= limit; step++ {
+		fBest = -1
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && d.equal(xLo+x, yLo+y) {
+				x++
+				y++
+			}
+			vf[off+k] = x
+
+			// an odd delta meets the backward paths of the previous step
+			if c := delta - k; delta%2 != 0 && -step < c && c < step && x+vb[off+c] >= n {
+				return xLo + sx, yLo + sy, xLo + x, yLo + y
+			}
+			if x <= n && y <= m && x+y > fBest {
+				fBest, fx, fy = x+y, x, y
+			}
+		}
+
+		bBest = -1
+		for c := -step; c <= step; c += 2 {
+			var x int
+			if c == -step || (c != step && vb[off+c-1] < vb[off+c+1]) {
+				x = vb[off+c+1]
+			} else {
+				x = vb[off+c-1] + 1
+			}
+			y := x - c
+			sx, sy := x, y
+			for x < n && y < m && d.equal(xHi-1-x, yHi-1-y) {
+				x++
+				y++
+			}
+			vb[off+c] = x
+
+			// an even delta meets the forward paths of the same step
+			if k := delta - c; delta%2 == 0 && -step <= k && k <= step && x+vf[off+k] >= n {
+				return xHi - x, yHi - y, xHi - sx, yHi - sy
+			}
+			if x <= n && y <= m && x+y > bBest {
+				bBest, bx, by = x+y, x, y
+			}
+		}
+	}
+
+	if fBest >= bBest {
+		return xLo + fx, yLo + fy, xLo + fx, yLo + fy
+	}
+	return xHi - bx, yHi - by, xHi - bx, yHi - by
+}
@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apply turns a into b following the edits
+func apply(a, b []rune, edits []Edit) []rune {
+	var out []rune
+	i, j := 0, 0
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			out = append(out, a[i:i+e.N]...)
+			i, j = i+e.N, j+e.N
+		case Delete:
+			i += e.N
+		case Insert:
+			out = append(out, b[j:j+e.N]...)
+			j += e.N
+		}
+	}
+	return out
+}
+
+// changed counts the inserted and deleted elements
+func changed(edits []Edit) int {
+	n := 0
+	for _, e := range edits {
+		if e.Op != Equal {
+			n += e.N
+		}
+	}
+	return n
+}
+
+func TestDiff_Minimal(t *testing.T) {
+	// the example of the paper, the shortest edit script has 5 edits
+	a, b := []rune("ABCABBA"), []rune("CBABAC")
+	edits := Diff(a, b)
+	assert.Equal(t, string(b), string(apply(a, b, edits)))
+	assert.Equal(t, 5, changed(edits))
+
+	assert.Equal(t, []Edit{{Op: Equal, N: 6}, {Op: Insert, N: 6}}, Diff([]rune("Hello "), []rune("Hello World!")))
+	assert.Equal(t, []Edit{{Op: Delete, N: 3}}, Diff([]rune("abc"), nil))
+	assert.Empty(t, Diff[rune](nil, nil))
+}
+
+func TestDiff_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []rune {
+		s := make([]rune, rng.Intn(40))
+		for i := range s {
+			s[i] = rune('a' + rng.Intn(3))
+		}
+		return s
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		edits := Diff(a, b)
+		require.Equal(t, string(b), string(apply(a, b, edits)), "%q -> %q", string(a), string(b))
+
+		// no edit script is shorter than the one over the longest common subsequence
+		assert.Equal(t, len(a)+len(b)-2*lcs(a, b), changed(edits))
+	}
+}
+
+// lcs returns the length of the longest common subsequence
+func lcs(a, b []rune) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else {
+				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
+
+func TestDiff_Large(t *testing.T) {
+	// nothing in common, the search gives up and replaces everything
+	a, b := make([]rune, 1_000_000), make([]rune, 1_000_000)
+	for i := range a {
+		a[i], b[i] = 'a', 'b'
+	}
+	assert.Equal(t, []Edit{{Op: Delete, N: len(a)}, {Op: Insert, N: len(b)}}, Diff(a, b))
+
+	// a few edits in long sequences are still found exactly
+	rng := rand.New(rand.NewSource(1))
+	for i := range a {
+		a[i] = rune('a' + rng.Intn(26))
+	}
+	b = append([]rune(nil), a...)
+	for i := 0; i < 10; i++ {
+		b[rng.Intn(len(b))] = '!'
+	}
+	edits := Diff(a, b)
+	require.Equal(t, string(b), string(apply(a, b, edits)))
+	assert.Equal(t, 20, changed(edits))
+}
+
+func TestDiff_ScatteredEdits(t *testing.T) {
+	// more edits than the search takes steps, the text around them is kept
+	rng := rand.New(rand.NewSource(1))
+	a := make([]rune, 20000)
+	for i := range a {
+		a[i] = rune('a' + rng.Intn(26))
+	}
+	b := append([]rune(nil), a...)
+	for i := 0; i < 1500; i++ {
+		pos := rng.Intn(len(b))
+		b[pos] = 'a' + (b[pos]-'a'+1+rune(rng.Intn(25)))%26
+	}
+
+	edits := Diff(a, b)
+	require.Equal(t, string(b), string(apply(a, b, edits)))
+	assert.Less(t, changed(edits), 2*1600)
+}
@@ -0,0 +1,407 @@
+// Package encoding implements the binary primitives of lib0, the
+// encoding library used by yjs, so blocks can be written in the
+// same format yjs reads and writes.
+package encoding
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	ErrUnexpectedEOF = errors.New("unexpected end of data")
+	ErrOverflow      = errors.New("varint overflows 64 bits")
+	ErrUnknownAny    = errors.New("unknown any type")
+)
+
+// type tags of lib0 writeAny
+const (
+	anyUndefined = 127
+	anyNull      = 126
+	anyInt       = 125
+	anyFloat32   = 124
+	anyFloat64   = 123
+	anyBigInt    = 122
+	anyFalse     = 121
+	anyTrue      = 120
+	anyString    = 119
+	anyObject    = 118
+	anyArray     = 117
+	anyBytes     = 116
+)
+
+// bits31 is the largest integer lib0 writes as a varint in writeAny
+const bits31 = 0x7FFFFFFF
+
+// Encoder accumulates encoded values in memory
+type Encoder struct {
+	buf []byte
+}
+
+// NewEncoder creates an empty encoder
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Bytes returns everything written so far
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// WriteUint8 writes a single byte
+func (e *Encoder) WriteUint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+// WriteVarUint writes an unsigned integer using 7 bits per byte
+func (e *Encoder) WriteVarUint(v uint64) {
+	for v > 0x7f {
+		e.buf = append(e.buf, 0x80|byte(v&0x7f))
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+// WriteVarInt writes a signed integer, the first byte carries
+// the sign in its second highest bit and 6 bits of the value
+func (e *Encoder) WriteVarInt(v int64) {
+	if v < 0 {
+		e.writeVarInt(uint64(-v), true)
+	} else {
+		e.writeVarInt(uint64(v), false)
+	}
+}
+
+// writeVarInt writes the absolute value u with the sign, unlike
+// WriteVarInt it can write the negative zero lib0 uses as a flag
+func (e *Encoder) writeVarInt(u uint64, negative bool) {
+	var sign byte
+	if negative {
+		sign = 0x40
+	}
+
+	first := sign | byte(u&0x3f)
+	u >>= 6
+	if u > 0 {
+		first |= 0x80
+	}
+	e.buf = append(e.buf, first)
+
+	for u > 0 {
+		b := byte(u & 0x7f)
+		u >>= 7
+		if u > 0 {
+			b |= 0x80
+		}
+		e.buf = append(e.buf, b)
+	}
+}
+
+// WriteVarString writes the UTF-8 bytes of s prefixed with their length
+func (e *Encoder) WriteVarString(s string) {
+	e.WriteVarUint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// WriteVarBytes writes b prefixed with its length
+func (e *Encoder) WriteVarBytes(b []byte) {
+	e.WriteVarUint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// WriteFloat32 writes a big endian float32
+func (e *Encoder) WriteFloat32(v float32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(v))
+}
+
+// WriteFloat64 writes a big endian float64
+func (e *Encoder) WriteFloat64(v float64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// WriteAny writes a JSON-like value the way lib0 writeAny does.
+// Objects are written with sorted keys so the output is deterministic.
+func (e *Encoder) WriteAny(v any) error {
+	switch v := v.(type) {
+	case nil:
+		e.WriteUint8(anyNull)
+	case bool:
+		if v {
+			e.WriteUint8(anyTrue)
+		} else {
+			e.WriteUint8(anyFalse)
+		}
+	case string:
+		e.WriteUint8(anyString)
+		e.WriteVarString(v)
+	case int:
+		e.writeInt(int64(v))
+	case int64:
+		e.writeInt(v)
+	case float32:
+		e.writeNumber(float64(v))
+	case float64:
+		e.writeNumber(v)
+	case []byte:
+		e.WriteUint8(anyBytes)
+		e.WriteVarBytes(v)
+	case []any:
+		e.WriteUint8(anyArray)
+		e.WriteVarUint(uint64(len(v)))
+		for _, item := range v {
+			if err := e.WriteAny(item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		e.WriteUint8(anyObject)
+		e.WriteVarUint(uint64(len(keys)))
+		for _, key := range keys {
+			e.WriteVarString(key)
+			if err := e.WriteAny(v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("write any: unsupported type %T", v)
+	}
+	return nil
+}
+
+// writeInt writes integers too large for a varint as a lib0 BigInt,
+// a float64 would lose their precision past 2^53
+func (e *Encoder) writeInt(v int64) {
+	if v < -bits31 || v > bits31 {
+		e.WriteUint8(anyBigInt)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+		return
+	}
+	e.WriteUint8(anyInt)
+	e.WriteVarInt(v)
+}
+
+func (e *Encoder) writeNumber(v float64) {
+	switch {
+	case v == math.Trunc(v) && math.Abs(v) <= bits31:
+		e.WriteUint8(anyInt)
+		e.WriteVarInt(int64(v))
+	case float64(float32(v)) == v:
+		e.WriteUint8(anyFloat32)
+		e.WriteFloat32(float32(v))
+	default:
+		e.WriteUint8(anyFloat64)
+		e.WriteFloat64(v)
+	}
+}
+
+// Decoder reads values written by an Encoder
+type Decoder struct {
+	buf []byte
+	pos int
+}
+
+// NewDecoder creates a decoder reading from b
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Remaining returns the number of unread bytes
+func (d *Decoder) Remaining() int {
+	return len(d.buf) - d.pos
+}
+
+// ReadUint8 reads a single byte
+func (d *Decoder) ReadUint8() (uint8, error) {
+	if d.pos >= len(d.buf) {
+		return 0, ErrUnexpectedEOF
+	}
+	v := d.buf[d.pos]
+	d.pos++
+	return v, nil
+}
+
+// ReadVarUint reads an unsigned integer written by WriteVarUint
+func (d *Decoder) ReadVarUint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := d.ReadUint8()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= 64 || (shift == 63 && b > 1) {
+			return 0, ErrOverflow
+		}
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+}
+
+// ReadVarInt reads a signed integer written by WriteVarInt
+func (d *Decoder) ReadVarInt() (int64, error) {
+	v, negative, err := d.readVarInt()
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+// readVarInt reads the absolute value and the sign of a signed integer,
+// it tells the negative zero apart from zero
+func (d *Decoder) readVarInt() (uint64, bool, error) {
+	first, err := d.ReadUint8()
+	if err != nil {
+		return 0, false, err
+	}
+
+	v := uint64(first & 0x3f)
+	negative := first&0x40 != 0
+	if first&0x80 != 0 {
+		for shift := uint(6); ; shift += 7 {
+			b, err := d.ReadUint8()
+			if err != nil {
+				return 0, false, err
+			}
+			if shift >= 63 {
+				return 0, false, ErrOverflow
+			}
+			v |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+	}
+	return v, negative, nil
+}
+
+// ReadVarBytes reads bytes written by WriteVarBytes. The returned
+// slice is a copy, it does not alias the decoded data.
+func (d *Decoder) ReadVarBytes() ([]byte, error) {
+	n, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(d.Remaining()) {
+		return nil, ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	copy(b, d.buf[d.pos:])
+	d.pos += int(n)
+	return b, nil
+}
+
+// ReadVarString reads a string written by WriteVarString
+func (d *Decoder) ReadVarString() (string, error) {
+	b, err := d.ReadVarBytes()
+	return string(b), err
+}
+
+// ReadFloat32 reads a big endian float32
+func (d *Decoder) ReadFloat32() (float32, error) {
+	if d.Remaining() < 4 {
+		return 0, ErrUnexpectedEOF
+	}
+	v := math.Float32frombits(binary.BigEndian.Uint32(d.buf[d.pos:]))
+	d.pos += 4
+	return v, nil
+}
+
+// ReadFloat64 reads a big endian float64
+func (d *Decoder) ReadFloat64() (float64, error) {
+	if d.Remaining() < 8 {
+		return 0, ErrUnexpectedEOF
+	}
+	v := math.Float64frombits(binary.BigEndian.Uint64(d.buf[d.pos:]))
+	d.pos += 8
+	return v, nil
+}
+
+// ReadAny reads a value written by WriteAny. Numbers are returned as
+// float64 and undefined as nil, just like encoding/json would decode
+// them. BigInts are returned as int64 so they keep their precision.
+func (d *Decoder) ReadAny() (any, error) {
+	tag, err := d.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case anyUndefined, anyNull:
+		return nil, nil
+	case anyInt:
+		v, err := d.ReadVarInt()
+		return float64(v), err
+	case anyFloat32:
+		v, err := d.ReadFloat32()
+		return float64(v), err
+	case anyFloat64:
+		return d.ReadFloat64()
+	case anyBigInt:
+		if d.Remaining() < 8 {
+			return nil, ErrUnexpectedEOF
+		}
+		v := int64(binary.BigEndian.Uint64(d.buf[d.pos:]))
+		d.pos += 8
+		return v, nil
+	case anyFalse:
+		return false, nil
+	case anyTrue:
+		return true, nil
+	case anyString:
+		return d.ReadVarString()
+	case anyBytes:
+		return d.ReadVarBytes()
+	case anyArray:
+		n, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		// every item takes at least a byte, don't trust n any further
+		if n > uint64(d.Remaining()) {
+			return nil, ErrUnexpectedEOF
+		}
+		arr := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			item, err := d.ReadAny()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+	case anyObject:
+		n, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(d.Remaining()) {
+			return nil, ErrUnexpectedEOF
+		}
+		obj := make(map[string]any, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.ReadVarString()
+			if err != nil {
+				return nil, err
+			}
+			if obj[key], err = d.ReadAny(); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnknownAny, tag)
+	}
+}
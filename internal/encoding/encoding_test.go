@@ -0,0 +1,89 @@
+package encoding
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarUint_RoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxUint64} {
+		enc := NewEncoder()
+		enc.WriteVarUint(v)
+
+		got, err := NewDecoder(enc.Bytes()).ReadVarUint()
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestVarUint_MatchesLib0(t *testing.T) {
+	enc := NewEncoder()
+	enc.WriteVarUint(300)
+	assert.Equal(t, []byte{0xac, 0x02}, enc.Bytes())
+}
+
+func TestVarInt_RoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 63, 64, -64, 1 << 40, -(1 << 40)} {
+		enc := NewEncoder()
+		enc.WriteVarInt(v)
+
+		got, err := NewDecoder(enc.Bytes()).ReadVarInt()
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestAny_RoundTrip(t *testing.T) {
+	values := []any{
+		nil, true, false, "hello", 42.0, -7.0, 1.5, 0.1,
+		[]byte{1, 2, 3},
+		[]any{"a", 1.0, []any{}},
+		map[string]any{"b": 1.0, "a": map[string]any{"c": nil}},
+	}
+
+	for _, v := range values {
+		enc := NewEncoder()
+		require.NoError(t, enc.WriteAny(v))
+
+		dec := NewDecoder(enc.Bytes())
+		got, err := dec.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+		assert.Zero(t, dec.Remaining())
+	}
+}
+
+func TestAny_IntegersAsVarInt(t *testing.T) {
+	enc := NewEncoder()
+	require.NoError(t, enc.WriteAny(5))
+	assert.Equal(t, []byte{anyInt, 5}, enc.Bytes())
+
+	// past 31 bits they are BigInts, read back without losing precision
+	for _, v := range []int64{1<<53 + 1, -1 << 62, 1<<31 + 7} {
+		enc := NewEncoder()
+		require.NoError(t, enc.WriteAny(v))
+		assert.Equal(t, byte(anyBigInt), enc.Bytes()[0])
+		got, err := NewDecoder(enc.Bytes()).ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	enc := NewEncoder()
+	enc.WriteVarString("hello")
+
+	_, err := NewDecoder(enc.Bytes()[:3]).ReadVarString()
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+
+	_, err = NewDecoder([]byte{0x80}).ReadVarUint()
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+}
+
+func TestDecoder_UnknownAny(t *testing.T) {
+	_, err := NewDecoder([]byte{1}).ReadAny()
+	assert.ErrorIs(t, err, ErrUnknownAny)
+}
@@ -0,0 +1,303 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// The column encoders of lib0 used by the yjs v2 update format. Each of
+// them writes a sequence of values of one kind, runs of repeated values
+// or of values growing by the same difference are written only once.
+
+// RleEncoder writes bytes, every run of the same byte as the byte
+// followed by the number of repetitions. The length of the last run
+// is left out, the decoder repeats the last byte forever.
+type RleEncoder struct {
+	enc   Encoder
+	s     uint8
+	count uint64
+}
+
+// NewRleEncoder creates an empty RleEncoder
+func NewRleEncoder() *RleEncoder {
+	return &RleEncoder{}
+}
+
+// Write appends v to the sequence
+func (e *RleEncoder) Write(v uint8) {
+	if e.count > 0 && e.s == v {
+		e.count++
+		return
+	}
+	if e.count > 0 {
+		e.enc.WriteVarUint(e.count - 1)
+	}
+	e.count = 1
+	e.enc.WriteUint8(v)
+	e.s = v
+}
+
+// Bytes returns the encoded sequence
+func (e *RleEncoder) Bytes() []byte {
+	return e.enc.Bytes()
+}
+
+// RleDecoder reads bytes written by RleEncoder
+type RleDecoder struct {
+	dec   *Decoder
+	s     uint8
+	count uint64
+	// last is set once the last run was reached
+	last bool
+}
+
+// NewRleDecoder creates a decoder reading from b
+func NewRleDecoder(b []byte) *RleDecoder {
+	return &RleDecoder{dec: NewDecoder(b)}
+}
+
+// Read reads the next byte
+func (d *RleDecoder) Read() (uint8, error) {
+	if d.count == 0 && !d.last {
+		var err error
+		if d.s, err = d.dec.ReadUint8(); err != nil {
+			return 0, err
+		}
+		if d.dec.Remaining() == 0 {
+			d.last = true
+		} else {
+			n, err := d.dec.ReadVarUint()
+			if err != nil {
+				return 0, err
+			}
+			d.count = n + 1
+		}
+	}
+	if !d.last {
+		d.count--
+	}
+	return d.s, nil
+}
+
+// UintOptRleEncoder writes unsigned integers. A value that isn't
+// repeated is written as is, a run is written negated followed by
+// the number of repetitions minus two.
+type UintOptRleEncoder struct {
+	enc   Encoder
+	s     uint64
+	count uint64
+}
+
+// NewUintOptRleEncoder creates an empty UintOptRleEncoder
+func NewUintOptRleEncoder() *UintOptRleEncoder {
+	return &UintOptRleEncoder{}
+}
+
+// Write appends v to the sequence
+func (e *UintOptRleEncoder) Write(v uint64) {
+	if e.count > 0 && e.s == v {
+		e.count++
+		return
+	}
+	e.flush()
+	e.count = 1
+	e.s = v
+}
+
+func (e *UintOptRleEncoder) flush() {
+	if e.count == 0 {
+		return
+	}
+	// a run of zeros is written as negative zero
+	e.enc.writeVarInt(e.s, e.count > 1)
+	if e.count > 1 {
+		e.enc.WriteVarUint(e.count - 2)
+	}
+	e.count = 0
+}
+
+// Bytes returns the encoded sequence
+func (e *UintOptRleEncoder) Bytes() []byte {
+	e.flush()
+	return e.enc.Bytes()
+}
+
+// UintOptRleDecoder reads unsigned integers written by UintOptRleEncoder
+type UintOptRleDecoder struct {
+	dec   *Decoder
+	s     uint64
+	count uint64
+}
+
+// NewUintOptRleDecoder creates a decoder reading from b
+func NewUintOptRleDecoder(b []byte) *UintOptRleDecoder {
+	return &UintOptRleDecoder{dec: NewDecoder(b)}
+}
+
+// Read reads the next integer
+func (d *UintOptRleDecoder) Read() (uint64, error) {
+	if d.count == 0 {
+		v, run, err := d.dec.readVarInt()
+		if err != nil {
+			return 0, err
+		}
+		d.s, d.count = v, 1
+		if run {
+			n, err := d.dec.ReadVarUint()
+			if err != nil {
+				return 0, err
+			}
+			if n > 1<<62 {
+				return 0, ErrOverflow
+			}
+			d.count = n + 2
+		}
+	}
+	d.count--
+	return d.s, nil
+}
+
+// IntDiffOptRleEncoder writes integers as the difference to the value
+// before. The difference is shifted left by one, the lowest bit says
+// whether the number of values sharing it follows.
+type IntDiffOptRleEncoder struct {
+	enc   Encoder
+	s     int64
+	diff  int64
+	count uint64
+}
+
+// NewIntDiffOptRleEncoder creates an empty IntDiffOptRleEncoder
+func NewIntDiffOptRleEncoder() *IntDiffOptRleEncoder {
+	return &IntDiffOptRleEncoder{}
+}
+
+// Write appends v to the sequence
+func (e *IntDiffOptRleEncoder) Write(v int64) {
+	if e.count > 0 && e.diff == v-e.s {
+		e.s = v
+		e.count++
+		return
+	}
+	e.flush()
+	e.count = 1
+	e.diff = v - e.s
+	e.s = v
+}
+
+func (e *IntDiffOptRleEncoder) flush() {
+	if e.count == 0 {
+		return
+	}
+	encoded := e.diff * 2
+	if e.count > 1 {
+		encoded++
+	}
+	e.enc.WriteVarInt(encoded)
+	if e.count > 1 {
+		e.enc.WriteVarUint(e.count - 2)
+	}
+	e.count = 0
+}
+
+// Bytes returns the encoded sequence
+func (e *IntDiffOptRleEncoder) Bytes() []byte {
+	e.flush()
+	return e.enc.Bytes()
+}
+
+// IntDiffOptRleDecoder reads integers written by IntDiffOptRleEncoder
+type IntDiffOptRleDecoder struct {
+	dec   *Decoder
+	s     int64
+	diff  int64
+	count uint64
+}
+
+// NewIntDiffOptRleDecoder creates a decoder reading from b
+func NewIntDiffOptRleDecoder(b []byte) *IntDiffOptRleDecoder {
+	return &IntDiffOptRleDecoder{dec: NewDecoder(b)}
+}
+
+// Read reads the next integer
+func (d *IntDiffOptRleDecoder) Read() (int64, error) {
+	if d.count == 0 {
+		encoded, err := d.dec.ReadVarInt()
+		if err != nil {
+			return 0, err
+		}
+		// the arithmetic shift rounds down like lib0 does
+		d.diff, d.count = encoded>>1, 1
+		if encoded&1 != 0 {
+			n, err := d.dec.ReadVarUint()
+			if err != nil {
+				return 0, err
+			}
+			if n > 1<<62 {
+				return 0, ErrOverflow
+			}
+			d.count = n + 2
+		}
+	}
+	d.s += d.diff
+	d.count--
+	return d.s, nil
+}
+
+// StringEncoder writes strings as a single string holding all of them
+// followed by their lengths in UTF-16 code units
+type StringEncoder struct {
+	s    strings.Builder
+	lens UintOptRleEncoder
+}
+
+// NewStringEncoder creates an empty StringEncoder
+func NewStringEncoder() *StringEncoder {
+	return &StringEncoder{}
+}
+
+// Write appends s to the sequence
+func (e *StringEncoder) Write(s string) {
+	e.s.WriteString(s)
+	e.lens.Write(uint64(len(utf16.Encode([]rune(s)))))
+}
+
+// Bytes returns the encoded sequence
+func (e *StringEncoder) Bytes() []byte {
+	enc := NewEncoder()
+	enc.WriteVarString(e.s.String())
+	enc.buf = append(enc.buf, e.lens.Bytes()...)
+	return enc.Bytes()
+}
+
+// StringDecoder reads strings written by StringEncoder
+type StringDecoder struct {
+	units []uint16
+	pos   int
+	lens  UintOptRleDecoder
+}
+
+// NewStringDecoder creates a decoder reading from b
+func NewStringDecoder(b []byte) (*StringDecoder, error) {
+	dec := NewDecoder(b)
+	s, err := dec.ReadVarString()
+	if err != nil {
+		return nil, err
+	}
+	return &StringDecoder{units: utf16.Encode([]rune(s)), lens: UintOptRleDecoder{dec: dec}}, nil
+}
+
+// Read reads the next string
+func (d *StringDecoder) Read() (string, error) {
+	n, err := d.lens.Read()
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(len(d.units)-d.pos) {
+		return "", fmt.Errorf("string of %d code units: %w", n, ErrUnexpectedEOF)
+	}
+	s := string(utf16.Decode(d.units[d.pos : d.pos+int(n)]))
+	d.pos += int(n)
+	return s, nil
+}
@@ -0,0 +1,77 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRle_MatchesLib0(t *testing.T) {
+	enc := NewRleEncoder()
+	for _, v := range []uint8{'a', 'a', 'b'} {
+		enc.Write(v)
+	}
+	assert.Equal(t, []byte{'a', 1, 'b'}, enc.Bytes())
+
+	// the last byte repeats forever
+	dec := NewRleDecoder(enc.Bytes())
+	for _, want := range []uint8{'a', 'a', 'b', 'b', 'b'} {
+		got, err := dec.Read()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestUintOptRle_MatchesLib0(t *testing.T) {
+	values := []uint64{1, 2, 2, 2, 0, 0}
+	enc := NewUintOptRleEncoder()
+	for _, v := range values {
+		enc.Write(v)
+	}
+	// the run of zeros is written as negative zero
+	assert.Equal(t, []byte{0x01, 0x42, 0x01, 0x40, 0x00}, enc.Bytes())
+
+	dec := NewUintOptRleDecoder(enc.Bytes())
+	for _, want := range values {
+		got, err := dec.Read()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := dec.Read()
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+}
+
+func TestIntDiffOptRle_MatchesLib0(t *testing.T) {
+	values := []int64{1, 2, 3, 5, 2, -10}
+	enc := NewIntDiffOptRleEncoder()
+	for _, v := range values {
+		enc.Write(v)
+	}
+	assert.Equal(t, []byte{0x03, 0x01, 0x04, 0x46, 0x58}, enc.Bytes())
+
+	dec := NewIntDiffOptRleDecoder(enc.Bytes())
+	for _, want := range values {
+		got, err := dec.Read()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestString_RoundTrip(t *testing.T) {
+	values := []string{"key", "", "héllo", "😀!", "key"}
+	enc := NewStringEncoder()
+	for _, v := range values {
+		enc.Write(v)
+	}
+
+	dec, err := NewStringDecoder(enc.Bytes())
+	require.NoError(t, err)
+	for _, want := range values {
+		got, err := dec.Read()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err = dec.Read()
+	assert.Error(t, err)
+}
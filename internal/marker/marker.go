@@ -65,28 +65,26 @@ func (ms *MarkerSystem) FindMarker(pos int64) (Marker, error) {
 
 	// it's important to know that in this algorithm, we iterate blocks
 	// markers `Pos` field points to the starting clock of a block in our blockstore
-	// which is why u will see p += len(b.Content) in the iteration
+	// which is why u will see p += b.Len() in the iteration
 
 	// iterate right
 	for b.Right != nil && p < pos {
-		if !b.IsDeleted {
-			if pos < p+int64(len(b.Content)) {
+		if b.Visible() {
+			if pos < p+int64(b.Len()) {
 				break
 			}
-			p += int64(len(b.Content))
+			p += int64(b.Len())
 		}
 		b = b.Right
 	}
 
-	// iterate left
+	// iterate left, `p` is the start of `b` so moving left
+	// means subtracting the length of the left neighbor
 	for b.Left != nil && p > pos {
-		if !b.IsDeleted {
-			if pos >= p-int64(len(b.Content)) {
-				break
-			}
-			p -= int64(len(b.Content))
-		}
 		b = b.Left
+		if b.Visible() {
+			p -= int64(b.Len())
+		}
 	}
 
 	final := Marker{
@@ -113,6 +111,28 @@ func (ms *MarkerSystem) UpdateMarkers(pos int64, delta int64, op OpType) {
 	}
 }
 
+// DeleteMarkersInRange removes every marker positioned in [start, end).
+func (ms *MarkerSystem) DeleteMarkersInRange(start, end int64) {
+	newMarkers := make([]Marker, 0, len(ms.Markers))
+	for _, m := range ms.Markers {
+		if m.Pos < start || m.Pos >= end {
+			newMarkers = append(newMarkers, m)
+		}
+	}
+	ms.Markers = newMarkers
+}
+
+// DeleteMarkersOfBlock removes every marker pointing to the block.
+func (ms *MarkerSystem) DeleteMarkersOfBlock(b *block.Block) {
+	newMarkers := make([]Marker, 0, len(ms.Markers))
+	for _, m := range ms.Markers {
+		if m.Block != b {
+			newMarkers = append(newMarkers, m)
+		}
+	}
+	ms.Markers = newMarkers
+}
+
 // DeleteMarkerAt removes a marker by its position.
 func (ms *MarkerSystem) DeleteMarkerAt(pos int64) {
 	newMarkers := make([]Marker, 0, len(ms.Markers))
@@ -113,3 +113,21 @@ func TestDestroyMarkers(t *testing.T) {
 		t.Fatalf("expected all markers to be removed")
 	}
 }
+
+func TestFindMarker_IterateLeft(t *testing.T) {
+	ms := NewSystem()
+
+	a := block.NewBlock(block.ID{Clock: 0, Client: 1}, "aaaa")
+	b := block.NewBlock(block.ID{Clock: 4, Client: 1}, "b")
+	a.Right = b
+	b.Left = a
+	ms.Add(b, 4)
+
+	m, err := ms.FindMarker(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Block != a || m.Pos != 0 {
+		t.Fatalf("expected block a at 0, got %v at %d", m.Block.ID, m.Pos)
+	}
+}
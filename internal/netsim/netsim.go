@@ -0,0 +1,222 @@
+// Package netsim tests that replicas of a document converge. It runs
+// random edits on several replicas and sends every change to the other
+// replicas through a simulated network that drops, duplicates, reorders
+// and delays messages and partitions the replicas. Once the network is
+// healed and the replicas synced, all of them have to hold the same text.
+//
+// A run is determined by its seed, the same seed replays the same edits
+// and the same network. A failing run is minimized to the fewest edits
+// that still fail, which Replay reproduces.
+package netsim
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+)
+
+// Config describes a simulation, the probabilities are between 0 and 1
+type Config struct {
+	// Replicas is the number of documents editing concurrently
+	Replicas int
+	// Edits is the number of random edits a run generates
+	Edits int
+	// Drop is the probability a message is lost
+	Drop float64
+	// Duplicate is the probability a message is delivered twice
+	Duplicate float64
+	// Reorder is the probability the messages due in a step are
+	// delivered in a random order instead of the order they were sent
+	Reorder float64
+	// MaxDelay is the number of steps a message is held back at most
+	MaxDelay int
+	// Partition is the probability the network splits the replicas
+	// in two groups in a step, or heals when it's split already.
+	// Messages between the groups are lost.
+	Partition float64
+}
+
+// Op is an edit of a replica. Positions and lengths are reduced to
+// the text the replica has when the edit runs, so every Op stays valid
+// when the edits before it are left out.
+type Op struct {
+	Replica int
+	Pos     int
+	// Text is inserted at Pos unless it's empty
+	Text string
+	// Delete is the number of characters deleted at Pos
+	Delete int
+}
+
+// Failure is a run whose replicas did not converge
+type Failure struct {
+	Seed int64
+	// Ops are the fewest edits found that still fail with the seed
+	Ops []Op
+	Err error
+}
+
+func (f *Failure) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "seed %d: %v\nreplay the %d edits with netsim.Replay(cfg, %d, []netsim.Op{\n", f.Seed, f.Err, len(f.Ops), f.Seed)
+	for _, op := range f.Ops {
+		fmt.Fprintf(&b, "\t{Replica: %d, Pos: %d, Text: %q, Delete: %d},\n", op.Replica, op.Pos, op.Text, op.Delete)
+	}
+	b.WriteString("})")
+	return b.String()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Check runs the simulation with every seed and fails t with
+// the minimized edits of the first seed that doesn't converge
+func Check(t testing.TB, cfg Config, seeds ...int64) {
+	t.Helper()
+	for _, seed := range seeds {
+		if err := Run(cfg, seed); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Run generates random edits from seed and replays them,
+// the error is a *Failure holding the minimized edits
+func Run(cfg Config, seed int64) error {
+	ops := Generate(cfg, seed)
+	if err := Replay(cfg, seed, ops); err != nil {
+		ops = minimize(ops, func(ops []Op) bool {
+			return Replay(cfg, seed, ops) != nil
+		})
+		return &Failure{Seed: seed, Ops: ops, Err: Replay(cfg, seed, ops)}
+	}
+	return nil
+}
+
+// Generate returns the random edits of a run
+func Generate(cfg Config, seed int64) []Op {
+	rng := rand.New(rand.NewSource(seed))
+	ops := make([]Op, cfg.Edits)
+	for i := range ops {
+		ops[i] = Op{Replica: rng.Intn(cfg.Replicas), Pos: rng.Intn(64)}
+		// inserts are more likely so the text grows
+		if rng.Intn(3) == 0 {
+			ops[i].Delete = 1 + rng.Intn(3)
+		} else {
+			ops[i].Text = randomText(rng)
+		}
+	}
+	return ops
+}
+
+func randomText(rng *rand.Rand) string {
+	b := make([]byte, 1+rng.Intn(3))
+	for i := range b {
+		b[i] = byte('a' + rng.Intn(26))
+	}
+	return string(b)
+}
+
+// Replay runs the edits on the replicas and checks they converge. The
+// network decides with the seed, so the same seed and edits replay the
+// same run. Panics of the replicas are returned as errors.
+func Replay(cfg Config, seed int64, ops []Op) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	// the network doesn't share the generator of the edits, so
+	// leaving edits out keeps the decisions of the network alike
+	n := newNetwork(cfg, rand.New(rand.NewSource(^seed)))
+	docs := make([]*ygo.YDoc, cfg.Replicas)
+	for i := range docs {
+		// client IDs decide concurrent inserts, they have to be fixed
+		docs[i] = ygo.NewYDoc(ygo.WithClientID(int64(i+1)), ygo.WithGC(false))
+	}
+
+	for _, op := range ops {
+		doc := docs[op.Replica]
+		sv := doc.EncodeStateVectorV1()
+		if err := edit(doc, op); err != nil {
+			return fmt.Errorf("replica %d: %+v: %w", op.Replica, op, err)
+		}
+		update, err := doc.EncodeStateAsUpdateV1(sv)
+		if err != nil {
+			return err
+		}
+		n.send(op.Replica, update)
+		if err := n.step(docs); err != nil {
+			return err
+		}
+	}
+
+	if err := n.heal(docs); err != nil {
+		return err
+	}
+	// what was lost is sent again the way replicas sync
+	// when they connect, every replica sends every other
+	// replica what its state vector misses
+	for i, from := range docs {
+		for j, to := range docs {
+			if i == j {
+				continue
+			}
+			update, err := from.EncodeStateAsUpdateV1(to.EncodeStateVectorV1())
+			if err != nil {
+				return err
+			}
+			if err := to.ApplyUpdateV1(update); err != nil {
+				return fmt.Errorf("sync replica %d to %d: %w", i, j, err)
+			}
+		}
+	}
+
+	want := docs[0].Content()
+	for i, doc := range docs[1:] {
+		if got := doc.Content(); got != want {
+			return fmt.Errorf("replica %d has %q, replica 0 has %q", i+1, got, want)
+		}
+	}
+	return nil
+}
+
+// edit runs op on doc, it does nothing to delete from an empty text
+func edit(doc *ygo.YDoc, op Op) error {
+	length := len(doc.Content())
+	if op.Text != "" {
+		return doc.InsertText(int64(op.Pos%(length+1)), op.Text)
+	}
+	if length == 0 {
+		return nil
+	}
+	pos := op.Pos % length
+	return doc.DeleteText(int64(pos), int64(min(op.Delete, length-pos)))
+}
+
+// minimize removes runs of ops, halving their length, for as
+// long as the remaining ops still fail
+func minimize(ops []Op, fails func([]Op) bool) []Op {
+	for size := len(ops) / 2; size > 0; {
+		removed := false
+		for start := 0; start < len(ops); {
+			end := min(start+size, len(ops))
+			rest := append(ops[:start:start], ops[end:]...)
+			if fails(rest) {
+				ops, removed = rest, true
+				continue
+			}
+			start = end
+		}
+		if !removed {
+			size /= 2
+		}
+	}
+	return ops
+}
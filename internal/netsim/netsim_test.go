@@ -0,0 +1,47 @@
+package netsim
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lossy = Config{
+	Replicas:  4,
+	Edits:     200,
+	Drop:      0.2,
+	Duplicate: 0.2,
+	Reorder:   0.5,
+	MaxDelay:  5,
+	Partition: 0.05,
+}
+
+func TestConvergence(t *testing.T) {
+	seeds := make([]int64, 50)
+	for i := range seeds {
+		seeds[i] = int64(i)
+	}
+	Check(t, lossy, seeds...)
+}
+
+func TestConvergence_ReliableNetwork(t *testing.T) {
+	Check(t, Config{Replicas: 3, Edits: 300}, 1, 2, 3)
+}
+
+func TestReplay_Deterministic(t *testing.T) {
+	ops := Generate(lossy, 7)
+	assert.Equal(t, ops, Generate(lossy, 7))
+	assert.NotEqual(t, ops, Generate(lossy, 8))
+	assert.NoError(t, Replay(lossy, 7, ops))
+}
+
+func TestMinimize(t *testing.T) {
+	ops := Generate(lossy, 1)
+	// fails as long as two particular edits are left
+	culprits := []Op{ops[17], ops[120]}
+	fails := func(ops []Op) bool {
+		return slices.Contains(ops, culprits[0]) && slices.Contains(ops, culprits[1])
+	}
+	assert.Equal(t, culprits, minimize(ops, fails))
+}
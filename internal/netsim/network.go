@@ -0,0 +1,111 @@
+package netsim
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/amoghyermalkar123/ygo"
+)
+
+// message is an update on its way from one replica to another
+type message struct {
+	from, to int
+	update   []byte
+	// due is the step the message arrives in
+	due int
+}
+
+// network delivers updates between replicas, it advances in steps
+type network struct {
+	cfg      Config
+	rng      *rand.Rand
+	now      int
+	inFlight []message
+	// group is the side of the partition every replica is on,
+	// nil while the network isn't split
+	group []int
+}
+
+func newNetwork(cfg Config, rng *rand.Rand) *network {
+	return &network{cfg: cfg, rng: rng}
+}
+
+// send sends update to every replica but from
+func (n *network) send(from int, update []byte) {
+	for to := range n.cfg.Replicas {
+		if to == from || n.rng.Float64() < n.cfg.Drop {
+			continue
+		}
+		copies := 1
+		if n.rng.Float64() < n.cfg.Duplicate {
+			copies++
+		}
+		for range copies {
+			n.inFlight = append(n.inFlight, message{
+				from:   from,
+				to:     to,
+				update: update,
+				due:    n.now + n.rng.Intn(n.cfg.MaxDelay+1),
+			})
+		}
+	}
+}
+
+// step splits or heals the network and delivers the messages that are due
+func (n *network) step(docs []*ygo.YDoc) error {
+	if n.rng.Float64() < n.cfg.Partition {
+		if n.group == nil {
+			n.split()
+		} else {
+			n.group = nil
+		}
+	}
+
+	var due, later []message
+	for _, m := range n.inFlight {
+		if m.due <= n.now {
+			due = append(due, m)
+		} else {
+			later = append(later, m)
+		}
+	}
+	n.inFlight = later
+	n.now++
+
+	if n.rng.Float64() < n.cfg.Reorder {
+		n.rng.Shuffle(len(due), func(i, j int) {
+			due[i], due[j] = due[j], due[i]
+		})
+	}
+	return n.deliver(docs, due)
+}
+
+// split puts every replica on one of two sides, both sides hold a replica
+func (n *network) split() {
+	n.group = make([]int, n.cfg.Replicas)
+	for i := range n.group {
+		n.group[i] = n.rng.Intn(2)
+	}
+	// the first and the last replica are on different sides
+	n.group[0], n.group[len(n.group)-1] = 0, 1
+}
+
+// heal joins the partition and delivers every message in flight
+func (n *network) heal(docs []*ygo.YDoc) error {
+	n.group = nil
+	due := n.inFlight
+	n.inFlight = nil
+	return n.deliver(docs, due)
+}
+
+func (n *network) deliver(docs []*ygo.YDoc, messages []message) error {
+	for _, m := range messages {
+		if n.group != nil && n.group[m.from] != n.group[m.to] {
+			continue
+		}
+		if err := docs[m.to].ApplyUpdateV1(m.update); err != nil {
+			return fmt.Errorf("deliver update of replica %d to %d: %w", m.from, m.to, err)
+		}
+	}
+	return nil
+}
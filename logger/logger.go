@@ -1,127 +1,75 @@
 package logger
 
 import (
+	"context"
+	"io"
+	"log/slog"
 	"os"
-	"sync"
 
 	"github.com/amoghyermalkar123/ygo/internal/block"
-
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-)
-
-var (
-	// Global logger instance
-	log *zap.Logger
-	// Once ensures the logger is initialized only once
-	once sync.Once
 )
 
-// Init initializes the global logger
-func Init() {
-	logPath := "blockstore.log" // Default log path
+// discardHandler drops every record without formatting it.
+type discardHandler struct{}
 
-	once.Do(func() {
-		// Create encoder configuration
-		encoderConfig := zapcore.EncoderConfig{
-			TimeKey:        "time",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller",
-			FunctionKey:    "function", // Enable function name
-			MessageKey:     "msg",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalLevelEncoder,
-			EncodeTime:     zapcore.ISO8601TimeEncoder,
-			EncodeDuration: zapcore.StringDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		}
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
 
-		// Create a file core for writing to a specific log file
-		var core zapcore.Core
-		if logPath != "" {
-			// Create log file if not exists
-			logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-			if err == nil {
-				fileCore := zapcore.NewCore(
-					zapcore.NewJSONEncoder(encoderConfig),
-					zapcore.AddSync(logFile),
-					zap.NewAtomicLevelAt(zap.DebugLevel),
-				)
+// Discard returns a logger that drops everything written to it.
+// It is the default logger of every document and block store.
+func Discard() *slog.Logger {
+	return slog.New(discardHandler{})
+}
 
-				// Create a multi-core to write to both console and file
-				core = zapcore.NewTee(
-					fileCore,
-					zapcore.NewCore(
-						zapcore.NewConsoleEncoder(encoderConfig),
-						zapcore.AddSync(os.Stdout),
-						zap.NewAtomicLevelAt(zap.DebugLevel),
-					),
-				)
-			}
-		}
+// OrDiscard returns l, or a discarding logger when l is nil.
+func OrDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return Discard()
+	}
+	return l
+}
 
-		// If file creation failed or no path provided, log to console only
-		if core == nil {
-			core = zapcore.NewCore(
-				zapcore.NewConsoleEncoder(encoderConfig),
-				zapcore.AddSync(os.Stdout),
-				zap.NewAtomicLevelAt(zap.InfoLevel),
-			)
-		}
+// NewFile opens (or creates) the file at path in append mode and returns a
+// JSON logger writing to it at the given level. The returned closer closes
+// the underlying file.
+func NewFile(path string, level slog.Leveler) (*slog.Logger, io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// Create the global logger
-		log = zap.New(
-			core,
-			zap.AddCaller(),
-			zap.AddCallerSkip(1), // Skip the wrapper function
-			zap.AddStacktrace(zapcore.ErrorLevel),
-		)
-	})
+	return slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level})), f, nil
 }
 
-// GetLogger returns the global logger instance
-func GetLogger() *zap.Logger {
-	// Initialize with default configuration if not already initialized
-	if log == nil {
-		Init()
+// Debug logs a debug message with the block and text list position as context
+func Debug(l *slog.Logger, msg string, blk *block.Block, tlp *block.BlockTextListPosition, args ...any) {
+	// building the context fields is not free, skip it when nobody listens
+	if !l.Enabled(context.Background(), slog.LevelDebug) {
+		return
 	}
-	return log
-}
 
-// Debug logs a debug message
-func Debug(msg string, block *block.Block, tlp *block.BlockTextListPosition, fields ...zap.Field) {
-	if block != nil {
-		fields = append(fields, zap.Any("Block", block.ID))
-		if block.Left != nil {
-			fields = append(fields, zap.Any("Block_left", block.Left.ID))
+	if blk != nil {
+		args = append(args, slog.Any("Block", blk.ID))
+		if blk.Left != nil {
+			args = append(args, slog.Any("Block_left", blk.Left.ID))
 		}
-		if block.Right != nil {
-			fields = append(fields, zap.Any("Block_right", block.Right.ID))
+		if blk.Right != nil {
+			args = append(args, slog.Any("Block_right", blk.Right.ID))
 		}
-		fields = append(fields, zap.String("Block_content", block.Content))
+		args = append(args, slog.Any("Block_content", blk.Content))
 	}
 
 	if tlp != nil {
 		if tlp.Left != nil {
-			fields = append(fields, zap.Any("TLP_left", tlp.Left.ID))
+			args = append(args, slog.Any("TLP_left", tlp.Left.ID))
 		}
 		if tlp.Right != nil {
-			fields = append(fields, zap.Any("TLP_right", tlp.Right.ID))
+			args = append(args, slog.Any("TLP_right", tlp.Right.ID))
 		}
-		fields = append(fields, zap.Int64("TLP_index", tlp.Index))
+		args = append(args, slog.Int64("TLP_index", tlp.Index))
 	}
 
-	GetLogger().Debug(msg, fields...)
-}
-
-// Info logs an info message
-func Info(msg string, fields ...zap.Field) {
-	GetLogger().Info(msg, fields...)
-}
-
-// Sync flushes any buffered log entries
-func Sync() error {
-	return GetLogger().Sync()
+	l.Debug(msg, args...)
 }
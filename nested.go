@@ -0,0 +1,130 @@
+package ygo
+
+import (
+	"fmt"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// Shared types nest: a map value, an array element or an xml child can be a
+// shared type of its own. The blocks of a nested type refer to the block
+// holding it as their parent, so the tree survives encoding.
+
+// shared returns the shared type handed out for the nested type `t`
+// based on the type held by its block, nil for unsupported types
+func (yd *YDoc) shared(t *blockstore.Type) sharedType {
+	if st, ok := yd.types[t]; ok {
+		return st
+	}
+
+	c, ok := t.Item.Content.(*block.ContentType)
+	if !ok {
+		return nil
+	}
+
+	var st sharedType
+	switch c.TypeRef {
+	case block.TypeArray:
+		st = &YArray{doc: yd, array: t}
+	case block.TypeMap:
+		st = &YMap{doc: yd, m: t}
+	case block.TypeText:
+		st = &YText{doc: yd, text: t}
+	case block.TypeXmlFragment:
+		st = &YXmlFragment{doc: yd, t: t}
+	case block.TypeXmlElement:
+		st = &YXmlElement{YXmlFragment{doc: yd, t: t}}
+	case block.TypeXmlText:
+		st = &YXmlText{&YText{doc: yd, text: t}}
+	default:
+		return nil
+	}
+
+	yd.types[t] = st
+	return st
+}
+
+// insertType inserts a new nested type at index of the list of `t`
+func (yd *YDoc) insertType(t *blockstore.Type, index int64, content *block.ContentType) (st sharedType, err error) {
+	if index < 0 || index > int64(t.Length) {
+		return nil, fmt.Errorf("insert at %d: index out of range [0, %d]", index, t.Length)
+	}
+
+	yd.Transact(func() {
+		var pos *block.BlockTextListPosition
+		if pos, err = yd.blockStore.FindPosition(t, index); err != nil {
+			return
+		}
+		blk := yd.blockStore.InsertAt(t, pos, content)
+		st = yd.shared(yd.blockStore.NestedType(blk))
+	})
+	return st, err
+}
+
+// setType sets `key` of the map `t` to a new nested type
+func (yd *YDoc) setType(t *blockstore.Type, key string, typeRef uint8) (st sharedType, err error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+
+	yd.Transact(func() {
+		yd.blockStore.SetMapValue(t, key, &block.ContentType{TypeRef: typeRef})
+		st = yd.shared(yd.blockStore.NestedType(t.Map[key]))
+	})
+	return st, nil
+}
+
+// SetText sets key to a new empty text and returns it
+func (m *YMap) SetText(key string) (*YText, error) {
+	st, err := m.doc.setType(m.m, key, block.TypeText)
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YText), nil
+}
+
+// SetMap sets key to a new empty map and returns it
+func (m *YMap) SetMap(key string) (*YMap, error) {
+	st, err := m.doc.setType(m.m, key, block.TypeMap)
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YMap), nil
+}
+
+// SetArray sets key to a new empty array and returns it
+func (m *YMap) SetArray(key string) (*YArray, error) {
+	st, err := m.doc.setType(m.m, key, block.TypeArray)
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YArray), nil
+}
+
+// InsertText inserts a new empty text at index and returns it
+func (a *YArray) InsertText(index int64) (*YText, error) {
+	st, err := a.doc.insertType(a.array, index, &block.ContentType{TypeRef: block.TypeText})
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YText), nil
+}
+
+// InsertMap inserts a new empty map at index and returns it
+func (a *YArray) InsertMap(index int64) (*YMap, error) {
+	st, err := a.doc.insertType(a.array, index, &block.ContentType{TypeRef: block.TypeMap})
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YMap), nil
+}
+
+// InsertArray inserts a new empty array at index and returns it
+func (a *YArray) InsertArray(index int64) (*YArray, error) {
+	st, err := a.doc.insertType(a.array, index, &block.ContentType{TypeRef: block.TypeArray})
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YArray), nil
+}
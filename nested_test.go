@@ -0,0 +1,188 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSection adds a section holding a text and an array of comments
+func newSection(t *testing.T, doc *ygo.YDoc, name, body string) {
+	t.Helper()
+
+	section, err := doc.GetMap("sections").SetMap(name)
+	require.NoError(t, err)
+	text, err := section.SetText("text")
+	require.NoError(t, err)
+	require.NoError(t, text.InsertText(0, body, nil))
+	_, err = section.SetArray("comments")
+	require.NoError(t, err)
+}
+
+// section returns the text and the comments of a section
+func section(t *testing.T, doc *ygo.YDoc, name string) (*ygo.YText, *ygo.YArray) {
+	t.Helper()
+
+	v, ok := doc.GetMap("sections").Get(name)
+	require.True(t, ok)
+	m, ok := v.(*ygo.YMap)
+	require.True(t, ok)
+
+	text, ok := m.Get("text")
+	require.True(t, ok)
+	comments, ok := m.Get("comments")
+	require.True(t, ok)
+	return text.(*ygo.YText), comments.(*ygo.YArray)
+}
+
+// TestNested_Sync tests that nested types reach other replicas in both update formats
+func TestNested_Sync(t *testing.T) {
+	docA := ygo.NewYDoc()
+	newSection(t, docA, "intro", "Hello")
+
+	docB := ygo.NewYDoc()
+	sync(t, docA, docB)
+	docC := ygo.NewYDoc()
+	syncV1(t, docA, docC)
+
+	for _, doc := range []*ygo.YDoc{docB, docC} {
+		text, comments := section(t, doc, "intro")
+		assert.Equal(t, "Hello", text.Content())
+		assert.Equal(t, int64(0), comments.Length())
+	}
+
+	// concurrent edits inside the nested types
+	textA, commentsA := section(t, docA, "intro")
+	textB, commentsB := section(t, docB, "intro")
+	require.NoError(t, textA.InsertText(5, " World", nil))
+	require.NoError(t, commentsA.Push("nice"))
+	require.NoError(t, textB.InsertText(0, ">> ", nil))
+	require.NoError(t, commentsB.Push("typo"))
+
+	sync(t, docA, docB)
+	assert.Equal(t, textA.Content(), textB.Content())
+	assert.Equal(t, ">> Hello World", textA.Content())
+	assert.ElementsMatch(t, []any{"nice", "typo"}, commentsA.ToSlice())
+	assert.Equal(t, commentsA.ToSlice(), commentsB.ToSlice())
+}
+
+// TestNested_ArrayOfTypes tests nested types as array elements
+func TestNested_ArrayOfTypes(t *testing.T) {
+	doc := ygo.NewYDoc()
+	rows := doc.GetArray("rows")
+
+	require.NoError(t, rows.Push("header"))
+	row, err := rows.InsertMap(1)
+	require.NoError(t, err)
+	require.NoError(t, row.Set("id", 1))
+	cells, err := rows.InsertArray(2)
+	require.NoError(t, err)
+	require.NoError(t, cells.Push("a", "b"))
+
+	v, ok := rows.Get(1)
+	require.True(t, ok)
+	assert.Same(t, row, v)
+	assert.Equal(t, map[string]any{"id": float64(1)}, row.Entries())
+
+	// deleting the element deletes everything inside it
+	require.NoError(t, rows.Delete(2, 1))
+	assert.Equal(t, int64(2), rows.Length())
+
+	other := ygo.NewYDoc()
+	syncV1(t, doc, other)
+	v, ok = other.GetArray("rows").Get(1)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{"id": float64(1)}, v.(*ygo.YMap).Entries())
+}
+
+// TestNested_ObserveDeep tests that changes bubble up with the path to the changed type
+func TestNested_ObserveDeep(t *testing.T) {
+	docA := ygo.NewYDoc()
+	newSection(t, docA, "intro", "Hello")
+	newSection(t, docA, "outro", "Bye")
+
+	var got [][]ygo.DeepEvent
+	unobserve := docA.GetMap("sections").ObserveDeep(func(events []ygo.DeepEvent) {
+		got = append(got, events)
+	})
+	defer unobserve()
+
+	text, comments := section(t, docA, "intro")
+	require.NoError(t, text.InsertText(5, "!", nil))
+	require.Len(t, got, 1)
+	require.Len(t, got[0], 1)
+	assert.Equal(t, []any{"intro", "text"}, got[0][0].Path)
+	assert.Equal(t, []ygo.TextDelta{{Retain: 5}, {Insert: "!"}}, got[0][0].Event.(*ygo.YTextEvent).Delta)
+
+	// one call per transaction, closest events first
+	docA.Transact(func() {
+		require.NoError(t, comments.Push("first"))
+		require.NoError(t, docA.GetMap("sections").Set("title", "Notes"))
+	})
+	require.Len(t, got, 2)
+	require.Len(t, got[1], 2)
+	assert.Empty(t, got[1][0].Path)
+	assert.IsType(t, &ygo.YMapEvent{}, got[1][0].Event)
+	assert.Equal(t, []any{"intro", "comments"}, got[1][1].Path)
+	assert.IsType(t, &ygo.YArrayEvent{}, got[1][1].Event)
+
+	// remote changes bubble up as well, array indexes are part of the path
+	docB := ygo.NewYDoc()
+	sync(t, docA, docB)
+	_, commentsB := section(t, docB, "outro")
+	item, err := commentsB.InsertMap(0)
+	require.NoError(t, err)
+	require.NoError(t, item.Set("author", "bob"))
+	require.NoError(t, commentsB.Push("second"))
+	sync(t, docA, docB)
+
+	require.Len(t, got, 3)
+	require.Len(t, got[2], 2)
+	assert.Equal(t, []any{"outro", "comments"}, got[2][0].Path)
+	assert.False(t, got[2][0].Event.(*ygo.YArrayEvent).Local)
+	assert.Equal(t, []any{"outro", "comments", int64(0)}, got[2][1].Path)
+	assert.IsType(t, &ygo.YMapEvent{}, got[2][1].Event)
+
+	require.NoError(t, item.Set("author", "carol"))
+	sync(t, docA, docB)
+	require.Len(t, got, 4)
+	assert.Equal(t, []any{"outro", "comments", int64(0)}, got[3][0].Path)
+}
+
+// TestNested_InvalidParent tests that blocks nested in something that
+// isn't a type are rejected or kept out of every type
+func TestNested_InvalidParent(t *testing.T) {
+	// a block nested in itself
+	doc := ygo.NewYDoc()
+	err := doc.ApplyUpdate([]byte(`{"updates":{"updates":{"1":[{"ID":{"Clock":0,"Client":1},"ContentRef":4,"Content":"abc","Parent":{"id":{"Clock":0,"Client":1}}}]}}}`))
+	assert.Error(t, err)
+	assert.Empty(t, doc.EncodeStateVector())
+
+	// a block nested in a piece of text
+	doc = ygo.NewYDoc()
+	require.NoError(t, doc.ApplyUpdate([]byte(`{"updates":{"updates":{"1":[`+
+		`{"ID":{"Clock":0,"Client":1},"ContentRef":4,"Content":"abc","Parent":{"root":"t"}},`+
+		`{"ID":{"Clock":3,"Client":1},"ContentRef":4,"Content":"x","Parent":{"id":{"Clock":1,"Client":1}}}]}}}`)))
+	assert.Equal(t, "abc", doc.GetText("t").Content())
+	assert.Equal(t, map[int64]int64{1: 4}, doc.EncodeStateVector())
+	assert.Zero(t, pendingBlocks(doc))
+
+	// a block nested in a type of a client that isn't known yet waits for it
+	doc = ygo.NewYDoc()
+	require.NoError(t, doc.ApplyUpdate([]byte(`{"updates":{"updates":{"1":[{"ID":{"Clock":0,"Client":1},"ContentRef":4,"Content":"abc","Parent":{"id":{"Clock":0,"Client":2}}}]}}}`)))
+	assert.Empty(t, doc.EncodeStateVector())
+	assert.Positive(t, pendingBlocks(doc))
+}
+
+// pendingBlocks counts the blocks waiting for their dependencies
+func pendingBlocks(doc *ygo.YDoc) int {
+	n := 0
+	for _, u := range doc.GetPendingUpdates() {
+		for _, blocks := range u.Updates {
+			n += len(blocks)
+		}
+	}
+	return n
+}
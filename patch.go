@@ -0,0 +1,338 @@
+package ygo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/diff"
+)
+
+// maxFuzz is the number of context lines at either end of a hunk that
+// may be ignored when it doesn't match otherwise, like `patch` does
+const maxFuzz = 2
+
+// ErrInvalidPatch is returned for patches that can't be parsed
+var ErrInvalidPatch = errors.New("invalid patch")
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Hunk is a hunk of a unified diff
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Lines are the lines of the hunk with their ' ', '-' or '+' prefix
+	// and without line breaks, "\ No newline at end of file" is dropped
+	Lines []string
+
+	// noNewline marks the lines not followed by a line break
+	noNewline map[int]bool
+}
+
+// AppliedHunk is a hunk that was applied
+type AppliedHunk struct {
+	Hunk Hunk
+	// Line is the line, counted from 1, the hunk was found at
+	Line int
+	// Fuzz is the number of context lines at its ends that didn't match
+	Fuzz int
+}
+
+// PatchResult says which hunks of a patch were applied
+type PatchResult struct {
+	Applied []AppliedHunk
+	// Rejected are the hunks that couldn't be found, none of their
+	// changes were made
+	Rejected []Hunk
+}
+
+// ApplyUnifiedDiff applies a unified diff of a single file to the default
+// text of the document in one transaction. Every hunk is looked for near
+// the line it names, shifted by the offset the hunks before it were found
+// at, ignoring up to two lines of context at its ends if it doesn't match
+// exactly. Hunks that can't be found are rejected as a whole, the others
+// are applied. Changed lines are edited character by character, so
+// positions in the unchanged parts of a line stay valid.
+func ApplyUnifiedDiff(doc *YDoc, patch string) (*PatchResult, error) {
+	hunks, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	text := doc.GetText(DefaultText)
+	lines := splitLines(text.Content())
+
+	// where every line starts in UTF-16 code units, and where the text ends
+	starts := make([]int64, len(lines)+1)
+	for i, line := range lines {
+		starts[i+1] = starts[i] + units([]rune(line))
+	}
+
+	result := &PatchResult{}
+	var edits []lineEdit
+	// hunks may not overlap, `next` is the first line the next one may touch
+	offset, next := 0, 0
+	for _, h := range hunks {
+		at, fuzz, lead, ok := h.find(lines, offset, next)
+		if !ok {
+			result.Rejected = append(result.Rejected, h)
+			continue
+		}
+
+		expected := h.OldStart - 1
+		if h.OldLines == 0 {
+			expected = h.OldStart
+		}
+		offset = at - lead - expected
+		next = at + h.edits(lead, fuzz, at, func(e lineEdit) { edits = append(edits, e) })
+		result.Applied = append(result.Applied, AppliedHunk{Hunk: h, Line: at - lead + 1, Fuzz: fuzz})
+	}
+
+	doc.Transact(func() {
+		// from the end so the positions of the edits before stay valid
+		for _, e := range slices.Backward(edits) {
+			if err = text.replace(starts[e.line], lines[e.line:e.line+e.remove], e.insert); err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lineEdit replaces `remove` lines starting at `line` with `insert`
+type lineEdit struct {
+	line, remove int
+	insert       []string
+}
+
+// replace turns the text `old` starting at pos into the lines of
+// `insert` editing only the characters that differ
+func (t *YText) replace(pos int64, old, insert []string) (err error) {
+	before := []rune(strings.Join(old, ""))
+	after := []rune(strings.Join(insert, ""))
+
+	i, j := 0, 0
+	for _, e := range diff.Diff(before, after) {
+		switch e.Op {
+		case diff.Equal:
+			pos += units(before[i : i+e.N])
+			i, j = i+e.N, j+e.N
+		case diff.Delete:
+			err = t.doc.blockStore.Delete(t.text, pos, units(before[i:i+e.N]))
+			i += e.N
+		case diff.Insert:
+			err = t.insert(pos, &block.ContentString{Str: string(after[j : j+e.N])}, nil)
+			pos += units(after[j : j+e.N])
+			j += e.N
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find looks for the hunk in the lines, the closest match to where the
+// hunk expects to be wins. It returns the line the match starts at, the
+// fuzz needed and the number of leading context lines ignored.
+func (h Hunk) find(lines []string, offset, next int) (at, fuzz, lead int, ok bool) {
+	leading, trailing := h.context()
+
+	for fuzz = 0; fuzz <= maxFuzz; fuzz++ {
+		lead, trail := min(fuzz, leading), min(fuzz, trailing)
+		if fuzz > 0 && lead+trail == 0 {
+			break
+		}
+		pattern := h.old()[lead : len(h.old())-trail]
+
+		expected := h.OldStart - 1 + offset + lead
+		if h.OldLines == 0 {
+			expected = h.OldStart + offset
+		}
+		// the search spreads out from the expected line within [next, last]
+		last := len(lines) - len(pattern)
+		if next > last {
+			continue
+		}
+		expected = min(max(expected, next), last)
+		for distance := 0; expected-distance >= next || expected+distance <= last; distance++ {
+			for _, candidate := range []int{expected - distance, expected + distance} {
+				if candidate >= next && candidate <= last && slices.Equal(lines[candidate:candidate+len(pattern)], pattern) {
+					return candidate, fuzz, lead, true
+				}
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// edits reports the edits of the hunk found at line `at` with `lead`
+// leading context lines ignored, it returns the number of lines the
+// hunk spans in the text
+func (h Hunk) edits(lead, fuzz, at int, fn func(lineEdit)) int {
+	_, trailing := h.context()
+	trail := min(fuzz, trailing)
+
+	line := at
+	var edit *lineEdit
+	flush := func() {
+		if edit != nil {
+			fn(*edit)
+			edit = nil
+		}
+	}
+
+	skipped := 0
+	for i, l := range h.Lines {
+		switch l[0] {
+		case ' ':
+			// the context ignored at the start isn't part of the match
+			if skipped < lead {
+				skipped++
+				continue
+			}
+			flush()
+			line++
+		case '-':
+			if edit == nil {
+				edit = &lineEdit{line: line}
+			}
+			edit.remove++
+			line++
+		case '+':
+			if edit == nil {
+				edit = &lineEdit{line: line}
+			}
+			edit.insert = append(edit.insert, h.line(i))
+		}
+	}
+	flush()
+	return line - at - trail
+}
+
+// context returns the number of context lines at the start and the end
+func (h Hunk) context() (leading, trailing int) {
+	for leading < len(h.Lines) && h.Lines[leading][0] == ' ' {
+		leading++
+	}
+	for trailing < len(h.Lines)-leading && h.Lines[len(h.Lines)-1-trailing][0] == ' ' {
+		trailing++
+	}
+	return leading, trailing
+}
+
+// old returns the lines the hunk expects in the text
+func (h Hunk) old() []string {
+	var old []string
+	for i, l := range h.Lines {
+		if l[0] != '+' {
+			old = append(old, h.line(i))
+		}
+	}
+	return old
+}
+
+// line returns the i-th line without its prefix, with its line break
+func (h Hunk) line(i int) string {
+	if h.noNewline[i] {
+		return h.Lines[i][1:]
+	}
+	return h.Lines[i][1:] + "\n"
+}
+
+// splitLines splits the text after every line break
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// parsePatch parses the hunks of a unified diff of a single file
+func parsePatch(patch string) ([]Hunk, error) {
+	var hunks []Hunk
+	files := 0
+
+	lines := strings.Split(strings.TrimSuffix(patch, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "--- ") {
+			if files++; files > 1 {
+				return nil, fmt.Errorf("%w: patches of several files aren't supported", ErrInvalidPatch)
+			}
+			continue
+		}
+
+		m := hunkHeader.FindStringSubmatch(lines[i])
+		if m == nil {
+			// headers and anything else between hunks
+			continue
+		}
+
+		h := Hunk{noNewline: make(map[int]bool)}
+		for j, field := range []*int{&h.OldStart, &h.OldLines, &h.NewStart, &h.NewLines} {
+			n, err := number(m[j+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: hunk %q: %v", ErrInvalidPatch, m[0], err)
+			}
+			*field = n
+		}
+
+		oldLeft, newLeft := h.OldLines, h.NewLines
+		for oldLeft > 0 || newLeft > 0 || (i+1 < len(lines) && strings.HasPrefix(lines[i+1], `\`)) {
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("%w: hunk %q ends early", ErrInvalidPatch, m[0])
+			}
+
+			l := lines[i]
+			if l == "" {
+				// some tools strip the space of empty context lines
+				l = " "
+			}
+			switch l[0] {
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			case '\\':
+				if len(h.Lines) == 0 {
+					return nil, fmt.Errorf("%w: %q before any line", ErrInvalidPatch, l)
+				}
+				h.noNewline[len(h.Lines)-1] = true
+				continue
+			default:
+				return nil, fmt.Errorf("%w: unexpected line %q in hunk %q", ErrInvalidPatch, l, m[0])
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("%w: hunk %q has more lines than it says", ErrInvalidPatch, m[0])
+			}
+			h.Lines = append(h.Lines, l)
+		}
+
+		hunks = append(hunks, h)
+	}
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("%w: no hunks", ErrInvalidPatch)
+	}
+	return hunks, nil
+}
+
+// number parses a number of a hunk header, line counts are 1 if left out
+func number(s string) (int, error) {
+	if s == "" {
+		return 1, nil
+	}
+	return strconv.Atoi(s)
+}
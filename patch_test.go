@@ -0,0 +1,152 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+func helper() int {
+	return 1
+}
+`
+
+// TestApplyUnifiedDiff tests applying the hunks of a git diff
+func TestApplyUnifiedDiff(t *testing.T) {
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.InsertText(0, source))
+
+	// the file gained two lines at the top since the diff was made
+	require.NoError(t, doc.InsertText(0, "// Code generated by hand.\n\n"))
+
+	patch := `diff --git a/main.go b/main.go
+index 3b18e51..a042389 100644
+--- a/main.go
++++ b/main.go
+@@ -4,5 +4,6 @@ import "fmt"
+ 
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("hello, world")
++	fmt.Println(helper())
+ }
+ 
+@@ -9,3 +10,3 @@ func main() {
+ func helper() int {
+-	return 1
++	return 2
+ }
+`
+	result, err := ygo.ApplyUnifiedDiff(doc, patch)
+	require.NoError(t, err)
+	require.Len(t, result.Applied, 2)
+	assert.Empty(t, result.Rejected)
+	assert.Equal(t, 6, result.Applied[0].Line)
+	assert.Equal(t, 0, result.Applied[0].Fuzz)
+
+	assert.Equal(t, `// Code generated by hand.
+
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello, world")
+	fmt.Println(helper())
+}
+
+func helper() int {
+	return 2
+}
+`, doc.Content())
+}
+
+// TestApplyUnifiedDiff_FuzzAndReject tests fuzzy context and rejected hunks
+func TestApplyUnifiedDiff_FuzzAndReject(t *testing.T) {
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.InsertText(0, source))
+
+	patch := `--- a/main.go
++++ b/main.go
+@@ -5,3 +5,3 @@
+ func Main() {
+-	fmt.Println("hello")
++	fmt.Println("hi")
+ }
+@@ -10,2 +10,2 @@
+-	return 3
++	return 4
+ }
+`
+	result, err := ygo.ApplyUnifiedDiff(doc, patch)
+	require.NoError(t, err)
+
+	// the first line of context changed since, the hunk still applies
+	require.Len(t, result.Applied, 1)
+	assert.Equal(t, 1, result.Applied[0].Fuzz)
+	assert.Equal(t, 5, result.Applied[0].Line)
+
+	// the line the second hunk removes isn't there, nothing of it is applied
+	require.Len(t, result.Rejected, 1)
+	assert.Equal(t, 10, result.Rejected[0].OldStart)
+	assert.Contains(t, doc.Content(), `fmt.Println("hi")`)
+	assert.Contains(t, doc.Content(), "return 1")
+}
+
+// TestApplyUnifiedDiff_NoNewline tests the last line without line break
+func TestApplyUnifiedDiff_NoNewline(t *testing.T) {
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.InsertText(0, "a\nb"))
+
+	patch := `@@ -1,2 +1,3 @@
+ a
+-b
+\ No newline at end of file
++b
++c
+`
+	result, err := ygo.ApplyUnifiedDiff(doc, patch)
+	require.NoError(t, err)
+	assert.Len(t, result.Applied, 1)
+	assert.Equal(t, "a\nb\nc\n", doc.Content())
+}
+
+// TestApplyUnifiedDiff_Invalid tests that malformed patches change nothing
+func TestApplyUnifiedDiff_Invalid(t *testing.T) {
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.InsertText(0, "a\n"))
+
+	for _, patch := range []string{
+		"",
+		"@@ -1,2 +1,2 @@\n a\n",
+		"@@ -1,2 +1,2 @@\n a\n?b\n",
+		"--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n",
+		"@@ -99999999999999999999 +1 @@\n-a\n+b\n",
+	} {
+		_, err := ygo.ApplyUnifiedDiff(doc, patch)
+		assert.ErrorIs(t, err, ygo.ErrInvalidPatch, "%q", patch)
+	}
+	assert.Equal(t, "a\n", doc.Content())
+}
+
+// TestApplyUnifiedDiff_FarLines tests hunks naming lines far past the end of the text
+func TestApplyUnifiedDiff_FarLines(t *testing.T) {
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.InsertText(0, "a\nb\n"))
+
+	result, err := ygo.ApplyUnifiedDiff(doc, "@@ -9223372036854775000 +1 @@\n-b\n+c\n@@ -4611686018427387904 +1 @@\n-x\n+y\n")
+	require.NoError(t, err)
+	assert.Len(t, result.Applied, 1)
+	assert.Len(t, result.Rejected, 1)
+	assert.Equal(t, "a\nc\n", doc.Content())
+}
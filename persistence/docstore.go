@@ -0,0 +1,536 @@
+package persistence
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amoghyermalkar123/ygo"
+)
+
+const (
+	// DefaultMaxDocs is the number of documents a DocStore keeps open
+	DefaultMaxDocs = 128
+	// DefaultMemoryBudget is the number of bytes the documents
+	// a DocStore keeps open may take up
+	DefaultMemoryBudget = 64 << 20
+)
+
+var (
+	// ErrInvalidName is returned for empty document names
+	ErrInvalidName = errors.New("persistence: invalid document name")
+	// ErrDocClosed is returned by handles that were closed, or whose
+	// document was deleted or whose store was closed
+	ErrDocClosed = errors.New("persistence: document closed")
+)
+
+// DocMeta describes a stored document
+type DocMeta struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+	// Size is the size of the document as a single v1 update in bytes
+	Size int64 `json:"size"`
+}
+
+// DocStore manages many named documents in a directory. The updates of
+// every document are stored through a Provider, a FileProvider in the
+// same directory by default, and its metadata next to them. Open
+// documents are kept in memory, the least recently used ones are saved
+// and dropped once there are too many of them or they take up more than
+// the memory budget.
+//
+// Documents are only reached through the Handle returned by Open. A
+// document is pinned while a handle to it is open, it is never evicted
+// and every Open of its name shares the same document. Handle.Do locks
+// the document, saving it takes the same lock, so the document must not
+// be used outside of Do and Do must not call back into the store.
+type DocStore struct {
+	dir      string
+	maxDocs  int
+	budget   int64
+	docOpts  []ygo.Option
+	provider Provider
+	// ownsProvider is set when the store created the provider itself
+	ownsProvider bool
+
+	mu sync.Mutex
+	// lru holds the open documents, the most recently used first
+	lru  *list.List
+	open map[string]*list.Element
+	// used is the sum of the sizes of the open documents
+	used int64
+}
+
+// openDoc is a document kept in memory. The fields before mu are
+// guarded by the mutex of the store, the ones after it by mu.
+type openDoc struct {
+	name string
+	meta DocMeta
+	// size is the size of the document when it was last measured
+	// plus the size of the updates made since
+	size int64
+	// refs is the number of open handles, the document isn't evicted while it has any
+	refs     int
+	lastUsed time.Time
+	// hash is the hash of the document as of the last save or load,
+	// the document is only written when it changed
+	hash uint64
+
+	// mu guards the document, it's never held while waiting for the mutex of the store
+	mu  sync.Mutex
+	doc *ygo.YDoc
+	// sv is the state vector as of the last save or load,
+	// saving stores the changes made since
+	sv []byte
+	// grown is the size of the updates made since the last Do
+	grown int64
+	// closed is set once the document was dropped from the store
+	closed bool
+}
+
+// Handle is an open document of a DocStore
+type Handle struct {
+	s      *DocStore
+	od     *openDoc
+	closed bool
+}
+
+// DocStoreOption configures a DocStore
+type DocStoreOption func(*DocStore)
+
+// WithMaxDocs sets the number of documents kept in memory
+func WithMaxDocs(n int) DocStoreOption {
+	return func(s *DocStore) {
+		s.maxDocs = n
+	}
+}
+
+// WithMemoryBudget sets the number of bytes the documents kept in memory
+// may take up. Documents are measured by the size of their v1 update
+// when they are loaded or saved, in between they grow by the size of
+// the updates made in Handle.Do.
+func WithMemoryBudget(bytes int64) DocStoreOption {
+	return func(s *DocStore) {
+		s.budget = bytes
+	}
+}
+
+// WithDocOptions sets the options every document is created with
+func WithDocOptions(opts ...ygo.Option) DocStoreOption {
+	return func(s *DocStore) {
+		s.docOpts = opts
+	}
+}
+
+// WithProvider sets the provider the updates of the documents are stored
+// through, the store doesn't close it
+func WithProvider(p Provider) DocStoreOption {
+	return func(s *DocStore) {
+		s.provider = p
+	}
+}
+
+// NewDocStore returns a store keeping its documents in dir,
+// the directory is created if it doesn't exist
+func NewDocStore(dir string, opts ...DocStoreOption) (*DocStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &DocStore{
+		dir:     dir,
+		maxDocs: DefaultMaxDocs,
+		budget:  DefaultMemoryBudget,
+		lru:     list.New(),
+		open:    make(map[string]*list.Element),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.provider == nil {
+		p, err := NewFileProvider(dir)
+		if err != nil {
+			return nil, err
+		}
+		s.provider, s.ownsProvider = p, true
+	}
+	return s, nil
+}
+
+// Open returns a handle to the document `name`, loading it or creating
+// it. Other documents may be evicted to make room for it. The document
+// stays in memory until the handle is closed.
+func (s *DocStore) Open(name string) (*Handle, error) {
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.open[name]
+	if !ok {
+		od, err := s.load(name)
+		if err != nil {
+			return nil, err
+		}
+		e = s.lru.PushFront(od)
+		s.open[name] = e
+		s.used += od.size
+	}
+
+	s.lru.MoveToFront(e)
+	od := e.Value.(*openDoc)
+	od.lastUsed = time.Now()
+	od.refs++
+	if err := s.shrink(); err != nil {
+		od.refs--
+		return nil, err
+	}
+	return &Handle{s: s, od: od}, nil
+}
+
+// Name returns the name of the document
+func (h *Handle) Name() string {
+	return h.od.name
+}
+
+// Do runs fn with the document locked. The document must not be used
+// after fn returns, changes are saved along with the document. Saving,
+// deleting the document and closing the store wait for fn to return,
+// so fn must not call back into the store.
+func (h *Handle) Do(fn func(doc *ygo.YDoc) error) error {
+	od := h.od
+	od.mu.Lock()
+	if h.closed || od.closed {
+		od.mu.Unlock()
+		return ErrDocClosed
+	}
+	err := fn(od.doc)
+	grown := od.grown
+	od.grown = 0
+	od.mu.Unlock()
+
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.used += grown
+	od.size += grown
+	od.lastUsed = time.Now()
+	return errors.Join(err, s.shrink())
+}
+
+// Close releases the handle, the document may be evicted once
+// every handle to it is closed. Closing twice does nothing.
+func (h *Handle) Close() error {
+	od := h.od
+	od.mu.Lock()
+	if h.closed {
+		od.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	od.mu.Unlock()
+
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	od.refs--
+	return s.shrink()
+}
+
+// Save writes the document `name` if it's open and changed
+func (s *DocStore) Save(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.open[name]
+	if !ok {
+		return nil
+	}
+	return s.save(e.Value.(*openDoc))
+}
+
+// Stat returns the metadata of the document `name`, the
+// metadata of open documents is as of their last save
+func (s *DocStore) Stat(name string) (DocMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.open[name]; ok {
+		return e.Value.(*openDoc).meta, nil
+	}
+	return s.readMeta(name)
+}
+
+// List returns the metadata of every stored document whose name
+// starts with prefix, sorted by name. Documents that were opened
+// but never saved aren't stored yet.
+func (s *DocStore) List(prefix string) ([]DocMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.meta"))
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []DocMeta
+	for _, file := range files {
+		escaped := strings.TrimSuffix(filepath.Base(file), ".meta")
+		name, err := url.PathUnescape(escaped)
+		if err != nil || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		meta, err := s.readMeta(name)
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := s.open[name]; ok {
+			meta = e.Value.(*openDoc).meta
+		}
+		metas = append(metas, meta)
+	}
+
+	slices.SortFunc(metas, func(a, b DocMeta) int { return strings.Compare(a.Name, b.Name) })
+	return metas, nil
+}
+
+// Delete drops the document `name` from memory and storage,
+// open handles to it return ErrDocClosed from then on
+func (s *DocStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.open[name]; ok {
+		s.drop(e)
+	}
+
+	if err := s.provider.ClearDocument(name); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(name, ".meta")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// EvictIdle saves and drops the documents without open handles
+// that weren't used within the last `idle` duration
+func (s *DocStore) EvictIdle(idle time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadline := time.Now().Add(-idle)
+	for e := s.lru.Back(); e != nil; {
+		prev := e.Prev()
+		od := e.Value.(*openDoc)
+		if od.refs == 0 && od.lastUsed.Before(deadline) {
+			if err := s.evict(e); err != nil {
+				return err
+			}
+		}
+		e = prev
+	}
+	return nil
+}
+
+// Close saves and drops every open document, handles still open return
+// ErrDocClosed from then on. A provider the store created is closed too.
+func (s *DocStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.lru.Len() > 0 {
+		if err := s.evict(s.lru.Back()); err != nil {
+			return err
+		}
+	}
+	if s.ownsProvider {
+		return s.provider.Close()
+	}
+	return nil
+}
+
+// path returns the file of `name` with the given extension
+func (s *DocStore) path(name, ext string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+ext)
+}
+
+// load reads the document `name` from the provider, a new one is created
+// if there is none. Nothing is written until the document is saved.
+func (s *DocStore) load(name string) (*openDoc, error) {
+	doc, err := s.provider.LoadDoc(name, s.docOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("load %q: %w", name, err)
+	}
+	data, err := doc.EncodeStateAsUpdateV1(nil)
+	if err != nil {
+		return nil, fmt.Errorf("load %q: %w", name, err)
+	}
+
+	now := time.Now()
+	od := &openDoc{
+		name:     name,
+		doc:      doc,
+		meta:     DocMeta{Name: name, Created: now, Modified: now},
+		size:     int64(len(data)),
+		lastUsed: now,
+		sv:       doc.EncodeStateVectorV1(),
+	}
+	doc.ObserveUpdateV1(func(update []byte) {
+		od.grown += int64(len(update))
+	})
+
+	meta, err := s.readMeta(name)
+	if errors.Is(err, os.ErrNotExist) {
+		// a document without metadata is written on its first save
+		return od, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	od.meta = meta
+	od.hash = hash(data)
+	return od, nil
+}
+
+// save stores the changes made to the document since the last save
+// along with its metadata, nothing is written if it didn't change
+func (s *DocStore) save(od *openDoc) error {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	data, err := od.doc.EncodeStateAsUpdateV1(nil)
+	if err != nil {
+		return fmt.Errorf("save %q: %w", od.name, err)
+	}
+	s.used += int64(len(data)) - od.size
+	od.size = int64(len(data))
+	h := hash(data)
+	if h == od.hash {
+		return nil
+	}
+
+	update, err := od.doc.EncodeStateAsUpdateV1(od.sv)
+	if err != nil {
+		return fmt.Errorf("save %q: %w", od.name, err)
+	}
+	meta := od.meta
+	meta.Modified = time.Now()
+	meta.Size = int64(len(data))
+	encodedMeta, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("save %q: %w", od.name, err)
+	}
+
+	// the update goes first, metadata without a document isn't listed wrongly for long
+	if err := s.provider.StoreUpdate(od.name, update); err != nil {
+		return fmt.Errorf("save %q: %w", od.name, err)
+	}
+	if err := writeFileAtomic(s.dir, s.path(od.name, ".meta"), encodedMeta); err != nil {
+		return fmt.Errorf("save %q: %w", od.name, err)
+	}
+
+	od.meta, od.hash = meta, h
+	od.sv = od.doc.EncodeStateVectorV1()
+	return nil
+}
+
+// shrink evicts the least recently used documents without open handles
+// until the open ones fit the limits, pinned documents may exceed them
+func (s *DocStore) shrink() error {
+	for e := s.lru.Back(); e != nil && (s.lru.Len() > s.maxDocs || s.used > s.budget); {
+		prev := e.Prev()
+		if e.Value.(*openDoc).refs == 0 {
+			if err := s.evict(e); err != nil {
+				return err
+			}
+		}
+		e = prev
+	}
+	return nil
+}
+
+// evict saves and drops an open document
+func (s *DocStore) evict(e *list.Element) error {
+	if err := s.save(e.Value.(*openDoc)); err != nil {
+		return err
+	}
+	s.drop(e)
+	return nil
+}
+
+// drop forgets an open document without saving it,
+// handles to it return ErrDocClosed from then on
+func (s *DocStore) drop(e *list.Element) {
+	od := s.lru.Remove(e).(*openDoc)
+	delete(s.open, od.name)
+	s.used -= od.size
+
+	od.mu.Lock()
+	od.closed = true
+	od.mu.Unlock()
+}
+
+// readMeta reads the stored metadata of `name`
+func (s *DocStore) readMeta(name string) (DocMeta, error) {
+	data, err := os.ReadFile(s.path(name, ".meta"))
+	if err != nil {
+		return DocMeta{}, err
+	}
+
+	var meta DocMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return DocMeta{}, fmt.Errorf("read metadata of %q: %w", name, err)
+	}
+	return meta, nil
+}
+
+func hash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// writeFileAtomic replaces the file at path with data, a crash
+// leaves either the old or the new content behind
+func writeFileAtomic(dir, path string, data []byte) error {
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
@@ -0,0 +1,239 @@
+package persistence_test
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/amoghyermalkar123/ygo/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insert opens `name`, inserts text at the start of its "text" and closes the handle
+func insert(t *testing.T, s *persistence.DocStore, name, text string) {
+	t.Helper()
+	h, err := s.Open(name)
+	require.NoError(t, err)
+	defer h.Close()
+	require.NoError(t, h.Do(func(doc *ygo.YDoc) error {
+		return doc.GetText("text").InsertText(0, text, nil)
+	}))
+}
+
+// content opens `name` and returns its "text" along with the document itself
+func content(t *testing.T, s *persistence.DocStore, name string) (string, *ygo.YDoc) {
+	t.Helper()
+	h, err := s.Open(name)
+	require.NoError(t, err)
+	defer h.Close()
+
+	var text string
+	var doc *ygo.YDoc
+	require.NoError(t, h.Do(func(d *ygo.YDoc) error {
+		text, doc = d.GetText("text").Content(), d
+		return nil
+	}))
+	return text, doc
+}
+
+// TestDocStore_OpenAndList tests documents surviving a restart along with their metadata
+func TestDocStore_OpenAndList(t *testing.T) {
+	dir := t.TempDir()
+	s, err := persistence.NewDocStore(dir)
+	require.NoError(t, err)
+
+	for _, name := range []string{"acme/roadmap", "acme/notes", "globex/notes"} {
+		insert(t, s, name, name)
+	}
+	require.NoError(t, s.Close())
+
+	s, err = persistence.NewDocStore(dir)
+	require.NoError(t, err)
+	defer s.Close()
+
+	metas, err := s.List("acme/")
+	require.NoError(t, err)
+	require.Len(t, metas, 2)
+	assert.Equal(t, "acme/notes", metas[0].Name)
+	assert.Equal(t, "acme/roadmap", metas[1].Name)
+	assert.Positive(t, metas[0].Size)
+	assert.False(t, metas[0].Created.IsZero())
+
+	text, _ := content(t, s, "acme/roadmap")
+	assert.Equal(t, "acme/roadmap", text)
+
+	// saving bumps the modification time only when something changed
+	before, err := s.Stat("acme/roadmap")
+	require.NoError(t, err)
+	require.NoError(t, s.Save("acme/roadmap"))
+	meta, err := s.Stat("acme/roadmap")
+	require.NoError(t, err)
+	assert.Equal(t, before, meta)
+
+	insert(t, s, "acme/roadmap", "v2 ")
+	require.NoError(t, s.Save("acme/roadmap"))
+	meta, err = s.Stat("acme/roadmap")
+	require.NoError(t, err)
+	assert.True(t, meta.Created.Equal(before.Created))
+	assert.True(t, meta.Modified.After(before.Modified))
+	assert.Greater(t, meta.Size, before.Size)
+
+	_, err = s.Open("")
+	assert.ErrorIs(t, err, persistence.ErrInvalidName)
+}
+
+// TestDocStore_Provider tests the updates being stored as v1 through the provider
+func TestDocStore_Provider(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	defer p.Close()
+	s, err := persistence.NewDocStore(dir, persistence.WithProvider(p))
+	require.NoError(t, err)
+
+	insert(t, s, "doc", "abc")
+	require.NoError(t, s.Save("doc"))
+	insert(t, s, "doc", "x")
+	require.NoError(t, s.Close())
+
+	doc, err := p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "xabc", doc.GetText("text").Content())
+}
+
+// TestDocStore_Evict tests evicting the least recently used documents
+func TestDocStore_Evict(t *testing.T) {
+	s, err := persistence.NewDocStore(t.TempDir(), persistence.WithMaxDocs(2))
+	require.NoError(t, err)
+	defer s.Close()
+
+	insert(t, s, "a", "a")
+	_, b := content(t, s, "b")
+	_, a := content(t, s, "a")
+
+	// b is the least recently used one
+	content(t, s, "c")
+	_, again := content(t, s, "a")
+	assert.Same(t, a, again)
+	_, again = content(t, s, "b")
+	assert.NotSame(t, b, again)
+
+	// a is the least recently used one now, it's saved when it's evicted
+	content(t, s, "c")
+	text, a2 := content(t, s, "a")
+	assert.NotSame(t, a, a2)
+	assert.Equal(t, "a", text)
+
+	// idle documents are evicted, recently used ones stay
+	require.NoError(t, s.EvictIdle(time.Hour))
+	_, same := content(t, s, "a")
+	assert.Same(t, a2, same)
+	require.NoError(t, s.EvictIdle(0))
+	_, same = content(t, s, "a")
+	assert.NotSame(t, a2, same)
+}
+
+// TestDocStore_Pinned tests documents with open handles staying in memory
+func TestDocStore_Pinned(t *testing.T) {
+	s, err := persistence.NewDocStore(t.TempDir(), persistence.WithMaxDocs(1))
+	require.NoError(t, err)
+	defer s.Close()
+
+	h, err := s.Open("pinned")
+	require.NoError(t, err)
+	var pinned *ygo.YDoc
+	require.NoError(t, h.Do(func(doc *ygo.YDoc) error {
+		pinned = doc
+		return doc.GetText("text").InsertText(0, "a", nil)
+	}))
+
+	// other documents don't push it out, opening it again shares the document
+	content(t, s, "other")
+	require.NoError(t, s.EvictIdle(0))
+	text, doc := content(t, s, "pinned")
+	assert.Same(t, pinned, doc)
+	assert.Equal(t, "a", text)
+
+	// once the handle is closed it's evicted and saved like any other
+	require.NoError(t, h.Close())
+	require.NoError(t, h.Close())
+	assert.ErrorIs(t, h.Do(func(*ygo.YDoc) error { return nil }), persistence.ErrDocClosed)
+	content(t, s, "other")
+	text, doc = content(t, s, "pinned")
+	assert.NotSame(t, pinned, doc)
+	assert.Equal(t, "a", text)
+
+	// deleting a document closes its handles
+	h, err = s.Open("pinned")
+	require.NoError(t, err)
+	require.NoError(t, s.Delete("pinned"))
+	assert.ErrorIs(t, h.Do(func(*ygo.YDoc) error { return nil }), persistence.ErrDocClosed)
+}
+
+// TestDocStore_Concurrent tests saving while other goroutines edit the same document
+func TestDocStore_Concurrent(t *testing.T) {
+	s, err := persistence.NewDocStore(t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				insert(t, s, "doc", "x")
+				assert.NoError(t, s.Save("doc"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	text, _ := content(t, s, "doc")
+	assert.Len(t, text, 40)
+}
+
+// TestDocStore_MemoryBudget tests evicting documents over the memory budget
+func TestDocStore_MemoryBudget(t *testing.T) {
+	dir := t.TempDir()
+	s, err := persistence.NewDocStore(dir, persistence.WithMemoryBudget(64))
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, small := content(t, s, "small")
+
+	// growing a document is measured without saving it, small is evicted
+	insert(t, s, "big", string(make([]byte, 128)))
+	_, again := content(t, s, "small")
+	assert.NotSame(t, small, again)
+
+	// big alone exceeds the budget, it's evicted once its handle is closed
+	text, big := content(t, s, "big")
+	assert.Len(t, text, 128)
+	text, again = content(t, s, "big")
+	assert.NotSame(t, big, again)
+	assert.Len(t, text, 128)
+}
+
+// TestDocStore_Delete tests deleting a document from memory and storage
+func TestDocStore_Delete(t *testing.T) {
+	s, err := persistence.NewDocStore(t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	insert(t, s, "doc", "abc")
+	require.NoError(t, s.Save("doc"))
+
+	require.NoError(t, s.Delete("doc"))
+	metas, err := s.List("")
+	require.NoError(t, err)
+	assert.Empty(t, metas)
+	_, err = s.Stat("doc")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	text, _ := content(t, s, "doc")
+	assert.Equal(t, "", text)
+}
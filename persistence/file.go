@@ -0,0 +1,389 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/decoder"
+)
+
+// DefaultCompactThreshold is the number of records a log grows
+// to before it is compacted, the one y-leveldb uses as well
+const DefaultCompactThreshold = 500
+
+// every record starts with the length of its payload, the crc32 of the
+// length and the crc32 of the payload, followed by the payload. The
+// length has a checksum of its own so a broken length is told apart
+// from a record torn at the end of the log.
+const headerSize = 12
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileProvider keeps an append-only log of updates per document in a
+// directory. Every update is a checksummed record, once a log holds
+// more records than the threshold they are merged into a single one.
+// A record torn by a crash is cut off before the log is appended to
+// again. Logs are opened for every access and closed right after, so a
+// provider serves any number of documents without holding on to files.
+type FileProvider struct {
+	dir       string
+	threshold int
+
+	mu sync.Mutex
+	// records is the number of records in the logs appended to
+	// so far, their torn ends were cut off by then
+	records map[string]int
+}
+
+// FileOption configures a FileProvider
+type FileOption func(*FileProvider)
+
+// WithCompactThreshold sets the number of records after which a log
+// is compacted, zero or less turns automatic compaction off
+func WithCompactThreshold(n int) FileOption {
+	return func(p *FileProvider) {
+		p.threshold = n
+	}
+}
+
+// NewFileProvider returns a provider storing its logs in dir,
+// the directory is created if it doesn't exist
+func NewFileProvider(dir string, opts ...FileOption) (*FileProvider, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	p := &FileProvider{
+		dir:       dir,
+		threshold: DefaultCompactThreshold,
+		records:   make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// StoreUpdate appends the update to the log of `docName` and syncs it
+// to disk, the log is compacted once it grew past the threshold. Updates
+// that don't decode are refused, they would make the log unreadable.
+func (p *FileProvider) StoreUpdate(docName string, update []byte) error {
+	if _, err := decoder.DecodeUpdateV1(update); err != nil {
+		return fmt.Errorf("store update of %q: %w", docName, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, records, err := p.openAppend(docName)
+	if err != nil {
+		return err
+	}
+	err = writeRecord(f, update)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("store update of %q: %w", docName, err)
+	}
+	p.records[docName] = records + 1
+
+	if p.threshold > 0 && records+1 > p.threshold {
+		return p.compact(docName)
+	}
+	return nil
+}
+
+// LoadDoc returns a new document with every update of `docName` applied,
+// reading an unknown document doesn't create its log
+func (p *FileProvider) LoadDoc(docName string, opts ...ygo.Option) (*ygo.YDoc, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	records, err := p.read(docName)
+	if err != nil {
+		return nil, err
+	}
+	return apply(docName, records, opts...)
+}
+
+// GetStateVector returns the v1 state vector of the stored document
+func (p *FileProvider) GetStateVector(docName string) ([]byte, error) {
+	doc, err := p.LoadDoc(docName)
+	if err != nil {
+		return nil, err
+	}
+	return doc.EncodeStateVectorV1(), nil
+}
+
+// Compact merges the log of `docName` into a single record
+func (p *FileProvider) Compact(docName string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.compact(docName)
+}
+
+// ClearDocument deletes the log of `docName`
+func (p *FileProvider) ClearDocument(docName string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.records, docName)
+	err := os.Remove(p.path(docName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Close forgets what the provider knows about the logs, no
+// files are held open between calls
+func (p *FileProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clear(p.records)
+	return nil
+}
+
+// path returns the file of the log of `docName`, the name is escaped
+// so it can't point outside of the directory
+func (p *FileProvider) path(docName string) string {
+	return filepath.Join(p.dir, url.PathEscape(docName)+".ylog")
+}
+
+// openAppend opens the log of `docName` for appending, creating it if
+// necessary, and returns the number of records in it. A log appended to
+// for the first time has its torn end cut off.
+func (p *FileProvider) openAppend(docName string) (*os.File, int, error) {
+	f, err := os.OpenFile(p.path(docName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	if records, ok := p.records[docName]; ok {
+		return f, records, nil
+	}
+
+	records, err := recoverLog(f)
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("open log of %q: %w", docName, err)
+	}
+	p.records[docName] = records
+	return f, records, nil
+}
+
+// read returns the intact records of the log of `docName`,
+// there are none if the log doesn't exist
+func (p *FileProvider) read(docName string) ([][]byte, error) {
+	f, err := os.Open(p.path(docName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, _, err := readRecords(f)
+	if err != nil {
+		return nil, fmt.Errorf("read log of %q: %w", docName, err)
+	}
+	return records, nil
+}
+
+// compact replaces the log with a single record holding the merged
+// update. The new log is written next to the old one and renamed over
+// it, a crash leaves either of them behind but never a mix.
+func (p *FileProvider) compact(docName string) error {
+	records, err := p.read(docName)
+	if err != nil {
+		return err
+	}
+	if len(records) <= 1 {
+		return nil
+	}
+
+	// deleted content is kept, it's up to the reader to collect it
+	doc, err := apply(docName, records, ygo.WithGC(false))
+	if err != nil {
+		return err
+	}
+
+	// updates still waiting for their dependencies aren't part of the
+	// document, the records are merged without one then
+	var merged []byte
+	if hasPending(doc) {
+		merged, err = mergeRecords(records)
+	} else {
+		merged, err = doc.EncodeStateAsUpdateV1(nil)
+	}
+	if err != nil {
+		return fmt.Errorf("compact log of %q: %w", docName, err)
+	}
+
+	path := p.path(docName)
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := writeRecord(tmp, merged); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("compact log of %q: %w", docName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(p.dir)
+
+	p.records[docName] = 1
+	return nil
+}
+
+// apply applies the records to a new document
+func apply(docName string, records [][]byte, opts ...ygo.Option) (*ygo.YDoc, error) {
+	doc := ygo.NewYDoc(opts...)
+	for i, update := range records {
+		if err := doc.ApplyUpdateV1(update); err != nil {
+			return nil, fmt.Errorf("load %q: record %d: %w", docName, i, err)
+		}
+	}
+	return doc, nil
+}
+
+// mergeRecords merges the updates of the records into one,
+// the updates don't need to have their dependencies
+func mergeRecords(records [][]byte) ([]byte, error) {
+	updates := make([]*block.Updates, len(records))
+	for i, record := range records {
+		var err error
+		if updates[i], err = decoder.DecodeUpdateV1(record); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	return block.MergeUpdates(updates...).MarshalV1()
+}
+
+// hasPending reports whether any block or deletion of
+// doc still waits for the blocks it depends on
+func hasPending(doc *ygo.YDoc) bool {
+	for _, u := range doc.GetPendingUpdates() {
+		for _, blocks := range u.Updates {
+			if len(blocks) > 0 {
+				return true
+			}
+		}
+	}
+	return len(doc.GetPendingDeletes()) > 0
+}
+
+// recoverLog cuts off a torn record at the end of the log and
+// returns the number of records left
+func recoverLog(f *os.File) (int, error) {
+	records, end, err := readRecords(f)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if end < info.Size() {
+		if err := f.Truncate(end); err != nil {
+			return 0, err
+		}
+		if err := f.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// readRecords reads the payloads of all intact records and returns the
+// offset the last of them ends at. The last record is allowed to be torn:
+// cut short by the end of the log, or failing the checksum of its payload
+// right at the end of it. Any other broken record is corruption.
+func readRecords(f *os.File) ([][]byte, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records [][]byte
+	var off int64
+	for rest := data; len(rest) > 0; {
+		if len(rest) < headerSize {
+			break
+		}
+		size := binary.LittleEndian.Uint32(rest[0:4])
+		if crc32.Checksum(rest[0:4], crcTable) != binary.LittleEndian.Uint32(rest[4:8]) {
+			return nil, 0, fmt.Errorf("offset %d: length: %w", off, ErrCorrupt)
+		}
+		sum := binary.LittleEndian.Uint32(rest[8:12])
+		if uint64(size) > uint64(len(rest)-headerSize) {
+			break
+		}
+
+		payload := rest[headerSize : headerSize+int(size)]
+		rest = rest[headerSize+int(size):]
+		if crc32.Checksum(payload, crcTable) != sum {
+			if len(rest) == 0 {
+				break
+			}
+			return nil, 0, fmt.Errorf("offset %d: %w", off, ErrCorrupt)
+		}
+
+		records = append(records, payload)
+		off += headerSize + int64(size)
+	}
+	return records, off, nil
+}
+
+// writeRecord appends a record with the payload and syncs it to disk
+func writeRecord(f *os.File, payload []byte) error {
+	buf := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(buf[0:4], crcTable))
+	binary.LittleEndian.PutUint32(buf[8:12], crc32.Checksum(payload, crcTable))
+	copy(buf[headerSize:], payload)
+
+	if _, err := f.Write(buf); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// syncDir makes a rename in dir durable, it's best effort
+// since not every platform can sync directories
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
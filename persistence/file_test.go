@@ -0,0 +1,284 @@
+package persistence_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/amoghyermalkar123/ygo/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// edit inserts text into doc and returns the update of just that edit
+func edit(t *testing.T, doc *ygo.YDoc, pos int64, text string) []byte {
+	t.Helper()
+
+	sv := doc.EncodeStateVectorV1()
+	require.NoError(t, doc.GetText("text").InsertText(pos, text, nil))
+	update, err := doc.EncodeStateAsUpdateV1(sv)
+	require.NoError(t, err)
+	return update
+}
+
+// logFile returns the only log in dir
+func logFile(t *testing.T, dir string) string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ylog"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	return files[0]
+}
+
+// TestFileProvider_StoreAndLoad tests that stored updates survive reopening
+func TestFileProvider_StoreAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+
+	doc := ygo.NewYDoc()
+	require.NoError(t, p.StoreUpdate("notes/1", edit(t, doc, 0, "Hello")))
+	require.NoError(t, p.StoreUpdate("notes/1", edit(t, doc, 5, " World")))
+	require.NoError(t, p.Close())
+
+	p, err = persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	defer p.Close()
+
+	loaded, err := p.LoadDoc("notes/1")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello World", loaded.GetText("text").Content())
+
+	sv, err := p.GetStateVector("notes/1")
+	require.NoError(t, err)
+	assert.Equal(t, doc.EncodeStateVectorV1(), sv)
+
+	empty, err := p.LoadDoc("unknown")
+	require.NoError(t, err)
+	assert.Equal(t, "", empty.GetText("text").Content())
+
+	// the name can't escape the directory
+	require.NoError(t, p.StoreUpdate("../escape", edit(t, ygo.NewYDoc(), 0, "x")))
+	_, err = os.Stat(filepath.Join(filepath.Dir(dir), "escape.ylog"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestFileProvider_Compact tests merging the log once it grew past the threshold
+func TestFileProvider_Compact(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir, persistence.WithCompactThreshold(10))
+	require.NoError(t, err)
+	defer p.Close()
+
+	doc := ygo.NewYDoc()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.StoreUpdate("doc", edit(t, doc, int64(i), "a")))
+	}
+	sv := doc.EncodeStateVectorV1()
+	require.NoError(t, doc.GetText("text").DeleteText(0, 5))
+	update, err := doc.EncodeStateAsUpdateV1(sv)
+	require.NoError(t, err)
+
+	before, err := os.Stat(logFile(t, dir))
+	require.NoError(t, err)
+
+	// the eleventh record triggers the compaction
+	require.NoError(t, p.StoreUpdate("doc", update))
+	after, err := os.Stat(logFile(t, dir))
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+
+	loaded, err := p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaa", loaded.GetText("text").Content())
+
+	// appending after a compaction still works
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 0, "b")))
+	require.NoError(t, p.Compact("doc"))
+	loaded, err = p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "baaaaa", loaded.GetText("text").Content())
+}
+
+// TestFileProvider_Recovery tests cutting off a torn last record
+func TestFileProvider_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+
+	doc := ygo.NewYDoc()
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 0, "abc")))
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 3, "def")))
+	require.NoError(t, p.Close())
+
+	// a crash in the middle of writing the second record
+	path := logFile(t, dir)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-2))
+
+	p, err = persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	loaded, err := p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", loaded.GetText("text").Content())
+
+	// the torn record is gone, new ones are appended after the intact ones
+	require.NoError(t, p.StoreUpdate("doc", edit(t, ygo.NewYDoc(), 0, "x")))
+	require.NoError(t, p.Close())
+
+	p, err = persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	defer p.Close()
+	loaded, err = p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Len(t, loaded.GetText("text").Content(), 4)
+}
+
+// TestFileProvider_Corrupt tests that a broken record in the middle is an error
+func TestFileProvider_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+
+	doc := ygo.NewYDoc()
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 0, "abc")))
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 3, "def")))
+	require.NoError(t, p.Close())
+
+	path := logFile(t, dir)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[10] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	p, err = persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	defer p.Close()
+	_, err = p.LoadDoc("doc")
+	assert.ErrorIs(t, err, persistence.ErrCorrupt)
+}
+
+// TestFileProvider_ClearDocument tests deleting a stored document
+func TestFileProvider_ClearDocument(t *testing.T) {
+	p, err := persistence.NewFileProvider(t.TempDir())
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.StoreUpdate("doc", edit(t, ygo.NewYDoc(), 0, "abc")))
+	require.NoError(t, p.ClearDocument("doc"))
+	require.NoError(t, p.ClearDocument("doc"))
+
+	loaded, err := p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "", loaded.GetText("text").Content())
+}
+
+// TestFileProvider_CorruptLength tests that a broken length in the
+// middle of the log is an error instead of cutting off what follows
+func TestFileProvider_CorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+
+	doc := ygo.NewYDoc()
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 0, "abc")))
+	require.NoError(t, p.StoreUpdate("doc", edit(t, doc, 3, "def")))
+	require.NoError(t, p.Close())
+
+	// the length of the first record now points past the end of the log
+	path := logFile(t, dir)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[2] = 0xff
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	p, err = persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	defer p.Close()
+	_, err = p.LoadDoc("doc")
+	assert.ErrorIs(t, err, persistence.ErrCorrupt)
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, after)
+}
+
+// TestFileProvider_InvalidUpdate tests that updates that don't decode aren't stored
+func TestFileProvider_InvalidUpdate(t *testing.T) {
+	p, err := persistence.NewFileProvider(t.TempDir())
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.StoreUpdate("doc", edit(t, ygo.NewYDoc(), 0, "abc")))
+	assert.Error(t, p.StoreUpdate("doc", []byte{1, 1, 1, 0, 4, 1, 4, 't', 'e'}))
+
+	loaded, err := p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", loaded.GetText("text").Content())
+	require.NoError(t, p.Compact("doc"))
+}
+
+// TestFileProvider_CompactPending tests compacting updates that
+// wait for an update that wasn't stored yet
+func TestFileProvider_CompactPending(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir, persistence.WithCompactThreshold(5))
+	require.NoError(t, err)
+	defer p.Close()
+
+	doc := ygo.NewYDoc()
+	first := edit(t, doc, 0, "a")
+	for i := 1; i < 10; i++ {
+		require.NoError(t, p.StoreUpdate("doc", edit(t, doc, int64(i), "b")))
+	}
+
+	// every record is merged into one even though none can be applied
+	data, err := os.ReadFile(logFile(t, dir))
+	require.NoError(t, err)
+	require.NoError(t, p.Compact("doc"))
+	compacted, err := os.ReadFile(logFile(t, dir))
+	require.NoError(t, err)
+	assert.Less(t, len(compacted), len(data))
+
+	loaded, err := p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "", loaded.GetText("text").Content())
+
+	require.NoError(t, p.StoreUpdate("doc", first))
+	loaded, err = p.LoadDoc("doc")
+	require.NoError(t, err)
+	assert.Equal(t, "abbbbbbbbb", loaded.GetText("text").Content())
+}
+
+// TestFileProvider_NoOpenFiles tests that reading unknown documents creates
+// nothing and that logs aren't held open between calls
+func TestFileProvider_NoOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	p, err := persistence.NewFileProvider(dir)
+	require.NoError(t, err)
+	defer p.Close()
+
+	_, err = p.LoadDoc("unknown")
+	require.NoError(t, err)
+	_, err = p.GetStateVector("unknown")
+	require.NoError(t, err)
+	require.NoError(t, p.Compact("unknown"))
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open files can't be counted here")
+	}
+	for i := 0; i < 100; i++ {
+		require.NoError(t, p.StoreUpdate(fmt.Sprint("doc", i), edit(t, ygo.NewYDoc(), 0, "x")))
+	}
+	after, err := os.ReadDir("/proc/self/fd")
+	require.NoError(t, err)
+	assert.Len(t, after, len(fds))
+}
@@ -0,0 +1,32 @@
+// Package persistence stores the updates of documents so they survive
+// restarts. A Provider keeps the updates of every document under its
+// name and gives back a document holding all of them, a DocStore
+// manages many named documents kept in memory on top of a Provider.
+package persistence
+
+import (
+	"errors"
+
+	"github.com/amoghyermalkar123/ygo"
+)
+
+// ErrCorrupt is returned when stored data fails its checksum
+// somewhere other than at the very end of a log
+var ErrCorrupt = errors.New("persistence: corrupt record")
+
+// Provider persists the v1 updates of documents by name
+type Provider interface {
+	// StoreUpdate appends a v1 update to the document `docName`
+	StoreUpdate(docName string, update []byte) error
+	// LoadDoc returns a new document with every stored update of
+	// `docName` applied, it is empty for unknown documents
+	LoadDoc(docName string, opts ...ygo.Option) (*ygo.YDoc, error)
+	// GetStateVector returns the v1 state vector of the stored document
+	GetStateVector(docName string) ([]byte, error)
+	// Compact merges the stored updates of `docName` into a single one
+	Compact(docName string) error
+	// ClearDocument deletes everything stored for `docName`
+	ClearDocument(docName string) error
+	// Close releases the resources held by the provider
+	Close() error
+}
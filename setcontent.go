@@ -0,0 +1,72 @@
+package ygo
+
+import (
+	"unicode/utf16"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/diff"
+)
+
+// embed stands for an embed in the characters of a text, no character
+// of a string is negative so it never matches one
+const embed rune = -1
+
+// SetContent changes the text to `text` in one transaction, inserting and
+// deleting the fewest characters possible, or nearly that few once the
+// texts differ in thousands of places. Unlike deleting everything and
+// inserting the new text, the text that stays is left alone, so
+// concurrent edits and positions in it keep working. Embeds aren't part
+// of `text`, SetContent deletes them. Inserted text continues the
+// formatting around it.
+func (t *YText) SetContent(text string) (err error) {
+	var current []rune
+	for blk := t.text.Start; blk != nil; blk = blk.Right {
+		if blk.IsDeleted {
+			continue
+		}
+		switch c := blk.Content.(type) {
+		case *block.ContentString:
+			current = append(current, []rune(c.Str)...)
+		case *block.ContentEmbed:
+			current = append(current, embed)
+		}
+	}
+	next := []rune(text)
+
+	t.doc.Transact(func() {
+		// positions count UTF-16 code units, i and j count runes
+		var pos int64
+		i, j := 0, 0
+		for _, e := range diff.Diff(current, next) {
+			switch e.Op {
+			case diff.Equal:
+				pos += units(current[i : i+e.N])
+				i, j = i+e.N, j+e.N
+			case diff.Delete:
+				err = t.doc.blockStore.Delete(t.text, pos, units(current[i:i+e.N]))
+				i += e.N
+			case diff.Insert:
+				err = t.insert(pos, &block.ContentString{Str: string(next[j : j+e.N])}, nil)
+				pos += units(next[j : j+e.N])
+				j += e.N
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// units returns the length of the characters in UTF-16 code units
+func units(chars []rune) int64 {
+	var n int64
+	for _, r := range chars {
+		if r == embed {
+			n++
+		} else {
+			n += int64(utf16.RuneLen(r))
+		}
+	}
+	return n
+}
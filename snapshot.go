@@ -0,0 +1,160 @@
+package ygo
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// Snapshot is the state of a document at some point, its state vector
+// and delete set. Rendering a snapshot needs the content that was deleted
+// since, so it only works on documents with garbage collection turned off.
+type Snapshot struct {
+	s *blockstore.Snapshot
+}
+
+// Snapshot returns the current state of the document
+func (yd *YDoc) Snapshot() *Snapshot {
+	return &Snapshot{s: yd.blockStore.Snapshot()}
+}
+
+// EncodeV1 encodes the snapshot like the encodeSnapshot of yjs
+func (sn *Snapshot) EncodeV1() []byte {
+	return block.EncodeSnapshotV1(sn.s.DeleteSet, sn.s.StateVector)
+}
+
+// DecodeSnapshotV1 decodes a snapshot encoded by yjs or EncodeV1
+func DecodeSnapshotV1(data []byte) (*Snapshot, error) {
+	ds, sv, err := block.DecodeSnapshotV1(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return &Snapshot{s: &blockstore.Snapshot{StateVector: sv, DeleteSet: ds}}, nil
+}
+
+// ContentAt returns the text as it was at the snapshot, embeds are left out
+func (t *YText) ContentAt(sn *Snapshot) string {
+	var content []byte
+	segments(t.text, func(blk *block.Block, offset, length int) {
+		c, ok := blk.Content.(*block.ContentString)
+		if ok && sn.s.Visible(idAt(blk, offset)) {
+			content = append(content, substring(c, offset, length)...)
+		}
+	}, sn.s)
+	return string(content)
+}
+
+// segments calls fn for every run of the blocks of `t` whose
+// characters share their visibility in each of the snapshots
+func segments(t *blockstore.Type, fn func(blk *block.Block, offset, length int), sns ...*blockstore.Snapshot) {
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		var cuts []int
+		for _, sn := range sns {
+			cuts = append(cuts, sn.Cuts(blk)...)
+		}
+		slices.Sort(cuts)
+		cuts = append(slices.Compact(cuts), blk.Len())
+
+		offset := 0
+		for _, cut := range cuts {
+			fn(blk, offset, cut-offset)
+			offset = cut
+		}
+	}
+}
+
+// idAt returns the ID of the character at offset in blk
+func idAt(blk *block.Block, offset int) block.ID {
+	return block.ID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(offset)}
+}
+
+// substring returns `length` characters of c starting at offset
+func substring(c *block.ContentString, offset, length int) string {
+	right := block.SliceContent(c, offset).(*block.ContentString)
+	right.Splice(length)
+	return right.Str
+}
+
+// DiffOp says what happened to a run of text between two snapshots
+type DiffOp int
+
+const (
+	// DiffRetain is text visible in both snapshots
+	DiffRetain DiffOp = iota
+	// DiffInsert is text inserted after the first snapshot
+	DiffInsert
+	// DiffDelete is text deleted after the first snapshot
+	DiffDelete
+)
+
+func (op DiffOp) String() string {
+	switch op {
+	case DiffRetain:
+		return "retain"
+	case DiffInsert:
+		return "insert"
+	case DiffDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("DiffOp(%d)", int(op))
+}
+
+// DiffRun is a run of text that was retained, inserted or deleted
+type DiffRun struct {
+	Op   DiffOp
+	Text string
+	// Authors are the clients that inserted the text of the run, sorted.
+	// For deleted runs too, they don't name who deleted the text as the
+	// delete set doesn't record it.
+	Authors []int64
+}
+
+// DiffSnapshots returns the text of both snapshots as runs of text that
+// was retained, inserted after prev or deleted after prev, in document
+// order. Neighboring runs with the same op are merged. A nil prev is the
+// empty document, a nil next is the current state. Like rendering
+// snapshots it needs garbage collection turned off, text whose content
+// was collected is left out.
+func (t *YText) DiffSnapshots(prev, next *Snapshot) []DiffRun {
+	prevState := &blockstore.Snapshot{}
+	if prev != nil {
+		prevState = prev.s
+	}
+	if next == nil {
+		next = t.doc.Snapshot()
+	}
+
+	var runs []DiffRun
+	segments(t.text, func(blk *block.Block, offset, length int) {
+		c, ok := blk.Content.(*block.ContentString)
+		if !ok {
+			return
+		}
+
+		id := idAt(blk, offset)
+		var op DiffOp
+		switch before, after := prevState.Visible(id), next.s.Visible(id); {
+		case before && after:
+			op = DiffRetain
+		case after:
+			op = DiffInsert
+		case before:
+			op = DiffDelete
+		default:
+			return
+		}
+
+		text := substring(c, offset, length)
+		if n := len(runs); n > 0 && runs[n-1].Op == op {
+			runs[n-1].Text += text
+			if i, found := slices.BinarySearch(runs[n-1].Authors, blk.ID.Client); !found {
+				runs[n-1].Authors = slices.Insert(runs[n-1].Authors, i, blk.ID.Client)
+			}
+			return
+		}
+		runs = append(runs, DiffRun{Op: op, Text: text, Authors: []int64{blk.ID.Client}})
+	}, prevState, next.s)
+	return runs
+}
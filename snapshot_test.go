@@ -0,0 +1,41 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestYText_DiffSnapshots tests the attributed diff between two snapshots
+func TestYText_DiffSnapshots(t *testing.T) {
+	docA := ygo.NewYDoc(ygo.WithGC(false))
+	docB := ygo.NewYDoc(ygo.WithGC(false))
+	a, b := docA.Client(), docB.Client()
+
+	require.NoError(t, docA.GetText("text").InsertText(0, "Hello World", nil))
+	syncV1(t, docA, docB)
+	yesterday := docA.Snapshot()
+
+	require.NoError(t, docB.GetText("text").DeleteText(0, 6))
+	require.NoError(t, docB.GetText("text").InsertText(5, "!", nil))
+	require.NoError(t, docA.GetText("text").InsertText(6, "Big ", nil))
+	syncV1(t, docA, docB)
+
+	// "Hello " was deleted by b, its authors are the ones that inserted it
+	text := docA.GetText("text")
+	assert.Equal(t, []ygo.DiffRun{
+		{Op: ygo.DiffDelete, Text: "Hello ", Authors: []int64{a}},
+		{Op: ygo.DiffInsert, Text: "Big ", Authors: []int64{a}},
+		{Op: ygo.DiffRetain, Text: "World", Authors: []int64{a}},
+		{Op: ygo.DiffInsert, Text: "!", Authors: []int64{b}},
+	}, text.DiffSnapshots(yesterday, nil))
+
+	// from the empty document everything is inserted
+	assert.Equal(t, []ygo.DiffRun{
+		{Op: ygo.DiffInsert, Text: "Hello World", Authors: []int64{a}},
+	}, text.DiffSnapshots(nil, yesterday))
+
+	assert.Equal(t, "insert", ygo.DiffInsert.String())
+}
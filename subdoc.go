@@ -0,0 +1,262 @@
+package ygo
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// A subdocument is a YDoc stored as a value of another document. The parent
+// only holds a reference by GUID, the content of the subdocument is synced
+// and persisted on its own, so a provider can decide which of them to load.
+
+// ErrSubdocInUse is returned when adding a document that already is a
+// subdocument, or a document to itself
+var ErrSubdocInUse = errors.New("document is already a subdocument")
+
+// SubdocsEvent is passed to subdocument observers of a document after a
+// transaction added, removed or loaded some of its subdocuments
+type SubdocsEvent struct {
+	Added   []*YDoc
+	Removed []*YDoc
+	Loaded  []*YDoc
+}
+
+// subdocState is the part of a YDoc dealing with subdocuments,
+// both as a parent and as a subdocument
+type subdocState struct {
+	// parent and item are the document and the block
+	// holding this document, nil unless it's a subdocument
+	parent     *YDoc
+	item       *block.Block
+	shouldLoad bool
+	autoLoad   bool
+	destroyed  bool
+	// docs holds the subdocuments by the block referencing them
+	docs      map[*block.Block]*YDoc
+	observers observers[*SubdocsEvent]
+}
+
+// WithGUID sets the GUID of the document, subdocuments are referenced
+// by it. Without it the document gets a random one.
+func WithGUID(guid string) Option {
+	return func(yd *YDoc) {
+		yd.guid = guid
+	}
+}
+
+// WithAutoLoad makes replicas load the document right away when it
+// reaches them as a subdocument, instead of waiting for Load
+func WithAutoLoad(autoLoad bool) Option {
+	return func(yd *YDoc) {
+		yd.subdoc.autoLoad = autoLoad
+	}
+}
+
+// GUID returns the globally unique ID of the document
+func (yd *YDoc) GUID() string {
+	return yd.guid
+}
+
+// Parent returns the document holding this one, nil unless it's a subdocument
+func (yd *YDoc) Parent() *YDoc {
+	return yd.subdoc.parent
+}
+
+// ShouldLoad reports whether the content of the document is wanted.
+// Subdocuments received from other replicas start out unloaded
+// unless they were added with WithAutoLoad.
+func (yd *YDoc) ShouldLoad() bool {
+	return yd.subdoc.shouldLoad
+}
+
+// Load asks for the content of a subdocument. The parent tells its
+// subdocument observers so a provider can start syncing it.
+func (yd *YDoc) Load() {
+	if yd.subdoc.shouldLoad {
+		return
+	}
+	yd.subdoc.shouldLoad = true
+
+	if parent := yd.subdoc.parent; parent != nil {
+		parent.subdoc.observers.call(&SubdocsEvent{Loaded: []*YDoc{yd}})
+	}
+}
+
+// Destroy releases a document along with its subdocuments. A destroyed
+// subdocument is replaced in its parent by a fresh unloaded document with
+// the same GUID, which subdocument observers see as removed and added.
+func (yd *YDoc) Destroy() {
+	if yd.subdoc.destroyed {
+		return
+	}
+	yd.destroy()
+
+	parent, item := yd.subdoc.parent, yd.subdoc.item
+	if parent == nil || item.IsDeleted {
+		return
+	}
+
+	fresh := parent.newSubdoc(item, item.Content.(*block.ContentDoc))
+	fresh.subdoc.shouldLoad = false
+	parent.subdoc.observers.call(&SubdocsEvent{
+		Added:   []*YDoc{fresh},
+		Removed: []*YDoc{yd},
+	})
+}
+
+func (yd *YDoc) destroy() {
+	yd.subdoc.destroyed = true
+	for _, sub := range yd.subdoc.docs {
+		sub.destroy()
+	}
+}
+
+// Subdocs returns the subdocuments of the document sorted by GUID
+func (yd *YDoc) Subdocs() []*YDoc {
+	var docs []*YDoc
+	for blk, sub := range yd.subdoc.docs {
+		if !blk.IsDeleted {
+			docs = append(docs, sub)
+		}
+	}
+	slices.SortFunc(docs, func(a, b *YDoc) int { return strings.Compare(a.guid, b.guid) })
+	return docs
+}
+
+// ObserveSubdocs calls fn after every transaction that added, removed or
+// loaded subdocuments. It returns a function that removes the observer.
+func (yd *YDoc) ObserveSubdocs(fn func(*SubdocsEvent)) (unobserve func()) {
+	return yd.subdoc.observers.add(fn)
+}
+
+// SetDoc sets key to the subdocument `doc`
+func (m *YMap) SetDoc(key string, doc *YDoc) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if err := m.doc.checkSubdoc(doc); err != nil {
+		return err
+	}
+
+	m.doc.Transact(func() {
+		m.doc.blockStore.SetMapValue(m.m, key, subdocContent(doc))
+		m.doc.adoptSubdoc(m.m.Map[key], doc)
+	})
+	return nil
+}
+
+// InsertDoc inserts the subdocument `doc` at index
+func (a *YArray) InsertDoc(index int64, doc *YDoc) (err error) {
+	if index < 0 || index > a.Length() {
+		return fmt.Errorf("insert at %d: index out of range [0, %d]", index, a.Length())
+	}
+	if err := a.doc.checkSubdoc(doc); err != nil {
+		return err
+	}
+
+	a.doc.Transact(func() {
+		var pos *block.BlockTextListPosition
+		if pos, err = a.doc.blockStore.FindPosition(a.array, index); err != nil {
+			return
+		}
+		a.doc.adoptSubdoc(a.doc.blockStore.InsertAt(a.array, pos, subdocContent(doc)), doc)
+	})
+	return err
+}
+
+func (yd *YDoc) checkSubdoc(doc *YDoc) error {
+	if doc == yd || doc.subdoc.parent != nil {
+		return ErrSubdocInUse
+	}
+	return nil
+}
+
+// subdocContent returns the content referencing `doc`
+// with the options replicas need to create it
+func subdocContent(doc *YDoc) *block.ContentDoc {
+	opts := make(map[string]any)
+	if !doc.blockStore.GC {
+		opts["gc"] = false
+	}
+	if doc.subdoc.autoLoad {
+		opts["autoLoad"] = true
+	}
+	return &block.ContentDoc{GUID: doc.guid, Opts: opts}
+}
+
+func (yd *YDoc) adoptSubdoc(blk *block.Block, doc *YDoc) {
+	doc.subdoc.parent = yd
+	doc.subdoc.item = blk
+	yd.subdoc.docs[blk] = doc
+}
+
+// subdocOf returns the subdocument referenced by `blk`,
+// creating it for blocks that came from other replicas
+func (yd *YDoc) subdocOf(blk *block.Block) *YDoc {
+	if doc, ok := yd.subdoc.docs[blk]; ok {
+		return doc
+	}
+	return yd.newSubdoc(blk, blk.Content.(*block.ContentDoc))
+}
+
+func (yd *YDoc) newSubdoc(blk *block.Block, c *block.ContentDoc) *YDoc {
+	opts := []Option{WithGUID(c.GUID)}
+	// like in yjs, subdocuments collect garbage unless told not to
+	gc, ok := c.Opts["gc"].(bool)
+	opts = append(opts, WithGC(gc || !ok))
+	autoLoad, _ := c.Opts["autoLoad"].(bool)
+	shouldLoad, _ := c.Opts["shouldLoad"].(bool)
+	opts = append(opts, WithAutoLoad(autoLoad))
+
+	doc := NewYDoc(opts...)
+	doc.subdoc.shouldLoad = shouldLoad || autoLoad
+	yd.adoptSubdoc(blk, doc)
+	return doc
+}
+
+// emitSubdocs tells the subdocument observers what the transaction
+// did to subdocuments. Subdocuments added and removed by the same
+// transaction never existed as far as they are concerned.
+func (yd *YDoc) emitSubdocs(txn *blockstore.Transaction) {
+	e := &SubdocsEvent{}
+
+	for _, blk := range txn.DocsAdded {
+		if blk.IsDeleted {
+			continue
+		}
+		doc := yd.subdocOf(blk)
+		e.Added = append(e.Added, doc)
+		if doc.subdoc.shouldLoad {
+			e.Loaded = append(e.Loaded, doc)
+		}
+	}
+
+	for _, blk := range txn.DocsRemoved {
+		doc, ok := yd.subdoc.docs[blk]
+		delete(yd.subdoc.docs, blk)
+		if !ok || txn.Adds(blk) {
+			continue
+		}
+		doc.destroy()
+		e.Removed = append(e.Removed, doc)
+	}
+
+	if len(e.Added) > 0 || len(e.Removed) > 0 || len(e.Loaded) > 0 {
+		yd.subdoc.observers.call(e)
+	}
+}
+
+// newGUID returns a random version 4 UUID
+func newGUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,106 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubdocs_Local tests adding and removing subdocuments on a single doc
+func TestSubdocs_Local(t *testing.T) {
+	doc := ygo.NewYDoc()
+	pages := doc.GetMap("pages")
+
+	var events []*ygo.SubdocsEvent
+	unobserve := doc.ObserveSubdocs(func(e *ygo.SubdocsEvent) {
+		events = append(events, e)
+	})
+	defer unobserve()
+
+	page := ygo.NewYDoc(ygo.WithGUID("page-1"))
+	require.NoError(t, pages.SetDoc("welcome", page))
+
+	require.Len(t, events, 1)
+	assert.Equal(t, []*ygo.YDoc{page}, events[0].Added)
+	assert.Equal(t, []*ygo.YDoc{page}, events[0].Loaded)
+	assert.Same(t, doc, page.Parent())
+	assert.Equal(t, []*ygo.YDoc{page}, doc.Subdocs())
+
+	v, ok := pages.Get("welcome")
+	require.True(t, ok)
+	assert.Same(t, page, v)
+
+	// a document can only live in one place
+	assert.ErrorIs(t, pages.SetDoc("again", page), ygo.ErrSubdocInUse)
+	assert.ErrorIs(t, doc.GetArray("list").InsertDoc(0, doc), ygo.ErrSubdocInUse)
+
+	pages.Delete("welcome")
+	require.Len(t, events, 2)
+	assert.Equal(t, []*ygo.YDoc{page}, events[1].Removed)
+	assert.Empty(t, doc.Subdocs())
+}
+
+// TestSubdocs_LazyLoading tests that subdocuments reach replicas
+// unloaded and their content is synced on its own
+func TestSubdocs_LazyLoading(t *testing.T) {
+	docA := ygo.NewYDoc()
+	pageA := ygo.NewYDoc(ygo.WithGUID("page-1"))
+	require.NoError(t, docA.GetArray("pages").InsertDoc(0, pageA))
+	require.NoError(t, docA.GetArray("pages").InsertDoc(1, ygo.NewYDoc(ygo.WithGUID("page-2"), ygo.WithAutoLoad(true))))
+	require.NoError(t, pageA.GetText("body").InsertText(0, "Hello", nil))
+
+	docB := ygo.NewYDoc()
+	var events []*ygo.SubdocsEvent
+	docB.ObserveSubdocs(func(e *ygo.SubdocsEvent) {
+		events = append(events, e)
+	})
+
+	syncV1(t, docA, docB)
+	require.Len(t, events, 1)
+	require.Len(t, events[0].Added, 2)
+	require.Len(t, events[0].Loaded, 1)
+	assert.Equal(t, "page-2", events[0].Loaded[0].GUID())
+
+	v, ok := docB.GetArray("pages").Get(0)
+	require.True(t, ok)
+	pageB := v.(*ygo.YDoc)
+	assert.Equal(t, "page-1", pageB.GUID())
+	assert.False(t, pageB.ShouldLoad())
+	// the parent only references the page, its content is not in the update
+	assert.Equal(t, "", pageB.GetText("body").Content())
+
+	pageB.Load()
+	require.Len(t, events, 2)
+	assert.Equal(t, []*ygo.YDoc{pageB}, events[1].Loaded)
+	assert.True(t, pageB.ShouldLoad())
+
+	syncV1(t, pageA, pageB)
+	assert.Equal(t, "Hello", pageB.GetText("body").Content())
+}
+
+// TestSubdocs_Destroy tests that a destroyed subdocument is replaced by a fresh one
+func TestSubdocs_Destroy(t *testing.T) {
+	doc := ygo.NewYDoc()
+	page := ygo.NewYDoc(ygo.WithGUID("page-1"))
+	require.NoError(t, doc.GetMap("pages").SetDoc("welcome", page))
+
+	var events []*ygo.SubdocsEvent
+	doc.ObserveSubdocs(func(e *ygo.SubdocsEvent) {
+		events = append(events, e)
+	})
+
+	page.Destroy()
+	require.Len(t, events, 1)
+	assert.Equal(t, []*ygo.YDoc{page}, events[0].Removed)
+	require.Len(t, events[0].Added, 1)
+
+	fresh := events[0].Added[0]
+	assert.NotSame(t, page, fresh)
+	assert.Equal(t, "page-1", fresh.GUID())
+	assert.False(t, fresh.ShouldLoad())
+
+	v, _ := doc.GetMap("pages").Get("welcome")
+	assert.Same(t, fresh, v)
+}
@@ -0,0 +1,233 @@
+package ygo
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// Versions are kept in the document itself, as entries of an array
+// under a reserved name, so they sync along with everything else.
+// Each entry holds the snapshot of the document when it was created.
+const versionsName = "ygo:versions"
+
+var (
+	// ErrGCEnabled is returned when versions are used on a document
+	// with garbage collection, it drops the content old versions need
+	ErrGCEnabled = errors.New("versions need garbage collection turned off")
+	// ErrVersionNotFound is returned for unknown version IDs
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// Version is a labeled snapshot of the document
+type Version struct {
+	ID       string
+	Label    string
+	Created  time.Time
+	Snapshot *Snapshot
+}
+
+// CreateVersion stores the current state of the document as a version
+func (yd *YDoc) CreateVersion(label string) (*Version, error) {
+	if yd.blockStore.GC {
+		return nil, ErrGCEnabled
+	}
+
+	v := &Version{
+		ID:       newGUID(),
+		Label:    label,
+		Created:  time.UnixMilli(time.Now().UnixMilli()),
+		Snapshot: yd.Snapshot(),
+	}
+
+	err := yd.GetArray(versionsName).Push(map[string]any{
+		"id":       v.ID,
+		"label":    v.Label,
+		"created":  v.Created.UnixMilli(),
+		"snapshot": base64.StdEncoding.EncodeToString(v.Snapshot.EncodeV1()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create version: %w", err)
+	}
+	return v, nil
+}
+
+// ListVersions returns the versions of the document in the order they
+// are stored in, versions created concurrently are ordered like array
+// elements inserted concurrently
+func (yd *YDoc) ListVersions() []*Version {
+	var versions []*Version
+	for _, value := range yd.GetArray(versionsName).ToSlice() {
+		entry, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		v := &Version{}
+		v.ID, _ = entry["id"].(string)
+		v.Label, _ = entry["label"].(string)
+		created, _ := entry["created"].(float64)
+		v.Created = time.UnixMilli(int64(created))
+
+		encoded, _ := entry["snapshot"].(string)
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		if v.Snapshot, err = DecodeSnapshotV1(data); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// RestoreVersion brings every text of the document back to the version
+// `id`. It doesn't reset the document, it deletes the text inserted since
+// and inserts the text deleted since again with the formatting it had.
+// Those are ordinary edits, other replicas converge with them and edits
+// made concurrently are kept. Changes to the formatting of text that
+// remained are not undone, neither are changes to maps and arrays.
+func (yd *YDoc) RestoreVersion(id string) error {
+	if yd.blockStore.GC {
+		return ErrGCEnabled
+	}
+
+	versions := yd.ListVersions()
+	i := slices.IndexFunc(versions, func(v *Version) bool { return v.ID == id })
+	if i < 0 {
+		return fmt.Errorf("restore version %q: %w", id, ErrVersionNotFound)
+	}
+	sn := versions[i].Snapshot
+
+	var err error
+	yd.Transact(func() {
+		for _, t := range yd.texts() {
+			if err = t.restore(sn.s); err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("restore version %q: %w", id, err)
+	}
+	return nil
+}
+
+// texts returns every text of the document that isn't deleted, root
+// types nobody asked for yet count as texts if they hold text content
+func (yd *YDoc) texts() []*YText {
+	var texts []*YText
+
+	for _, name := range slices.Sorted(maps.Keys(yd.blockStore.Types)) {
+		t := yd.blockStore.Types[name]
+		st, ok := yd.types[t]
+		if !ok && holdsText(t) {
+			st = yd.GetText(name)
+		}
+		if text := textOf(st); text != nil {
+			texts = append(texts, text)
+		}
+	}
+
+	for _, t := range yd.blockStore.Nested {
+		if t.Item.IsDeleted {
+			continue
+		}
+		if text := textOf(yd.shared(t)); text != nil {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// textOf returns the text of texts and xml texts, nil for anything else
+func textOf(st sharedType) *YText {
+	switch st := st.(type) {
+	case *YText:
+		return st
+	case *YXmlText:
+		return st.YText
+	}
+	return nil
+}
+
+// holdsText reports whether the list of `t` holds text content
+func holdsText(t *blockstore.Type) bool {
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		switch blk.Content.(type) {
+		case *block.ContentString, *block.ContentFormat, *block.ContentEmbed:
+			return true
+		}
+	}
+	return false
+}
+
+// restoreEdit is a single edit bringing a text back to a snapshot
+type restoreEdit struct {
+	pos int64
+	// either delete characters or insert content
+	delete  int64
+	content block.Content
+	attrs   map[string]any
+}
+
+// restore edits the text so it reads like it did at the snapshot. The
+// edits are planned first, applying them splits the blocks walked.
+func (t *YText) restore(sn *blockstore.Snapshot) error {
+	var edits []restoreEdit
+	var pos int64
+	attrs := make(map[string]any)
+
+	segments(t.text, func(blk *block.Block, offset, length int) {
+		then := sn.Visible(idAt(blk, offset))
+
+		var content block.Content
+		switch c := blk.Content.(type) {
+		case *block.ContentFormat:
+			if then {
+				updateAttributes(attrs, c)
+			}
+			return
+		case *block.ContentString:
+			content = &block.ContentString{Str: substring(c, offset, length)}
+		case *block.ContentEmbed:
+			content = c
+		default:
+			return
+		}
+
+		switch {
+		case !blk.IsDeleted && then:
+			pos += int64(length)
+		case !blk.IsDeleted:
+			if n := len(edits); n > 0 && edits[n-1].content == nil && edits[n-1].pos == pos {
+				edits[n-1].delete += int64(length)
+				return
+			}
+			edits = append(edits, restoreEdit{pos: pos, delete: int64(length)})
+		case then:
+			edits = append(edits, restoreEdit{pos: pos, content: content, attrs: maps.Clone(attrs)})
+			pos += int64(length)
+		}
+	}, sn)
+
+	for _, e := range edits {
+		var err error
+		if e.content == nil {
+			err = t.doc.blockStore.Delete(t.text, e.pos, e.delete)
+		} else {
+			err = t.insert(e.pos, e.content, e.attrs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
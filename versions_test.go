@@ -0,0 +1,83 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVersions_Restore tests restoring a version with ordinary edits
+func TestVersions_Restore(t *testing.T) {
+	doc := ygo.NewYDoc(ygo.WithGC(false))
+	text := doc.GetText("text")
+	require.NoError(t, text.InsertText(0, "Hello World", nil))
+	require.NoError(t, text.Format(6, 5, map[string]any{"bold": true}))
+
+	v1, err := doc.CreateVersion("first draft")
+	require.NoError(t, err)
+
+	require.NoError(t, text.DeleteText(0, 6))
+	require.NoError(t, text.InsertText(5, "!", nil))
+	require.NoError(t, text.InsertText(0, "Bye ", map[string]any{}))
+	assert.Equal(t, "Bye World!", text.Content())
+	assert.Equal(t, "Hello World", text.ContentAt(v1.Snapshot))
+
+	_, err = doc.CreateVersion("second draft")
+	require.NoError(t, err)
+
+	versions := doc.ListVersions()
+	require.Len(t, versions, 2)
+	assert.Equal(t, "first draft", versions[0].Label)
+	assert.Equal(t, v1.ID, versions[0].ID)
+	assert.Equal(t, v1.Created, versions[0].Created)
+	assert.Equal(t, "second draft", versions[1].Label)
+
+	require.NoError(t, doc.RestoreVersion(v1.ID))
+	assert.Equal(t, "Hello World", text.Content())
+	assert.Equal(t, []ygo.TextDelta{
+		{Insert: "Hello "},
+		{Insert: "World", Attributes: map[string]any{"bold": true}},
+	}, text.ToDelta())
+	assert.Equal(t, "Bye World!", text.ContentAt(versions[1].Snapshot))
+
+	assert.ErrorIs(t, doc.RestoreVersion("unknown"), ygo.ErrVersionNotFound)
+	_, err = ygo.NewYDoc(ygo.WithGC(true)).CreateVersion("gc")
+	assert.ErrorIs(t, err, ygo.ErrGCEnabled)
+}
+
+// TestVersions_RestoreConcurrent tests that a restore merges with concurrent edits
+func TestVersions_RestoreConcurrent(t *testing.T) {
+	docA := ygo.NewYDoc(ygo.WithGC(false))
+	docB := ygo.NewYDoc(ygo.WithGC(false))
+	require.NoError(t, docA.GetText("text").InsertText(0, "one two", nil))
+	v, err := docA.CreateVersion("v")
+	require.NoError(t, err)
+	require.NoError(t, docA.GetText("text").DeleteText(3, 4))
+	syncV1(t, docA, docB)
+
+	// versions sync along with the document
+	require.Len(t, docB.ListVersions(), 1)
+
+	require.NoError(t, docA.RestoreVersion(v.ID))
+	require.NoError(t, docB.GetText("text").InsertText(3, " three", nil))
+	syncV1(t, docA, docB)
+
+	// the restored " two" and " three" are concurrent inserts at the
+	// same position, their order depends on the client IDs
+	assert.Contains(t, []string{"one three two", "one two three"}, docA.GetText("text").Content())
+	assert.Equal(t, docA.GetText("text").Content(), docB.GetText("text").Content())
+}
+
+// TestSnapshot_EncodeV1 tests encoding snapshots the way yjs does
+func TestSnapshot_EncodeV1(t *testing.T) {
+	// the snapshot of client 1 having inserted "abc" and deleted "b"
+	data := []byte{1, 1, 1, 1, 1, 1, 1, 3}
+	sn, err := ygo.DecodeSnapshotV1(data)
+	require.NoError(t, err)
+	assert.Equal(t, data, sn.EncodeV1())
+
+	_, err = ygo.DecodeSnapshotV1(data[:5])
+	assert.Error(t, err)
+}
@@ -0,0 +1,280 @@
+package ygo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// XmlNode is a child of a YXmlFragment or a YXmlElement,
+// either a *YXmlElement or a *YXmlText
+type XmlNode interface {
+	// ToString serializes the node to XML
+	ToString() string
+}
+
+// YXmlFragment is a list of xml nodes, the root of an xml tree.
+// It is compatible with the Y.XmlFragment of yjs, y-prosemirror
+// for example keeps its document in one.
+type YXmlFragment struct {
+	doc       *YDoc
+	t         *blockstore.Type
+	observers observers[*YXmlEvent]
+}
+
+// YXmlElement is an xml element with a node name, attributes and children
+type YXmlElement struct {
+	YXmlFragment
+}
+
+// YXmlText is the text inside xml elements, its formatting
+// attributes are serialized as nested elements
+type YXmlText struct {
+	*YText
+}
+
+// YXmlEvent is passed to observers of a YXmlFragment or a YXmlElement
+// after a transaction changed its children or attributes
+type YXmlEvent struct {
+	// Target is the changed *YXmlFragment or *YXmlElement
+	Target XmlNode
+	// Local is true when the change was made through this document
+	// and false when it came from ApplyUpdate
+	Local bool
+	// Delta describes the changes of the children like the delta of
+	// a YArrayEvent, inserted children are XmlNodes
+	Delta []ArrayDelta
+	// AttributesChanged lists the attributes of an element that changed
+	AttributesChanged map[string]KeyChange
+}
+
+// GetXmlFragment returns the xml fragment with the given name,
+// creating it if necessary. It panics if the name is already
+// used by a different shared type.
+func (yd *YDoc) GetXmlFragment(name string) *YXmlFragment {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YXmlFragment{doc: yd, t: t}
+	})
+
+	f, ok := st.(*YXmlFragment)
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return f
+}
+
+// InsertElement inserts a new element named `nodeName` at index and returns it
+func (f *YXmlFragment) InsertElement(index int64, nodeName string) (*YXmlElement, error) {
+	st, err := f.doc.insertType(f.t, index, &block.ContentType{TypeRef: block.TypeXmlElement, Name: nodeName})
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YXmlElement), nil
+}
+
+// InsertText inserts a new empty text node at index and returns it
+func (f *YXmlFragment) InsertText(index int64) (*YXmlText, error) {
+	st, err := f.doc.insertType(f.t, index, &block.ContentType{TypeRef: block.TypeXmlText})
+	if err != nil {
+		return nil, err
+	}
+	return st.(*YXmlText), nil
+}
+
+// Delete deletes `length` children starting at index
+func (f *YXmlFragment) Delete(index, length int64) (err error) {
+	if index < 0 || length < 0 || index+length > f.Length() {
+		return fmt.Errorf("delete [%d, %d): range out of bounds [0, %d)", index, index+length, f.Length())
+	}
+
+	f.doc.Transact(func() {
+		err = f.doc.blockStore.Delete(f.t, index, length)
+	})
+	return err
+}
+
+// Length returns the number of children
+func (f *YXmlFragment) Length() int64 {
+	return int64(f.t.Length)
+}
+
+// Get returns the child at index, nil if there is none
+func (f *YXmlFragment) Get(index int64) XmlNode {
+	children := f.Children()
+	if index < 0 || index >= int64(len(children)) {
+		return nil
+	}
+	return children[index]
+}
+
+// Children returns the child nodes in order
+func (f *YXmlFragment) Children() []XmlNode {
+	var children []XmlNode
+	for blk := f.t.Start; blk != nil; blk = blk.Right {
+		if !blk.Visible() {
+			continue
+		}
+		if _, ok := blk.Content.(*block.ContentType); !ok {
+			continue
+		}
+		if node, ok := f.doc.shared(f.doc.blockStore.NestedType(blk)).(XmlNode); ok {
+			children = append(children, node)
+		}
+	}
+	return children
+}
+
+// ToString serializes the children to XML the way yjs does,
+// text is written as it is without escaping
+func (f *YXmlFragment) ToString() string {
+	var sb strings.Builder
+	for _, child := range f.Children() {
+		sb.WriteString(child.ToString())
+	}
+	return sb.String()
+}
+
+// Observe calls fn after every transaction that changed the children
+// of the fragment, or the attributes of an element. It returns a
+// function that removes the observer.
+func (f *YXmlFragment) Observe(fn func(*YXmlEvent)) (unobserve func()) {
+	return f.observers.add(fn)
+}
+
+// ObserveDeep calls fn after every transaction that changed the fragment
+// or a node nested in it, see YMap.ObserveDeep
+func (f *YXmlFragment) ObserveDeep(fn func([]DeepEvent)) (unobserve func()) {
+	return f.doc.observeDeep(f.t, fn)
+}
+
+func (f *YXmlFragment) emit(txn *blockstore.Transaction, keys map[string]struct{}) any {
+	return f.emitTo(f, txn, keys)
+}
+
+func (e *YXmlElement) emit(txn *blockstore.Transaction, keys map[string]struct{}) any {
+	return e.emitTo(e, txn, keys)
+}
+
+// emitTo describes the changed children as a delta and the changed
+// attributes like the keys of a map, "" stands for the children
+func (f *YXmlFragment) emitTo(target XmlNode, txn *blockstore.Transaction, keys map[string]struct{}) any {
+	var delta []ArrayDelta
+	if _, ok := keys[""]; ok {
+		delta, _ = f.doc.sequenceDelta(f.t, txn)
+	}
+	attrs := f.doc.keyChanges(f.t, txn, keys)
+	if len(attrs) == 0 {
+		if len(delta) == 0 {
+			return nil
+		}
+		attrs = nil
+	}
+
+	e := &YXmlEvent{
+		Target:            target,
+		Local:             txn.Local,
+		Delta:             delta,
+		AttributesChanged: attrs,
+	}
+	f.observers.call(e)
+	return e
+}
+
+// NodeName returns the name of the element
+func (e *YXmlElement) NodeName() string {
+	return e.t.Item.Content.(*block.ContentType).Name
+}
+
+// SetAttribute sets the attribute `name` to value, the value is
+// normalized like a map value
+func (e *YXmlElement) SetAttribute(name string, value any) error {
+	if name == "" {
+		return ErrEmptyKey
+	}
+
+	v, err := normalizeValue(value)
+	if err != nil {
+		return fmt.Errorf("set attribute %q: %w", name, err)
+	}
+
+	e.doc.Transact(func() {
+		e.doc.blockStore.SetMapValue(e.t, name, &block.ContentAny{Values: []any{v}})
+	})
+	return nil
+}
+
+// GetAttribute returns the value of the attribute `name` and whether it is set
+func (e *YXmlElement) GetAttribute(name string) (any, bool) {
+	blk := e.t.Map[name]
+	if blk == nil || blk.IsDeleted {
+		return nil, false
+	}
+	return e.doc.mapValue(blk), true
+}
+
+// RemoveAttribute removes the attribute `name`
+func (e *YXmlElement) RemoveAttribute(name string) {
+	e.doc.Transact(func() {
+		e.doc.blockStore.DeleteMapValue(e.t, name)
+	})
+}
+
+// Attributes returns a copy of all attributes
+func (e *YXmlElement) Attributes() map[string]any {
+	attrs := make(map[string]any, len(e.t.Map))
+	for name, blk := range e.t.Map {
+		if !blk.IsDeleted {
+			attrs[name] = e.doc.mapValue(blk)
+		}
+	}
+	return attrs
+}
+
+// ToString serializes the element to XML, attributes sorted by name
+func (e *YXmlElement) ToString() string {
+	nodeName := strings.ToLower(e.NodeName())
+	attrs := e.Attributes()
+
+	var sb strings.Builder
+	sb.WriteString("<" + nodeName)
+	for _, name := range slices.Sorted(maps.Keys(attrs)) {
+		fmt.Fprintf(&sb, " %s=\"%v\"", name, attrs[name])
+	}
+	sb.WriteString(">")
+	sb.WriteString(e.YXmlFragment.ToString())
+	sb.WriteString("</" + nodeName + ">")
+	return sb.String()
+}
+
+// ToString serializes the text to XML, every formatting attribute is
+// written as an element around the text it formats. Object values
+// become the attributes of that element.
+func (x *YXmlText) ToString() string {
+	var sb strings.Builder
+	for _, d := range x.ToDelta() {
+		str, ok := d.Insert.(string)
+		if !ok {
+			continue
+		}
+
+		nodeNames := slices.Sorted(maps.Keys(d.Attributes))
+		for _, nodeName := range nodeNames {
+			sb.WriteString("<" + nodeName)
+			if attrs, ok := d.Attributes[nodeName].(map[string]any); ok {
+				for _, key := range slices.Sorted(maps.Keys(attrs)) {
+					fmt.Fprintf(&sb, " %s=\"%v\"", key, attrs[key])
+				}
+			}
+			sb.WriteString(">")
+		}
+		sb.WriteString(str)
+		for i := len(nodeNames) - 1; i >= 0; i-- {
+			sb.WriteString("</" + nodeNames[i] + ">")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,177 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncV1 syncs two docs both ways with v1 updates, each side
+// only sending what the other one is missing
+func syncV1(t *testing.T, a, b *ygo.YDoc) {
+	t.Helper()
+
+	svA := a.EncodeStateVectorV1()
+	svB := b.EncodeStateVectorV1()
+
+	updateA, err := a.EncodeStateAsUpdateV1(svB)
+	require.NoError(t, err)
+	updateB, err := b.EncodeStateAsUpdateV1(svA)
+	require.NoError(t, err)
+
+	require.NoError(t, b.ApplyUpdateV1(updateA))
+	require.NoError(t, a.ApplyUpdateV1(updateB))
+}
+
+// TestYXml_Build tests building an xml tree and serializing it
+func TestYXml_Build(t *testing.T) {
+	doc := ygo.NewYDoc()
+	frag := doc.GetXmlFragment("prosemirror")
+
+	p, err := frag.InsertElement(0, "paragraph")
+	require.NoError(t, err)
+	require.NoError(t, p.SetAttribute("align", "left"))
+
+	text, err := p.InsertText(0)
+	require.NoError(t, err)
+	require.NoError(t, text.InsertText(0, "Hello World", nil))
+	require.NoError(t, text.Format(6, 5, map[string]any{"bold": true}))
+
+	h, err := frag.InsertElement(0, "heading")
+	require.NoError(t, err)
+	require.NoError(t, h.SetAttribute("level", 1))
+
+	assert.Equal(t, int64(2), frag.Length())
+	assert.Equal(t, "heading", frag.Get(0).(*ygo.YXmlElement).NodeName())
+	assert.Equal(t, `<heading level="1"></heading><paragraph align="left">Hello <bold>World</bold></paragraph>`, frag.ToString())
+
+	p.RemoveAttribute("align")
+	_, ok := p.GetAttribute("align")
+	assert.False(t, ok)
+
+	require.NoError(t, frag.Delete(0, 1))
+	assert.Equal(t, "<paragraph>Hello <bold>World</bold></paragraph>", frag.ToString())
+
+	_, err = frag.InsertElement(5, "paragraph")
+	assert.Error(t, err)
+}
+
+// TestYXml_Sync tests syncing xml trees with v1 updates
+func TestYXml_Sync(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+
+	p, err := docA.GetXmlFragment("xml").InsertElement(0, "p")
+	require.NoError(t, err)
+	text, err := p.InsertText(0)
+	require.NoError(t, err)
+	require.NoError(t, text.InsertText(0, "abc", nil))
+
+	syncV1(t, docA, docB)
+	assert.Equal(t, "<p>abc</p>", docB.GetXmlFragment("xml").ToString())
+
+	// edits on both sides after the first sync
+	pB := docB.GetXmlFragment("xml").Get(0).(*ygo.YXmlElement)
+	require.NoError(t, pB.SetAttribute("class", "intro"))
+	_, err = docA.GetXmlFragment("xml").InsertElement(1, "hr")
+	require.NoError(t, err)
+	require.NoError(t, text.DeleteText(1, 1))
+
+	syncV1(t, docA, docB)
+	assert.Equal(t, `<p class="intro">ac</p><hr></hr>`, docA.GetXmlFragment("xml").ToString())
+	assert.Equal(t, docA.GetXmlFragment("xml").ToString(), docB.GetXmlFragment("xml").ToString())
+}
+
+// TestYXml_Observe tests the events of fragments and elements
+func TestYXml_Observe(t *testing.T) {
+	doc := ygo.NewYDoc()
+	remote := ygo.NewYDoc()
+	frag := doc.GetXmlFragment("xml")
+
+	var events []*ygo.YXmlEvent
+	frag.Observe(func(e *ygo.YXmlEvent) { events = append(events, e) })
+	var deep [][]ygo.DeepEvent
+	frag.ObserveDeep(func(e []ygo.DeepEvent) { deep = append(deep, e) })
+
+	p, err := frag.InsertElement(0, "p")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.True(t, events[0].Local)
+	assert.Equal(t, frag, events[0].Target)
+	assert.Equal(t, []ygo.ArrayDelta{{Insert: []any{p}}}, events[0].Delta)
+	assert.Nil(t, events[0].AttributesChanged)
+
+	var pEvents []*ygo.YXmlEvent
+	p.Observe(func(e *ygo.YXmlEvent) { pEvents = append(pEvents, e) })
+
+	// children and attributes changed in one transaction
+	doc.Transact(func() {
+		require.NoError(t, p.SetAttribute("class", "intro"))
+		_, err = p.InsertText(0)
+		require.NoError(t, err)
+	})
+	require.Len(t, pEvents, 1)
+	assert.Equal(t, p, pEvents[0].Target)
+	require.Len(t, pEvents[0].Delta, 1)
+	assert.IsType(t, &ygo.YXmlText{}, pEvents[0].Delta[0].Insert[0])
+	assert.Equal(t, map[string]ygo.KeyChange{"class": {Action: ygo.ActionAdd}}, pEvents[0].AttributesChanged)
+	assert.Len(t, events, 1)
+	require.Len(t, deep, 2)
+	assert.Equal(t, []any{int64(0)}, deep[1][0].Path)
+	assert.Equal(t, pEvents[0], deep[1][0].Event)
+
+	require.NoError(t, p.SetAttribute("class", "outro"))
+	require.Len(t, pEvents, 2)
+	assert.Nil(t, pEvents[1].Delta)
+	assert.Equal(t, map[string]ygo.KeyChange{"class": {Action: ygo.ActionUpdate, OldValue: "intro"}}, pEvents[1].AttributesChanged)
+
+	// remote changes are observed as well
+	syncV1(t, doc, remote)
+	_, err = remote.GetXmlFragment("xml").InsertElement(1, "hr")
+	require.NoError(t, err)
+	syncV1(t, doc, remote)
+	require.Len(t, events, 2)
+	assert.False(t, events[1].Local)
+	require.Len(t, events[1].Delta, 2)
+	assert.Equal(t, int64(1), events[1].Delta[0].Retain)
+
+	require.NoError(t, frag.Delete(0, 1))
+	require.Len(t, events, 3)
+	assert.Equal(t, []ygo.ArrayDelta{{Delete: 1}}, events[2].Delta)
+}
+
+// TestApplyUpdateV1_Yjs tests applying updates encoded by yjs
+func TestApplyUpdateV1_Yjs(t *testing.T) {
+	// ytext.insert(0, 'abc') by client 1 on the text "text"
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.ApplyUpdateV1([]byte{1, 1, 1, 0, 4, 1, 4, 't', 'e', 'x', 't', 3, 'a', 'b', 'c', 0}))
+	assert.Equal(t, "abc", doc.GetText("text").Content())
+
+	// a paragraph holding the text "hi" by client 1 in the fragment "prosemirror"
+	update := []byte{1, 3, 1, 0}
+	update = append(update, 7, 1, 11)
+	update = append(update, "prosemirror"...)
+	update = append(update, 3, 9)
+	update = append(update, "paragraph"...)
+	update = append(update, 7, 0, 1, 0, 6)
+	update = append(update, 4, 0, 1, 1, 2, 'h', 'i')
+	update = append(update, 0)
+
+	doc = ygo.NewYDoc()
+	require.NoError(t, doc.ApplyUpdateV1(update))
+	assert.Equal(t, "<paragraph>hi</paragraph>", doc.GetXmlFragment("prosemirror").ToString())
+
+	// encoding the doc again yields the same bytes
+	encoded, err := doc.EncodeStateAsUpdateV1(nil)
+	require.NoError(t, err)
+	assert.Equal(t, update, encoded)
+}
+
+// TestApplyUpdateV1_Invalid tests that malformed updates are rejected
+func TestApplyUpdateV1_Invalid(t *testing.T) {
+	doc := ygo.NewYDoc()
+	assert.Error(t, doc.ApplyUpdateV1([]byte{1, 1, 1, 0, 4, 1, 4, 't', 'e'}))
+	assert.Error(t, doc.ApplyUpdateV1([]byte{1, 1, 1, 0, 4, 1, 4, 't', 'e', 'x', 't', 0, 0}))
+}
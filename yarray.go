@@ -0,0 +1,378 @@
+package ygo
+
+import (
+	"fmt"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// YArray is a shared list of JSON values living in a YDoc under a name.
+// Concurrent inserts are ordered the same way as concurrent text.
+type YArray struct {
+	doc       *YDoc
+	array     *blockstore.Type
+	observers observers[*YArrayEvent]
+}
+
+// ArrayDelta is a single step of the changes made to a YArray, exactly
+// one of Insert, Delete and Retain is set. Retain skips over unchanged values.
+type ArrayDelta struct {
+	Insert []any
+	Delete int64
+	Retain int64
+	// Moved is set on the inserts and deletes of moved values,
+	// the values weren't added or removed but changed their place
+	Moved bool
+}
+
+// ArrayMove describes `Length` values moved from index From
+// before the transaction to index To after it
+type ArrayMove struct {
+	From   int64
+	To     int64
+	Length int64
+}
+
+// YArrayEvent is passed to observers of a YArray after a transaction changed it
+type YArrayEvent struct {
+	Target *YArray
+	// Local is true when the change was made through this document
+	// and false when it came from ApplyUpdate
+	Local bool
+	// Delta describes the changes from the start of the array,
+	// trailing unchanged values are left out
+	Delta []ArrayDelta
+	// Moves lists the values that were moved, in the order of their
+	// new index. They show up in Delta as well, flagged as Moved.
+	Moves []ArrayMove
+}
+
+// GetArray returns the array with the given name, creating it if necessary.
+// It panics if the name is already used by a different shared type.
+func (yd *YDoc) GetArray(name string) *YArray {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YArray{doc: yd, array: t}
+	})
+
+	a, ok := st.(*YArray)
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return a
+}
+
+// Name returns the name the array is stored under in the document
+func (a *YArray) Name() string {
+	return a.array.Name
+}
+
+// Insert inserts values at index. Values must be encodable as JSON and are
+// stored in their decoded JSON form, see YMap.Set.
+func (a *YArray) Insert(index int64, values ...any) (err error) {
+	if index < 0 || index > a.Length() {
+		return fmt.Errorf("insert at %d: index out of range [0, %d]", index, a.Length())
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	normalized := make([]any, len(values))
+	for i, value := range values {
+		if normalized[i], err = normalizeValue(value); err != nil {
+			return fmt.Errorf("insert value %d: %w", i, err)
+		}
+	}
+
+	a.doc.Transact(func() {
+		err = a.doc.blockStore.InsertContent(a.array, index, &block.ContentAny{Values: normalized})
+	})
+	return err
+}
+
+// Push appends values to the end of the array
+func (a *YArray) Push(values ...any) error {
+	return a.Insert(a.Length(), values...)
+}
+
+// Delete deletes `length` values starting at index
+func (a *YArray) Delete(index, length int64) (err error) {
+	if index < 0 || length < 0 || index+length > a.Length() {
+		return fmt.Errorf("delete [%d, %d): range out of bounds [0, %d)", index, index+length, a.Length())
+	}
+
+	a.doc.Transact(func() {
+		// values shown at a move are deleted where they were
+		// inserted, otherwise they'd show up there again
+		var moved []block.ID
+		if a.array.HasMoves {
+			for _, e := range a.elements(index, length) {
+				if e.slot {
+					moved = append(moved, e.id)
+				}
+			}
+		}
+
+		if err = a.doc.blockStore.Delete(a.array, index, length); err != nil {
+			return
+		}
+		for _, id := range moved {
+			a.doc.blockStore.DeleteElement(id)
+		}
+	})
+	return err
+}
+
+// Move moves `length` values starting at index to `target`, an index in
+// the array before the move. Values moved concurrently by several clients
+// end up at exactly one of the targets, the same one on every replica.
+func (a *YArray) Move(index, length, target int64) (err error) {
+	if index < 0 || length < 0 || index+length > a.Length() {
+		return fmt.Errorf("move [%d, %d): range out of bounds [0, %d)", index, index+length, a.Length())
+	}
+	if target < 0 || target > a.Length() {
+		return fmt.Errorf("move to %d: index out of range [0, %d]", target, a.Length())
+	}
+	// moving values next to themselves changes nothing
+	if length == 0 || (target >= index && target <= index+length) {
+		return nil
+	}
+
+	a.doc.Transact(func() {
+		var targets []block.ID
+		for _, e := range a.elements(index, length) {
+			targets = append(targets, e.id)
+		}
+
+		var pos *block.BlockTextListPosition
+		if pos, err = a.doc.blockStore.FindPosition(a.array, target); err != nil {
+			return
+		}
+		a.doc.blockStore.InsertAt(a.array, pos, &block.ContentMove{
+			Targets:  targets,
+			Priority: a.array.MovePriority(),
+		})
+		a.doc.blockStore.ResolveMoves(a.array)
+	})
+	return err
+}
+
+// element is a value of the array identified by the ID it was inserted with
+type element struct {
+	id block.ID
+	// slot is set for values shown at a move
+	slot bool
+}
+
+// elements returns the `length` elements starting at index
+func (a *YArray) elements(index, length int64) []element {
+	var elements []element
+	var pos int64
+
+	for blk := a.array.Start; blk != nil && pos < index+length; blk = blk.Right {
+		if !blk.Visible() {
+			continue
+		}
+		for i := 0; i < blk.Len(); i++ {
+			if pos >= index && pos < index+length {
+				elements = append(elements, elementAt(blk, i))
+			}
+			pos++
+		}
+	}
+	return elements
+}
+
+// elementAt returns the i-th element of a block
+func elementAt(blk *block.Block, i int) element {
+	if m, ok := blk.Content.(*block.ContentMove); ok {
+		return element{id: m.Targets[i], slot: true}
+	}
+	return element{id: block.ID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(i)}}
+}
+
+// Get returns the value at index and whether index is within the array
+func (a *YArray) Get(index int64) (any, bool) {
+	values := a.Slice(index, index+1)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// Slice returns the values in [start, end), clamped to the array bounds
+func (a *YArray) Slice(start, end int64) []any {
+	values := []any{}
+	var pos int64
+
+	for blk := a.array.Start; blk != nil && pos < end; blk = blk.Right {
+		if !blk.Visible() {
+			continue
+		}
+		for _, v := range a.doc.values(blk) {
+			if pos >= start && pos < end {
+				values = append(values, v)
+			}
+			pos++
+		}
+	}
+
+	return values
+}
+
+// ToSlice returns all values of the array
+func (a *YArray) ToSlice() []any {
+	return a.Slice(0, a.Length())
+}
+
+// Length returns the number of values in the array
+func (a *YArray) Length() int64 {
+	return int64(a.array.Length)
+}
+
+// Observe calls fn after every transaction that changed the array.
+// It returns a function that removes the observer.
+func (a *YArray) Observe(fn func(*YArrayEvent)) (unobserve func()) {
+	return a.observers.add(fn)
+}
+
+// ObserveDeep calls fn after every transaction that changed the array or
+// any type nested in it, with one event for every changed type.
+// It returns a function that removes the observer.
+func (a *YArray) ObserveDeep(fn func([]DeepEvent)) (unobserve func()) {
+	return a.doc.observeDeep(a.array, fn)
+}
+
+// emit describes the changes of the array as a delta
+func (a *YArray) emit(txn *blockstore.Transaction, _ map[string]struct{}) any {
+	delta, moves := a.doc.sequenceDelta(a.array, txn)
+	if len(delta) == 0 {
+		return nil
+	}
+
+	e := &YArrayEvent{
+		Target: a,
+		Local:  txn.Local,
+		Delta:  delta,
+		Moves:  moves,
+	}
+	a.observers.call(e)
+	return e
+}
+
+// sequenceDelta walks the sequence of `t` once, values added by the
+// transaction become inserts, values it deleted become deletes and
+// everything else is retained. Values that changed their place are
+// flagged as moved.
+func (yd *YDoc) sequenceDelta(t *blockstore.Type, txn *blockstore.Transaction) ([]ArrayDelta, []ArrayMove) {
+	// wasVisible reports whether the block was shown before the transaction
+	wasVisible := func(blk *block.Block) bool {
+		return blk.Content.Countable() && !txn.Adds(blk) &&
+			(!blk.IsDeleted || txn.Deletes(blk)) && !txn.WasMoved(blk)
+	}
+
+	// where every value was shown before the transaction
+	before := make(map[block.ID]int64)
+	var index int64
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		if !wasVisible(blk) {
+			continue
+		}
+		for i := 0; i < blk.Len(); i++ {
+			before[elementAt(blk, i).id] = index
+			index++
+		}
+	}
+
+	var delta []ArrayDelta
+	var current ArrayDelta
+	var moves []ArrayMove
+
+	flush := func() {
+		if current.Insert != nil || current.Delete > 0 || current.Retain > 0 {
+			delta = append(delta, current)
+		}
+		current = ArrayDelta{}
+	}
+
+	index = 0
+	for blk := t.Start; blk != nil; blk = blk.Right {
+		was, is := wasVisible(blk), blk.Visible()
+
+		switch {
+		case was && is:
+			if current.Retain == 0 {
+				flush()
+			}
+			current.Retain += int64(blk.Len())
+		case was:
+			// values that are still there were moved away
+			moved := !blk.IsDeleted
+			if current.Delete == 0 || current.Moved != moved {
+				flush()
+			}
+			current.Delete += int64(blk.Len())
+			current.Moved = moved
+		case is:
+			// values that were there somewhere else before were moved here
+			_, moved := before[elementAt(blk, 0).id]
+			if current.Insert == nil || current.Moved != moved {
+				flush()
+			}
+			current.Insert = append(current.Insert, yd.values(blk)...)
+			current.Moved = moved
+
+			for i := 0; moved && i < blk.Len(); i++ {
+				from, to := before[elementAt(blk, i).id], index+int64(i)
+				if n := len(moves); n > 0 && moves[n-1].From+moves[n-1].Length == from && moves[n-1].To+moves[n-1].Length == to {
+					moves[n-1].Length++
+				} else {
+					moves = append(moves, ArrayMove{From: from, To: to, Length: 1})
+				}
+			}
+		}
+
+		if is {
+			index += int64(blk.Len())
+		}
+	}
+
+	// a trailing retain carries no information
+	if current.Retain == 0 {
+		flush()
+	}
+
+	return delta, moves
+}
+
+// values returns the values held by a block of a map or array,
+// nested types are returned as their shared type
+func (yd *YDoc) values(blk *block.Block) []any {
+	switch c := blk.Content.(type) {
+	case *block.ContentAny:
+		return c.Values
+	case *block.ContentBinary:
+		return []any{c.Data}
+	case *block.ContentEmbed:
+		return []any{c.Embed}
+	case *block.ContentDoc:
+		return []any{yd.subdocOf(blk)}
+	case *block.ContentMove:
+		values := make([]any, 0, len(c.Targets))
+		for _, target := range c.Targets {
+			var v any
+			if moved := yd.blockStore.GetBlock(target); moved != nil {
+				if vs := yd.values(moved); int(target.Clock-moved.ID.Clock) < len(vs) {
+					v = vs[target.Clock-moved.ID.Clock]
+				}
+			}
+			values = append(values, v)
+		}
+		return values
+	case *block.ContentType:
+		if st := yd.shared(yd.blockStore.NestedType(blk)); st != nil {
+			return []any{st}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package ygo_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestYArray_InsertDelete tests the basic array operations on a single doc
+func TestYArray_InsertDelete(t *testing.T) {
+	doc := ygo.NewYDoc()
+	todos := doc.GetArray("todos")
+
+	require.NoError(t, todos.Insert(0, "write", "test"))
+	require.NoError(t, todos.Push(map[string]any{"done": true}))
+	require.NoError(t, todos.Insert(1, "review"))
+
+	assert.Equal(t, []any{"write", "review", "test", map[string]any{"done": true}}, todos.ToSlice())
+	assert.Equal(t, int64(4), todos.Length())
+
+	v, ok := todos.Get(2)
+	assert.True(t, ok)
+	assert.Equal(t, "test", v)
+	_, ok = todos.Get(4)
+	assert.False(t, ok)
+
+	// deleting across blocks splits them
+	require.NoError(t, todos.Delete(1, 2))
+	assert.Equal(t, []any{"write", map[string]any{"done": true}}, todos.ToSlice())
+	assert.Equal(t, []any{map[string]any{"done": true}}, todos.Slice(1, 10))
+
+	assert.Error(t, todos.Insert(5, "x"))
+	assert.Error(t, todos.Delete(1, 2))
+}
+
+// TestYArray_Sync tests concurrent array edits converge
+func TestYArray_Sync(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+
+	require.NoError(t, doc1.GetArray("column").Push(1, 2, 3, 4))
+	sync(t, doc1, doc2)
+
+	require.NoError(t, doc1.GetArray("column").Insert(2, "a"))
+	require.NoError(t, doc2.GetArray("column").Insert(2, "b"))
+	require.NoError(t, doc2.GetArray("column").Delete(0, 1))
+	sync(t, doc1, doc2)
+
+	assert.Equal(t, doc1.GetArray("column").ToSlice(), doc2.GetArray("column").ToSlice())
+	if doc1.Client() < doc2.Client() {
+		assert.Equal(t, []any{float64(2), "a", "b", float64(3), float64(4)}, doc1.GetArray("column").ToSlice())
+	} else {
+		assert.Equal(t, []any{float64(2), "b", "a", float64(3), float64(4)}, doc1.GetArray("column").ToSlice())
+	}
+}
+
+// TestYArray_Observe tests the deltas passed to array observers
+func TestYArray_Observe(t *testing.T) {
+	doc := ygo.NewYDoc()
+	remote := ygo.NewYDoc()
+	arr := doc.GetArray("arr")
+
+	var events []*ygo.YArrayEvent
+	arr.Observe(func(e *ygo.YArrayEvent) {
+		events = append(events, e)
+	})
+
+	require.NoError(t, arr.Push("a", "b", "c"))
+	require.Len(t, events, 1)
+	assert.Equal(t, []ygo.ArrayDelta{{Insert: []any{"a", "b", "c"}}}, events[0].Delta)
+
+	doc.Transact(func() {
+		require.NoError(t, arr.Delete(1, 1))
+		require.NoError(t, arr.Insert(2, "d"))
+	})
+	require.Len(t, events, 2)
+	assert.Equal(t, []ygo.ArrayDelta{
+		{Retain: 1},
+		{Delete: 1},
+		{Retain: 1},
+		{Insert: []any{"d"}},
+	}, events[1].Delta)
+
+	sync(t, doc, remote)
+	require.NoError(t, remote.GetArray("arr").Insert(0, "z"))
+	update, err := remote.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	require.NoError(t, doc.ApplyUpdate(update))
+
+	require.Len(t, events, 3)
+	assert.False(t, events[2].Local)
+	assert.Equal(t, []ygo.ArrayDelta{{Insert: []any{"z"}}}, events[2].Delta)
+	assert.Equal(t, []any{"z", "a", "c", "d"}, arr.ToSlice())
+}
+
+// TestYArray_Move tests moving ranges of values
+func TestYArray_Move(t *testing.T) {
+	doc := ygo.NewYDoc()
+	arr := doc.GetArray("arr")
+	require.NoError(t, arr.Push("a", "b", "c", "d", "e"))
+
+	require.NoError(t, arr.Move(0, 2, 4))
+	assert.Equal(t, []any{"c", "d", "a", "b", "e"}, arr.ToSlice())
+
+	// values can be moved again, from wherever they are
+	require.NoError(t, arr.Move(2, 3, 0))
+	assert.Equal(t, []any{"a", "b", "e", "c", "d"}, arr.ToSlice())
+	assert.Equal(t, int64(5), arr.Length())
+
+	// deleting a moved value deletes it for good
+	require.NoError(t, arr.Delete(0, 1))
+	assert.Equal(t, []any{"b", "e", "c", "d"}, arr.ToSlice())
+
+	assert.Error(t, arr.Move(3, 2, 0))
+	assert.Error(t, arr.Move(0, 1, 5))
+
+	other := ygo.NewYDoc()
+	syncV1(t, doc, other)
+	assert.Equal(t, arr.ToSlice(), other.GetArray("arr").ToSlice())
+	sync(t, doc, other)
+	assert.Equal(t, arr.ToSlice(), other.GetArray("arr").ToSlice())
+}
+
+// TestYArray_ConcurrentMoves tests that a value moved concurrently ends up in one place
+func TestYArray_ConcurrentMoves(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+	require.NoError(t, docA.GetArray("arr").Push("a", "b", "c", "d"))
+	sync(t, docA, docB)
+
+	arrA, arrB := docA.GetArray("arr"), docB.GetArray("arr")
+	require.NoError(t, arrA.Move(0, 1, 4))
+	require.NoError(t, arrB.Move(0, 1, 2))
+	// a move made after seeing another one wins over it
+	require.NoError(t, arrB.Move(3, 1, 0))
+
+	sync(t, docA, docB)
+	assert.Equal(t, arrA.ToSlice(), arrB.ToSlice())
+	assert.Equal(t, int64(4), arrA.Length())
+	assert.ElementsMatch(t, []any{"a", "b", "c", "d"}, arrA.ToSlice())
+	assert.Equal(t, "d", arrA.ToSlice()[0])
+
+	// a value moved by one client and deleted by another is gone
+	require.NoError(t, arrA.Move(1, 1, 4))
+	idx := int64(slices.Index(arrB.ToSlice(), arrA.ToSlice()[3]))
+	require.NoError(t, arrB.Delete(idx, 1))
+	sync(t, docA, docB)
+	assert.Equal(t, arrA.ToSlice(), arrB.ToSlice())
+	assert.Equal(t, int64(3), arrA.Length())
+}
+
+// TestYArray_ObserveMove tests that observers are told about moves
+func TestYArray_ObserveMove(t *testing.T) {
+	doc := ygo.NewYDoc()
+	remote := ygo.NewYDoc()
+	arr := doc.GetArray("arr")
+	require.NoError(t, arr.Push("a", "b", "c", "d"))
+
+	var events []*ygo.YArrayEvent
+	arr.Observe(func(e *ygo.YArrayEvent) {
+		events = append(events, e)
+	})
+	remote.GetArray("arr").Observe(func(e *ygo.YArrayEvent) {
+		events = append(events, e)
+	})
+	sync(t, doc, remote)
+	events = events[:0]
+
+	require.NoError(t, arr.Move(0, 2, 3))
+	require.Len(t, events, 1)
+	assert.Equal(t, []ygo.ArrayDelta{
+		{Delete: 2, Moved: true},
+		{Retain: 1},
+		{Insert: []any{"a", "b"}, Moved: true},
+	}, events[0].Delta)
+	assert.Equal(t, []ygo.ArrayMove{{From: 0, To: 1, Length: 2}}, events[0].Moves)
+
+	// replicas see the same move
+	sync(t, doc, remote)
+	require.Len(t, events, 2)
+	assert.False(t, events[1].Local)
+	assert.Equal(t, events[0].Moves, events[1].Moves)
+	assert.Equal(t, []any{"c", "a", "b", "d"}, remote.GetArray("arr").ToSlice())
+}
@@ -0,0 +1,170 @@
+package ygo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// ErrCounterOverflow is returned for changes that would take a counter,
+// or the totals of a client, past the range of an int64
+var ErrCounterOverflow = errors.New("counter overflows int64")
+
+// YCounter is a shared PN-counter living in a YDoc under a name. Every
+// client keeps the totals of its own increments and decrements under its
+// client ID as [increments, decrements], the value of the counter is the
+// sum over all clients. Since only a client itself ever writes its totals,
+// concurrent updates never conflict. The totals are stored as integers,
+// the legacy JSON update format reads them back as float64 though and
+// loses precision past 2^53.
+type YCounter struct {
+	doc       *YDoc
+	m         *blockstore.Type
+	observers observers[*YCounterEvent]
+}
+
+// YCounterEvent is passed to observers of a YCounter after a
+// transaction changed its value
+type YCounterEvent struct {
+	Target *YCounter
+	// Local is true when the change was made through this document
+	// and false when it came from ApplyUpdate
+	Local    bool
+	OldValue int64
+	Value    int64
+}
+
+// GetCounter returns the counter with the given name, creating it if necessary.
+// It panics if the name is already used by a different shared type.
+func (yd *YDoc) GetCounter(name string) *YCounter {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YCounter{doc: yd, m: t}
+	})
+
+	c, ok := st.(*YCounter)
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return c
+}
+
+// Name returns the name the counter is stored under in the document
+func (c *YCounter) Name() string {
+	return c.m.Name
+}
+
+// Increment adds n to the counter, a negative n decrements it
+func (c *YCounter) Increment(n int64) error {
+	switch {
+	case n == math.MinInt64:
+		return fmt.Errorf("increment by %d: %w", n, ErrCounterOverflow)
+	case n < 0:
+		return c.add(0, -n)
+	default:
+		return c.add(n, 0)
+	}
+}
+
+// Decrement subtracts n from the counter, a negative n increments it
+func (c *YCounter) Decrement(n int64) error {
+	if n == math.MinInt64 {
+		return fmt.Errorf("decrement by %d: %w", n, ErrCounterOverflow)
+	}
+	return c.Increment(-n)
+}
+
+// add raises the totals of the local client
+func (c *YCounter) add(inc, dec int64) error {
+	if inc == 0 && dec == 0 {
+		return nil
+	}
+
+	key := strconv.FormatInt(c.doc.Client(), 10)
+	p, n := c.totals(key)
+	value := c.Value()
+	if inc > math.MaxInt64-p || dec > math.MaxInt64-n || value > math.MaxInt64-inc || value < math.MinInt64+dec {
+		return fmt.Errorf("add %d, subtract %d: %w", inc, dec, ErrCounterOverflow)
+	}
+
+	c.doc.Transact(func() {
+		c.doc.blockStore.SetMapValue(c.m, key, &block.ContentAny{Values: []any{[]any{p + inc, n + dec}}})
+	})
+	return nil
+}
+
+// totals returns the increments and decrements stored under key
+func (c *YCounter) totals(key string) (inc, dec int64) {
+	blk := c.m.Map[key]
+	if blk == nil || blk.IsDeleted {
+		return 0, 0
+	}
+
+	return counterTotals(c.doc.mapValue(blk))
+}
+
+// counterTotals reads the totals of a client from its stored value. Small
+// totals decode as float64, large ones as int64.
+func counterTotals(v any) (inc, dec int64) {
+	values, _ := v.([]any)
+	if len(values) != 2 {
+		return 0, 0
+	}
+	return counterTotal(values[0]), counterTotal(values[1])
+}
+
+func counterTotal(v any) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Value returns the current value of the counter
+func (c *YCounter) Value() int64 {
+	var value int64
+	for key := range c.m.Map {
+		p, n := c.totals(key)
+		value += p - n
+	}
+	return value
+}
+
+// Observe calls fn after every transaction that changed the value of
+// the counter. It returns a function that removes the observer.
+func (c *YCounter) Observe(fn func(*YCounterEvent)) (unobserve func()) {
+	return c.observers.add(fn)
+}
+
+// emit works out the old value from the totals the
+// changed clients had before the transaction
+func (c *YCounter) emit(txn *blockstore.Transaction, keys map[string]struct{}) any {
+	value := c.Value()
+	old := value
+	for key, change := range c.doc.keyChanges(c.m, txn, keys) {
+		p, n := c.totals(key)
+		old -= p - n
+		if change.Action != ActionAdd {
+			p, n = counterTotals(change.OldValue)
+			old += p - n
+		}
+	}
+	if old == value {
+		return nil
+	}
+
+	e := &YCounterEvent{
+		Target:   c,
+		Local:    txn.Local,
+		OldValue: old,
+		Value:    value,
+	}
+	c.observers.call(e)
+	return e
+}
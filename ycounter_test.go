@@ -0,0 +1,165 @@
+package ygo_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestYCounter_Concurrent tests that concurrent increments and decrements all count
+func TestYCounter_Concurrent(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+
+	votesA := docA.GetCounter("votes")
+	votesA.Increment(3)
+	votesA.Decrement(1)
+	sync(t, docA, docB)
+
+	votesB := docB.GetCounter("votes")
+	assert.Equal(t, int64(2), votesB.Value())
+
+	// concurrent changes of both sides add up
+	votesA.Increment(5)
+	votesB.Decrement(4)
+	votesB.Increment(-1)
+	syncV1(t, docA, docB)
+
+	assert.Equal(t, int64(2), votesA.Value())
+	assert.Equal(t, int64(2), votesB.Value())
+
+	// applying the same update twice changes nothing
+	update, err := docA.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	require.NoError(t, docB.ApplyUpdate(update))
+	assert.Equal(t, int64(2), votesB.Value())
+}
+
+// TestYCounter_Large tests that large totals sync exactly and overflows are refused
+func TestYCounter_Large(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+
+	big := int64(1)<<60 + 1
+	views := docA.GetCounter("views")
+	require.NoError(t, views.Increment(big))
+	require.NoError(t, views.Increment(big))
+	require.NoError(t, docB.GetCounter("views").Decrement(1))
+	syncV1(t, docA, docB)
+	assert.Equal(t, 2*big-1, docB.GetCounter("views").Value())
+
+	v2 := ygo.NewYDoc()
+	update, err := docA.EncodeStateAsUpdateV2(nil)
+	require.NoError(t, err)
+	require.NoError(t, v2.ApplyUpdateV2(update))
+	assert.Equal(t, 2*big-1, v2.GetCounter("views").Value())
+
+	assert.ErrorIs(t, views.Increment(math.MinInt64), ygo.ErrCounterOverflow)
+	assert.ErrorIs(t, views.Decrement(math.MinInt64), ygo.ErrCounterOverflow)
+	assert.ErrorIs(t, views.Increment(math.MaxInt64), ygo.ErrCounterOverflow)
+	require.NoError(t, views.Decrement(math.MaxInt64))
+	assert.ErrorIs(t, views.Decrement(math.MaxInt64), ygo.ErrCounterOverflow)
+	assert.Equal(t, 2*big-1-math.MaxInt64, views.Value())
+}
+
+// TestYCounter_Observe tests that observers see the net change of a transaction
+func TestYCounter_Observe(t *testing.T) {
+	doc := ygo.NewYDoc()
+	remote := ygo.NewYDoc()
+	votes := doc.GetCounter("votes")
+
+	var events []*ygo.YCounterEvent
+	votes.Observe(func(e *ygo.YCounterEvent) { events = append(events, e) })
+
+	votes.Increment(3)
+	require.Len(t, events, 1)
+	assert.Equal(t, &ygo.YCounterEvent{Target: votes, Local: true, OldValue: 0, Value: 3}, events[0])
+
+	// changes that cancel each other out aren't observed
+	doc.Transact(func() {
+		votes.Increment(2)
+		votes.Decrement(2)
+	})
+	assert.Len(t, events, 1)
+
+	remote.GetCounter("votes").Decrement(5)
+	sync(t, doc, remote)
+	require.Len(t, events, 2)
+	assert.Equal(t, &ygo.YCounterEvent{Target: votes, Local: false, OldValue: 3, Value: -2}, events[1])
+}
+
+// TestYRegister_LastWriterWins tests that the write with the highest (clock, client) wins
+func TestYRegister_LastWriterWins(t *testing.T) {
+	type status struct {
+		State string `json:"state"`
+		By    string `json:"by"`
+	}
+
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+	regA := ygo.GetRegister[status](docA, "status")
+	regB := ygo.GetRegister[status](docB, "status")
+
+	_, ok := regA.Get()
+	assert.False(t, ok)
+
+	require.NoError(t, regA.Set(status{State: "draft", By: "a"}))
+	sync(t, docA, docB)
+	got, ok := regB.Get()
+	require.True(t, ok)
+	assert.Equal(t, status{State: "draft", By: "a"}, got)
+
+	// a write after seeing another one wins over it
+	require.NoError(t, regB.Set(status{State: "review", By: "b"}))
+	sync(t, docA, docB)
+	got, _ = regA.Get()
+	assert.Equal(t, "review", got.State)
+
+	// concurrent writes with the same clock are decided by the client ID
+	require.NoError(t, regA.Set(status{State: "published", By: "a"}))
+	require.NoError(t, regB.Set(status{State: "rejected", By: "b"}))
+	syncV1(t, docA, docB)
+
+	gotA, _ := regA.Get()
+	gotB, _ := regB.Get()
+	assert.Equal(t, gotA, gotB)
+	if docA.Client() > docB.Client() {
+		assert.Equal(t, "a", gotA.By)
+	} else {
+		assert.Equal(t, "b", gotA.By)
+	}
+
+	assert.Panics(t, func() { ygo.GetRegister[int](docA, "status") })
+}
+
+// TestYRegister_Observe tests that observers see changes of the winning value
+func TestYRegister_Observe(t *testing.T) {
+	docA := ygo.NewYDoc(ygo.WithClientID(1))
+	docB := ygo.NewYDoc(ygo.WithClientID(2))
+	regA := ygo.GetRegister[string](docA, "status")
+	regB := ygo.GetRegister[string](docB, "status")
+
+	var events []*ygo.YRegisterEvent[string]
+	regA.Observe(func(e *ygo.YRegisterEvent[string]) { events = append(events, e) })
+
+	require.NoError(t, regA.Set("draft"))
+	require.Len(t, events, 1)
+	assert.Equal(t, &ygo.YRegisterEvent[string]{Target: regA, Local: true, OldValue: "", Value: "draft"}, events[0])
+
+	// writing the same value again changes nothing
+	require.NoError(t, regA.Set("draft"))
+	assert.Len(t, events, 1)
+
+	// a concurrent write that loses isn't observed, one that wins is
+	require.NoError(t, regB.Set("rejected"))
+	sync(t, docA, docB)
+	assert.Len(t, events, 1)
+
+	require.NoError(t, regB.Set("published"))
+	sync(t, docA, docB)
+	require.Len(t, events, 2)
+	assert.Equal(t, &ygo.YRegisterEvent[string]{Target: regA, Local: false, OldValue: "draft", Value: "published"}, events[1])
+}
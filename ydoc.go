@@ -3,6 +3,7 @@ package ygo
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"sort"
 
 	"github.com/amoghyermalkar123/ygo/internal/block"
@@ -12,34 +13,95 @@ import (
 )
 
 type YDoc struct {
+	// guid identifies the document, subdocuments are referenced by it
+	guid           string
 	blockStore     *blockstore.BlockStore
 	pendingUpdates []*block.Update
 	pendingDeletes []*block.DeleteUpdate
+	// types holds the shared type handed out for every type
+	// of the store, observers are registered on them
+	types map[*blockstore.Type]sharedType
+	// deepObservers holds the observers of every type
+	// that are told about changes of nested types as well
+	deepObservers map[*blockstore.Type]*observers[[]DeepEvent]
+	// updateObservers are given the v1 update of every transaction
+	updateObservers observers[[]byte]
+	subdoc          subdocState
 }
 
-func NewYDoc() *YDoc {
-	logger.Init()
+// Option configures a YDoc at construction time
+type Option func(*YDoc)
 
-	return &YDoc{
-		blockStore: blockstore.NewStore(),
+// WithLogger routes the document's debug output to l.
+// Without it the document logs nothing.
+func WithLogger(l *slog.Logger) Option {
+	return func(yd *YDoc) {
+		yd.blockStore.Log = logger.OrDiscard(l)
 	}
 }
 
+// WithGC turns garbage collection of deleted content on or off,
+// it is off by default. With it deleted content is dropped from the
+// document and its updates, only the clock ranges it covered are kept.
+func WithGC(enabled bool) Option {
+	return func(yd *YDoc) {
+		yd.blockStore.GC = enabled
+	}
+}
+
+// WithClientID sets the client ID the document creates blocks with
+// instead of a random one. Two documents editing with the same ID
+// corrupt each other, it's meant for reproducible tests.
+func WithClientID(id int64) Option {
+	return func(yd *YDoc) {
+		yd.blockStore.CurrentClientID = id
+	}
+}
+
+func NewYDoc(opts ...Option) *YDoc {
+	yd := &YDoc{
+		guid:          newGUID(),
+		blockStore:    blockstore.NewStore(),
+		types:         make(map[*blockstore.Type]sharedType),
+		deepObservers: make(map[*blockstore.Type]*observers[[]DeepEvent]),
+		subdoc: subdocState{
+			shouldLoad: true,
+			docs:       make(map[*block.Block]*YDoc),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(yd)
+	}
+
+	return yd
+}
+
 func (yd *YDoc) Client() int64 {
 	return yd.blockStore.GetCurrentClient()
 }
 
+// InsertText inserts text into the default text of the document,
+// positions past the end append to it
 func (yd *YDoc) InsertText(pos int64, text string) error {
-	yd.blockStore.Insert(pos, text)
-	return nil
+	t := yd.GetText(DefaultText)
+	return t.InsertText(min(pos, t.Length()), text, nil)
 }
 
+// DeleteText deletes text from the default text of the document
 func (yd *YDoc) DeleteText(pos, length int64) error {
-	return yd.blockStore.Delete(pos, length)
+	return yd.GetText(DefaultText).DeleteText(pos, length)
 }
 
+// Content returns the content of the default text of the document
 func (yd *YDoc) Content() string {
-	return yd.blockStore.Content()
+	return yd.GetText(DefaultText).Content()
+}
+
+// SetContent changes the default text of the document to `text`
+// with as few edits as possible, see YText.SetContent
+func (yd *YDoc) SetContent(text string) error {
+	return yd.GetText(DefaultText).SetContent(text)
 }
 
 // refer readUpdateV2 from yjs in encoding.js
@@ -53,26 +115,121 @@ func (yd *YDoc) ApplyUpdate(data []byte) error {
 		return fmt.Errorf("decode update: %w", err)
 	}
 
-	// Step 2: Integrate the blocks from remote clients
-	yd.processUpdates(&update.Updates)
+	yd.applyUpdate(update)
+	return nil
+}
 
-	// Step 3: Process deletions
-	yd.processDeletes(&update.Deletes)
+// ApplyUpdateV1 applies an update in the yjs v1 format, like the ones
+// Y.encodeStateAsUpdate or EncodeStateAsUpdateV1 produce
+func (yd *YDoc) ApplyUpdateV1(data []byte) error {
+	update, err := decoder.DecodeUpdateV1(data)
+	if err != nil {
+		return fmt.Errorf("decode update: %w", err)
+	}
 
-	// Check if there are any pending updates that can now be processed
-	yd.processPendingUpdates()
+	yd.applyUpdate(update)
+	return nil
+}
+
+// ApplyUpdateV2 applies an update in the yjs v2 format, like the ones
+// Y.encodeStateAsUpdateV2 or EncodeStateAsUpdateV2 produce
+func (yd *YDoc) ApplyUpdateV2(data []byte) error {
+	update, err := decoder.DecodeUpdateV2(data)
+	if err != nil {
+		return fmt.Errorf("decode update: %w", err)
+	}
 
+	yd.applyUpdate(update)
 	return nil
 }
 
+func (yd *YDoc) applyUpdate(update *block.Updates) {
+	yd.transact(false, func() {
+		// Step 2: Integrate the blocks from remote clients
+		yd.processUpdates(&update.Updates)
+
+		// Step 3: Process deletions
+		yd.processDeletes(&update.Deletes)
+
+		// Check if there are any pending updates that can now be processed
+		yd.processPendingUpdates()
+	})
+}
+
+// Transact runs fn as a single transaction. Observers are called
+// once after fn returns instead of after every single change.
+// Transactions nest, an inner Transact joins the outer one.
+func (yd *YDoc) Transact(fn func()) {
+	yd.transact(true, fn)
+}
+
+func (yd *YDoc) transact(local bool, fn func()) {
+	if yd.blockStore.Txn != nil {
+		fn()
+		return
+	}
+
+	yd.blockStore.Begin(local)
+	defer func() {
+		yd.resolveMoves()
+		txn := yd.blockStore.End()
+		yd.emit(txn)
+		yd.emitSubdocs(txn)
+		yd.blockStore.Cleanup(txn)
+		yd.emitUpdate(txn)
+		yd.cleanupFormatting(txn)
+	}()
+
+	fn()
+}
+
+// ObserveUpdateV1 calls fn with the v1 update of every transaction that
+// changed the document, local or remote, like the update event of yjs.
+// The update holds the blocks added and the ranges deleted by the
+// transaction. It returns a function that removes the observer.
+func (yd *YDoc) ObserveUpdateV1(fn func(update []byte)) (unobserve func()) {
+	return yd.updateObservers.add(fn)
+}
+
+// emitUpdate encodes the changes of the transaction for the update
+// observers, nothing is encoded while there are none
+func (yd *YDoc) emitUpdate(txn *blockstore.Transaction) {
+	if len(yd.updateObservers.entries) == 0 {
+		return
+	}
+
+	u := yd.updateSince(txn.BeforeState, txn.Deleted)
+	if len(u.Updates.Updates) == 0 && len(u.Deletes.ClientDeletes) == 0 {
+		return
+	}
+	data, err := u.MarshalV1()
+	if err != nil {
+		yd.blockStore.Log.Error("encode update", slog.Any("err", err))
+		return
+	}
+	yd.updateObservers.call(data)
+}
+
+// resolveMoves settles where moved values are shown in every
+// type the running transaction changed
+func (yd *YDoc) resolveMoves() {
+	for _, t := range yd.blockStore.Txn.ChangedTypes {
+		if t.HasMoves {
+			yd.blockStore.ResolveMoves(t)
+		}
+	}
+}
+
 func (yd *YDoc) processUpdates(update *block.Update) {
 	// Collect all blocks from all clients
 	var allBlocks []*block.Block
 
 	for _, blocks := range update.Updates {
 		for _, remoteBlock := range blocks {
-			// Skip blocks that already exist in store
-			if !yd.blockStore.HasBlock(remoteBlock.ID) {
+			// Skip blocks that are already fully known, a block
+			// may have been merged with its successors on the
+			// sender's side so its start alone is not enough
+			if remoteBlock.ID.Clock+int64(remoteBlock.Len()) > yd.blockStore.GetState(remoteBlock.ID.Client) {
 				allBlocks = append(allBlocks, remoteBlock)
 			}
 		}
@@ -102,11 +259,11 @@ func (yd *YDoc) processUpdates(update *block.Update) {
 
 			// Resolve left and right references
 			if remoteBlock.LeftOrigin != (block.ID{}) {
-				remoteBlock.Left = yd.blockStore.ResolveNeighborByPreciseBlockID(remoteBlock.LeftOrigin)
+				remoteBlock.Left = yd.blockStore.GetItemCleanEnd(remoteBlock.LeftOrigin)
 			}
 
 			if remoteBlock.RightOrigin != (block.ID{}) {
-				remoteBlock.Right = yd.blockStore.ResolveNeighborByPreciseBlockID(remoteBlock.RightOrigin)
+				remoteBlock.Right = yd.blockStore.GetItemCleanStart(remoteBlock.RightOrigin)
 			}
 
 			// Integrate the block
@@ -114,9 +271,11 @@ func (yd *YDoc) processUpdates(update *block.Update) {
 		}
 	}
 
-	yd.AddPendingUpdate(&block.Update{
-		Updates: restOfTheUpdates,
-	})
+	if len(restOfTheUpdates) > 0 {
+		yd.AddPendingUpdate(&block.Update{
+			Updates: restOfTheUpdates,
+		})
+	}
 }
 
 func (yd *YDoc) processDeletes(deletes *block.DeleteUpdate) {
@@ -180,13 +339,13 @@ func (yd *YDoc) processDeletes(deletes *block.DeleteUpdate) {
 						if !blk.IsDeleted {
 							// check if the endClock sits between the clock range of `blk`
 							// if it does, we need to split the block
-							if int64(endClock) < blk.ID.Clock+int64(len(blk.Content)) {
+							if int64(endClock) < blk.ID.Clock+int64(blk.Len()) {
 								splitPoint := int(int64(endClock) - blk.ID.Clock)
 								yd.blockStore.PreciseBlockCut(blk, splitPoint)
 							}
 
 							// Mark the block as deleted
-							blk.MarkDeleted()
+							yd.blockStore.MarkDeleted(blk)
 						}
 					} else {
 						break
@@ -222,58 +381,140 @@ func (yd *YDoc) processDeletes(deletes *block.DeleteUpdate) {
 
 }
 
-// Process any pending updates that can now be integrated
+// Process any pending updates that can now be integrated. The queues
+// are taken before they are processed, what still can't be applied is
+// queued again.
 func (yd *YDoc) processPendingUpdates() {
-	pendingUpdates := yd.GetPendingUpdates()
+	// a pending block may wait for another pending block,
+	// retry for as long as blocks get integrated
+	for pending := yd.pendingBlocks(); pending > 0; {
+		pendingUpdates := yd.GetPendingUpdates()
+		yd.SetPendingUpdates(nil)
+
+		for _, pendingUpdate := range pendingUpdates {
+			yd.processUpdates(pendingUpdate)
+		}
 
-	for _, pendingUpdate := range pendingUpdates {
-		yd.processUpdates(pendingUpdate)
+		left := yd.pendingBlocks()
+		if left == pending {
+			break
+		}
+		pending = left
 	}
 
 	pendingDeletes := yd.GetPendingDeletes()
+	yd.SetPendingDeletes(nil)
 
 	for _, deleteUpdate := range pendingDeletes {
 		yd.processDeletes(deleteUpdate)
 	}
 }
 
+// pendingBlocks counts the blocks in the pending queue
+func (yd *YDoc) pendingBlocks() int {
+	n := 0
+	for _, u := range yd.pendingUpdates {
+		for _, blocks := range u.Updates {
+			n += len(blocks)
+		}
+	}
+	return n
+}
+
 // EncodeStateAsUpdate encodes the current document state as an update message
 // that can be applied to other YDoc instances
 func (yd *YDoc) EncodeStateAsUpdate() ([]byte, error) {
-	// Create an update message containing all blocks in the store
+	return json.Marshal(yd.update(nil))
+}
+
+// EncodeStateAsUpdateV1 encodes everything a replica with the state vector
+// `sv` is missing in the yjs v1 format. `sv` is encoded like
+// EncodeStateVectorV1 does, nil encodes the whole document.
+func (yd *YDoc) EncodeStateAsUpdateV1(sv []byte) ([]byte, error) {
+	var state map[int64]int64
+	if sv != nil {
+		var err error
+		if state, err = block.DecodeStateVectorV1(sv); err != nil {
+			return nil, fmt.Errorf("decode state vector: %w", err)
+		}
+	}
+
+	return yd.update(state).MarshalV1()
+}
+
+// EncodeStateAsUpdateV2 is EncodeStateAsUpdateV1 in the yjs v2 format,
+// state vectors are encoded the same way in both
+func (yd *YDoc) EncodeStateAsUpdateV2(sv []byte) ([]byte, error) {
+	var state map[int64]int64
+	if sv != nil {
+		var err error
+		if state, err = block.DecodeStateVectorV1(sv); err != nil {
+			return nil, fmt.Errorf("decode state vector: %w", err)
+		}
+	}
+
+	return yd.update(state).MarshalV2()
+}
+
+// EncodeStateVectorV1 encodes the state vector in the yjs v1 format
+func (yd *YDoc) EncodeStateVectorV1() []byte {
+	return block.EncodeStateVectorV1(yd.blockStore.StateVector)
+}
+
+// update collects the blocks a replica with the state vector `sv`
+// is missing along with the whole delete set
+func (yd *YDoc) update(sv map[int64]int64) *block.Updates {
+	return yd.updateSince(sv, yd.blockStore.DeleteSet)
+}
+
+// updateSince collects the blocks a replica with the state
+// vector `sv` is missing along with the ranges of deleteSet
+func (yd *YDoc) updateSince(sv map[int64]int64, deleteSet map[int64][]block.DeleteRange) *block.Updates {
 	updates := make(map[int64][]*block.Block)
 
-	// Process each client's blocks
 	for clientID, blocks := range yd.blockStore.Blocks {
-		// Deep copy blocks to avoid modifying the original store
-		clientBlocks := make([]*block.Block, len(blocks))
-		for i, b := range blocks {
+		known := sv[clientID]
+		// the blocks of a client are sorted by clock, skip the known ones
+		start := sort.Search(len(blocks), func(i int) bool {
+			return known < blocks[i].ID.Clock+int64(blocks[i].Len())
+		})
+
+		var clientBlocks []*block.Block
+		for _, b := range blocks[start:] {
+
 			// Copy only the block data, not the references
-			clientBlocks[i] = &block.Block{
+			blk := &block.Block{
 				ID:          b.ID,
 				Content:     b.Content,
 				IsDeleted:   b.IsDeleted,
 				LeftOrigin:  b.LeftOrigin,
 				RightOrigin: b.RightOrigin,
-				// Leave Left and Right as nil since they're references
+				Parent:      b.Parent,
+				ParentSub:   b.ParentSub,
+			}
+
+			// the start of the block is known already, the
+			// rest is inserted right after it
+			if offset := known - b.ID.Clock; offset > 0 {
+				blk.ID.Clock = known
+				blk.Content = block.SliceContent(b.Content, int(offset))
+				blk.LeftOrigin = block.ID{Client: clientID, Clock: known - 1}
 			}
+
+			clientBlocks = append(clientBlocks, blk)
 		}
-		updates[clientID] = clientBlocks
-	}
 
-	// Convert the internal deleteSet to a DeleteUpdate structure
-	deleteUpdate := createDeleteUpdateFromDeleteSet(yd.blockStore.DeleteSet)
+		if len(clientBlocks) > 0 {
+			updates[clientID] = clientBlocks
+		}
+	}
 
-	// Create the update message
-	updateMsg := block.Updates{
+	return &block.Updates{
 		Updates: block.Update{
 			Updates: updates,
 		},
-		Deletes: deleteUpdate,
+		Deletes: createDeleteUpdateFromDeleteSet(deleteSet),
 	}
-
-	// Marshal the update message
-	return json.Marshal(updateMsg)
 }
 
 // EncodeStateVector returns the current state vector as a map of client IDs to clocks
@@ -287,6 +528,12 @@ func (yd *YDoc) EncodeStateVector() map[int64]int64 {
 	return stateVector
 }
 
+// GetBlockStore returns the store holding the blocks of the document,
+// it's meant for debugging tools. Changing it corrupts the document.
+func (yd *YDoc) GetBlockStore() *blockstore.BlockStore {
+	return yd.blockStore
+}
+
 // AddPendingUpdate adds an update to the pending queue
 func (yd *YDoc) AddPendingUpdate(update *block.Update) {
 	yd.pendingUpdates = append(yd.pendingUpdates, update)
@@ -325,7 +572,7 @@ func createDeleteUpdateFromDeleteSet(deleteSet map[int64][]block.DeleteRange) bl
 		if len(deleteRanges) > 0 {
 			clientDeletes = append(clientDeletes, block.ClientDeletes{
 				Client:        clientID,
-				DeletedRanges: deleteRanges,
+				DeletedRanges: block.MergeDeleteRanges(deleteRanges),
 			})
 		}
 	}
@@ -1,7 +1,9 @@
 package ygo_test
 
 import (
+	"bytes"
 	"fmt"
+	"log/slog"
 	"testing"
 
 	"github.com/amoghyermalkar123/ygo"
@@ -426,6 +428,29 @@ func TestApplyUpdate_IdempotentUpdates(t *testing.T) {
 	assert.Equal(t, contentAfterFirstUpdate, targetDoc.Content())
 }
 
+// TestApplyUpdate_ReversedUpdates tests that updates arriving in reverse
+// order wait in the pending queue until the blocks they need arrive
+func TestApplyUpdate_ReversedUpdates(t *testing.T) {
+	source := ygo.NewYDoc()
+	target := ygo.NewYDoc()
+
+	var updates [][]byte
+	for i, s := range []string{"a", "b", "c", "d"} {
+		sv := source.EncodeStateVectorV1()
+		require.NoError(t, source.InsertText(int64(i), s))
+		update, err := source.EncodeStateAsUpdateV1(sv)
+		require.NoError(t, err)
+		updates = append(updates, update)
+	}
+
+	for i := len(updates) - 1; i >= 0; i-- {
+		require.NoError(t, target.ApplyUpdateV1(updates[i]))
+	}
+
+	assert.Equal(t, "abcd", target.Content())
+	assert.Empty(t, target.GetPendingUpdates())
+}
+
 // TestApplyUpdate_EdgeCaseEmptyDocument tests applying updates to an empty document
 func TestApplyUpdate_EdgeCaseEmptyDocument(t *testing.T) {
 	// Create an empty doc
@@ -568,3 +593,120 @@ func TestMultipleDeletionSynchronization(t *testing.T) {
 	// Verify target content now matches source
 	assert.Equal(t, source.Content(), target.Content())
 }
+
+// TestWithLogger tests that debug output only goes to an injected logger
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	doc := ygo.NewYDoc(ygo.WithLogger(l))
+	err := doc.InsertText(0, "Hello")
+	require.NoError(t, err)
+	err = doc.InsertText(2, "X")
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "insert text")
+	assert.Contains(t, buf.String(), "TLP_index")
+
+	// the default logger must stay silent
+	silent := ygo.NewYDoc()
+	err = silent.InsertText(0, "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", silent.Content())
+}
+
+// TestApplyUpdateV2_Yjs tests applying an update encoded by yjs in the v2 format
+func TestApplyUpdateV2_Yjs(t *testing.T) {
+	// ytext.insert(0, 'abc') by client 1 on the text "text"
+	update := []byte{0, 0, 1, 1, 0, 0, 1, 4, 10, 7, 't', 'e', 'x', 't', 'a', 'b', 'c', 4, 3, 1, 1, 0, 0, 1, 1, 0, 0}
+
+	doc := ygo.NewYDoc()
+	require.NoError(t, doc.ApplyUpdateV2(update))
+	assert.Equal(t, "abc", doc.GetText("text").Content())
+
+	// encoding the doc again yields the same bytes
+	encoded, err := doc.EncodeStateAsUpdateV2(nil)
+	require.NoError(t, err)
+	assert.Equal(t, update, encoded)
+
+	assert.Error(t, ygo.NewYDoc().ApplyUpdateV2(update[:12]))
+}
+
+// TestEncodeStateAsUpdateV2_RoundTrip tests that every kind of content
+// survives the v2 format and that it agrees with v1
+func TestEncodeStateAsUpdateV2_RoundTrip(t *testing.T) {
+	source := ygo.NewYDoc()
+	text := source.GetText("text")
+	require.NoError(t, text.InsertText(0, "hello wörld 😀", nil))
+	require.NoError(t, text.Format(0, 5, map[string]any{"bold": true}))
+	require.NoError(t, text.DeleteText(5, 1))
+	require.NoError(t, source.GetMap("map").Set("key", map[string]any{"n": 1.5, "list": []any{"a", nil}}))
+	require.NoError(t, source.GetArray("array").Push(1.0, "two", true))
+	_, err := source.GetXmlFragment("xml").InsertElement(0, "paragraph")
+	require.NoError(t, err)
+
+	update, err := source.EncodeStateAsUpdateV2(nil)
+	require.NoError(t, err)
+
+	target := ygo.NewYDoc()
+	require.NoError(t, target.ApplyUpdateV2(update))
+	assert.Equal(t, text.ToDelta(), target.GetText("text").ToDelta())
+	assert.Equal(t, source.GetMap("map").Entries(), target.GetMap("map").Entries())
+	assert.Equal(t, source.GetArray("array").ToSlice(), target.GetArray("array").ToSlice())
+	assert.Equal(t, "<paragraph></paragraph>", target.GetXmlFragment("xml").ToString())
+
+	v1, err := source.EncodeStateAsUpdateV1(nil)
+	require.NoError(t, err)
+	targetV1, err := target.EncodeStateAsUpdateV1(nil)
+	require.NoError(t, err)
+	assert.Equal(t, v1, targetV1)
+
+	// only what the state vector doesn't cover is encoded
+	sv := target.EncodeStateVectorV1()
+	require.NoError(t, text.InsertText(0, ">", nil))
+	diff, err := source.EncodeStateAsUpdateV2(sv)
+	require.NoError(t, err)
+	assert.Less(t, len(diff), len(update))
+	require.NoError(t, target.ApplyUpdateV2(diff))
+	assert.Equal(t, text.Content(), target.GetText("text").Content())
+}
+
+// TestObserveUpdateV1 tests that the updates of every transaction keep another document in sync
+func TestObserveUpdateV1(t *testing.T) {
+	source := ygo.NewYDoc()
+	target := ygo.NewYDoc()
+
+	var updates [][]byte
+	unobserve := source.ObserveUpdateV1(func(update []byte) {
+		updates = append(updates, update)
+		require.NoError(t, target.ApplyUpdateV1(update))
+	})
+
+	text := source.GetText("text")
+	require.NoError(t, text.InsertText(0, "Hello World", nil))
+	require.NoError(t, text.DeleteText(0, 6))
+	require.NoError(t, source.GetMap("map").Set("key", "value"))
+	assert.Len(t, updates, 3)
+	assert.Equal(t, "World", target.GetText("text").Content())
+	assert.Equal(t, "value", target.GetMap("map").Entries()["key"])
+
+	// a deletion only carries the deleted range, not the whole document
+	assert.Less(t, len(updates[1]), len(updates[0]))
+
+	// transactions changing nothing don't produce updates
+	source.Transact(func() {})
+	assert.Len(t, updates, 3)
+
+	// remote changes are reported as well
+	remote := ygo.NewYDoc()
+	require.NoError(t, remote.GetText("text").InsertText(0, "!", nil))
+	update, err := remote.EncodeStateAsUpdateV1(nil)
+	require.NoError(t, err)
+	require.NoError(t, source.ApplyUpdateV1(update))
+	assert.Len(t, updates, 4)
+	assert.Equal(t, source.GetText("text").Content(), target.GetText("text").Content())
+
+	unobserve()
+	require.NoError(t, text.InsertText(0, "x", nil))
+	assert.Len(t, updates, 4)
+}
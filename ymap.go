@@ -0,0 +1,221 @@
+package ygo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// ErrEmptyKey is returned when setting a map value without a key
+var ErrEmptyKey = errors.New("map key must not be empty")
+
+// YMap is a shared map living in a YDoc under a name.
+// Concurrent writes to the same key are resolved last-writer-wins,
+// every replica agrees on the winner.
+type YMap struct {
+	doc       *YDoc
+	m         *blockstore.Type
+	observers observers[*YMapEvent]
+}
+
+// Action describes how a key of a map changed
+type Action string
+
+const (
+	ActionAdd    Action = "add"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// KeyChange describes the change of a single key
+type KeyChange struct {
+	Action Action
+	// OldValue is the value before the change, nil for ActionAdd
+	OldValue any
+}
+
+// YMapEvent is passed to observers of a YMap after a transaction changed it
+type YMapEvent struct {
+	Target *YMap
+	// Local is true when the change was made through this document
+	// and false when it came from ApplyUpdate
+	Local       bool
+	KeysChanged map[string]KeyChange
+}
+
+// GetMap returns the map with the given name, creating it if necessary.
+// It panics if the name is already used by a different shared type.
+func (yd *YDoc) GetMap(name string) *YMap {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YMap{doc: yd, m: t}
+	})
+
+	m, ok := st.(*YMap)
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return m
+}
+
+// Name returns the name the map is stored under in the document
+func (m *YMap) Name() string {
+	return m.m.Name
+}
+
+// Set sets key to value. The value must be encodable as JSON, it is
+// stored as its decoded JSON form so every replica reads the same
+// value back, numbers for example always come back as float64.
+func (m *YMap) Set(key string, value any) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	v, err := normalizeValue(value)
+	if err != nil {
+		return fmt.Errorf("set %q: %w", key, err)
+	}
+
+	m.doc.Transact(func() {
+		m.doc.blockStore.SetMapValue(m.m, key, &block.ContentAny{Values: []any{v}})
+	})
+	return nil
+}
+
+// Get returns the value of key and whether it is set
+func (m *YMap) Get(key string) (any, bool) {
+	blk := m.m.Map[key]
+	if blk == nil || blk.IsDeleted {
+		return nil, false
+	}
+	return m.doc.mapValue(blk), true
+}
+
+// Has reports whether key is set
+func (m *YMap) Has(key string) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Delete removes key from the map, it's a no-op for unset keys
+func (m *YMap) Delete(key string) {
+	m.doc.Transact(func() {
+		m.doc.blockStore.DeleteMapValue(m.m, key)
+	})
+}
+
+// Keys returns the keys currently set in sorted order
+func (m *YMap) Keys() []string {
+	keys := make([]string, 0, len(m.m.Map))
+	for key, blk := range m.m.Map {
+		if !blk.IsDeleted {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Entries returns a copy of all keys currently set and their values
+func (m *YMap) Entries() map[string]any {
+	entries := make(map[string]any, len(m.m.Map))
+	for _, key := range m.Keys() {
+		entries[key], _ = m.Get(key)
+	}
+	return entries
+}
+
+// Len returns the number of keys currently set
+func (m *YMap) Len() int {
+	return len(m.Keys())
+}
+
+// Observe calls fn after every transaction that changed the map.
+// It returns a function that removes the observer.
+func (m *YMap) Observe(fn func(*YMapEvent)) (unobserve func()) {
+	return m.observers.add(fn)
+}
+
+// ObserveDeep calls fn after every transaction that changed the map or
+// any type nested in it, with one event for every changed type.
+// It returns a function that removes the observer.
+func (m *YMap) ObserveDeep(fn func([]DeepEvent)) (unobserve func()) {
+	return m.doc.observeDeep(m.m, fn)
+}
+
+// emit describes the changes of every changed key
+func (m *YMap) emit(txn *blockstore.Transaction, keys map[string]struct{}) any {
+	changes := m.doc.keyChanges(m.m, txn, keys)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	e := &YMapEvent{
+		Target:      m,
+		Local:       txn.Local,
+		KeysChanged: changes,
+	}
+	m.observers.call(e)
+	return e
+}
+
+// keyChanges works out what happened to the changed keys of `t` the
+// same way yjs does, by comparing the blocks of the key chain that
+// were added or deleted by the transaction
+func (yd *YDoc) keyChanges(t *blockstore.Type, txn *blockstore.Transaction, keys map[string]struct{}) map[string]KeyChange {
+	changes := make(map[string]KeyChange)
+
+	for key := range keys {
+		blk := t.Map[key]
+		if blk == nil {
+			continue
+		}
+
+		if txn.Adds(blk) {
+			prev := blk.Left
+			for prev != nil && txn.Adds(prev) {
+				prev = prev.Left
+			}
+
+			if txn.Deletes(blk) {
+				if prev != nil && txn.Deletes(prev) {
+					changes[key] = KeyChange{Action: ActionDelete, OldValue: yd.mapValue(prev)}
+				}
+			} else if prev != nil && txn.Deletes(prev) {
+				changes[key] = KeyChange{Action: ActionUpdate, OldValue: yd.mapValue(prev)}
+			} else {
+				changes[key] = KeyChange{Action: ActionAdd}
+			}
+		} else if txn.Deletes(blk) {
+			changes[key] = KeyChange{Action: ActionDelete, OldValue: yd.mapValue(blk)}
+		}
+	}
+
+	return changes
+}
+
+// mapValue returns the value held by a block of a map key
+func (yd *YDoc) mapValue(blk *block.Block) any {
+	values := yd.values(blk)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1]
+}
+
+// normalizeValue returns the value the way every replica will decode it
+func normalizeValue(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode value: %w", err)
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("decode value: %w", err)
+	}
+	return v, nil
+}
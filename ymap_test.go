@@ -0,0 +1,193 @@
+package ygo_test
+
+import (
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sync exchanges the full state of two docs in both directions
+func sync(t *testing.T, a, b *ygo.YDoc) {
+	t.Helper()
+
+	updateA, err := a.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	updateB, err := b.EncodeStateAsUpdate()
+	require.NoError(t, err)
+
+	require.NoError(t, b.ApplyUpdate(updateA))
+	require.NoError(t, a.ApplyUpdate(updateB))
+}
+
+// TestYMap_SetGetDelete tests the basic map operations on a single doc
+func TestYMap_SetGetDelete(t *testing.T) {
+	doc := ygo.NewYDoc()
+	settings := doc.GetMap("settings")
+
+	require.NoError(t, settings.Set("theme", "dark"))
+	require.NoError(t, settings.Set("fontSize", 12))
+	require.NoError(t, settings.Set("theme", "light"))
+
+	v, ok := settings.Get("theme")
+	assert.True(t, ok)
+	assert.Equal(t, "light", v)
+
+	// values come back in their JSON form
+	v, ok = settings.Get("fontSize")
+	assert.True(t, ok)
+	assert.Equal(t, float64(12), v)
+
+	settings.Delete("fontSize")
+	assert.False(t, settings.Has("fontSize"))
+	assert.Equal(t, []string{"theme"}, settings.Keys())
+	assert.Equal(t, map[string]any{"theme": "light"}, settings.Entries())
+
+	assert.ErrorIs(t, settings.Set("", 1), ygo.ErrEmptyKey)
+	assert.Error(t, settings.Set("bad", func() {}))
+}
+
+// TestYMap_Sync tests that map changes travel in the same update as text
+func TestYMap_Sync(t *testing.T) {
+	source := ygo.NewYDoc()
+	target := ygo.NewYDoc()
+
+	require.NoError(t, source.InsertText(0, "Hello"))
+	require.NoError(t, source.GetMap("meta").Set("author", "ana"))
+	require.NoError(t, source.GetMap("meta").Set("tags", []string{"a", "b"}))
+	require.NoError(t, source.GetMap("meta").Set("author", "bo"))
+	source.GetMap("meta").Delete("tags")
+
+	update, err := source.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	require.NoError(t, target.ApplyUpdate(update))
+
+	assert.Equal(t, "Hello", target.Content())
+	assert.Equal(t, map[string]any{"author": "bo"}, target.GetMap("meta").Entries())
+}
+
+// TestYMap_ConcurrentSet tests that concurrent writes to a key converge
+func TestYMap_ConcurrentSet(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+
+	require.NoError(t, doc1.GetMap("m").Set("key", "initial"))
+	sync(t, doc1, doc2)
+
+	require.NoError(t, doc1.GetMap("m").Set("key", "one"))
+	require.NoError(t, doc2.GetMap("m").Set("key", "two"))
+	require.NoError(t, doc2.GetMap("m").Set("other", "x"))
+	sync(t, doc1, doc2)
+
+	v1, _ := doc1.GetMap("m").Get("key")
+	v2, _ := doc2.GetMap("m").Get("key")
+	assert.Equal(t, v1, v2)
+
+	// the higher client id ends up on the right and wins
+	if doc1.Client() > doc2.Client() {
+		assert.Equal(t, "one", v1)
+	} else {
+		assert.Equal(t, "two", v1)
+	}
+	assert.Equal(t, doc1.GetMap("m").Entries(), doc2.GetMap("m").Entries())
+}
+
+// TestYMap_ConcurrentSetAndDelete tests a delete racing with an overwrite
+func TestYMap_ConcurrentSetAndDelete(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+
+	require.NoError(t, doc1.GetMap("m").Set("key", "initial"))
+	sync(t, doc1, doc2)
+
+	doc1.GetMap("m").Delete("key")
+	require.NoError(t, doc2.GetMap("m").Set("key", "new"))
+	sync(t, doc1, doc2)
+
+	// the delete only removed the value it saw
+	v1, ok := doc1.GetMap("m").Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "new", v1)
+	assert.Equal(t, doc1.GetMap("m").Entries(), doc2.GetMap("m").Entries())
+}
+
+// TestYMap_Observe tests the events passed to map observers
+func TestYMap_Observe(t *testing.T) {
+	doc := ygo.NewYDoc()
+	remote := ygo.NewYDoc()
+	m := doc.GetMap("m")
+
+	var events []*ygo.YMapEvent
+	unobserve := m.Observe(func(e *ygo.YMapEvent) {
+		events = append(events, e)
+	})
+
+	require.NoError(t, m.Set("a", 1))
+	require.Len(t, events, 1)
+	assert.True(t, events[0].Local)
+	assert.Equal(t, map[string]ygo.KeyChange{"a": {Action: ygo.ActionAdd}}, events[0].KeysChanged)
+
+	// a transaction produces a single event with the net changes
+	doc.Transact(func() {
+		require.NoError(t, m.Set("a", 2))
+		require.NoError(t, m.Set("b", 1))
+		m.Delete("b")
+	})
+	require.Len(t, events, 2)
+	assert.Equal(t, map[string]ygo.KeyChange{
+		"a": {Action: ygo.ActionUpdate, OldValue: float64(1)},
+	}, events[1].KeysChanged)
+
+	// remote changes are observed as well
+	require.NoError(t, remote.GetMap("m").Set("c", "remote"))
+	update, err := remote.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	require.NoError(t, doc.ApplyUpdate(update))
+	require.Len(t, events, 3)
+	assert.False(t, events[2].Local)
+	assert.Equal(t, map[string]ygo.KeyChange{"c": {Action: ygo.ActionAdd}}, events[2].KeysChanged)
+
+	m.Delete("a")
+	require.Len(t, events, 4)
+	assert.Equal(t, map[string]ygo.KeyChange{
+		"a": {Action: ygo.ActionDelete, OldValue: float64(2)},
+	}, events[3].KeysChanged)
+
+	unobserve()
+	require.NoError(t, m.Set("d", 1))
+	assert.Len(t, events, 4)
+}
+
+// TestYMap_ObserveOrder tests that the types changed by a transaction
+// are observed in the order they were changed in
+func TestYMap_ObserveOrder(t *testing.T) {
+	doc := ygo.NewYDoc()
+
+	var names []string
+	maps := make([]*ygo.YMap, 8)
+	for i := range maps {
+		name := string(rune('a' + i))
+		maps[i] = doc.GetMap(name)
+		maps[i].Observe(func(*ygo.YMapEvent) { names = append(names, name) })
+	}
+
+	for run := 0; run < 10; run++ {
+		names = nil
+		doc.Transact(func() {
+			for i := len(maps) - 1; i >= 0; i-- {
+				require.NoError(t, maps[i].Set("run", run))
+			}
+		})
+		assert.Equal(t, []string{"h", "g", "f", "e", "d", "c", "b", "a"}, names)
+	}
+}
+
+// TestYMap_NameConflict tests that a name can't be used by two shared types
+func TestYMap_NameConflict(t *testing.T) {
+	doc := ygo.NewYDoc()
+	doc.GetText("shared")
+
+	assert.Panics(t, func() { doc.GetMap("shared") })
+}
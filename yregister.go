@@ -0,0 +1,168 @@
+package ygo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// YRegister is a shared single value living in a YDoc under a name.
+// Every client stores its last write under its client ID as [clock, value]
+// where clock is a Lamport clock, the write with the highest (clock, client)
+// wins. A write always wins over every write its client has seen before.
+type YRegister[T any] struct {
+	doc       *YDoc
+	m         *blockstore.Type
+	observers observers[*YRegisterEvent[T]]
+}
+
+// YRegisterEvent is passed to observers of a YRegister after a
+// transaction changed its value
+type YRegisterEvent[T any] struct {
+	Target *YRegister[T]
+	// Local is true when the change was made through this document
+	// and false when it came from ApplyUpdate
+	Local bool
+	// OldValue is the value before the change, the zero
+	// value if the register wasn't set before
+	OldValue T
+	Value    T
+}
+
+// GetRegister returns the register with the given name holding values of
+// type T, creating it if necessary. It panics if the name is already used
+// by a different shared type or a register of a different type.
+func GetRegister[T any](yd *YDoc, name string) *YRegister[T] {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YRegister[T]{doc: yd, m: t}
+	})
+
+	r, ok := st.(*YRegister[T])
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return r
+}
+
+// Name returns the name the register is stored under in the document
+func (r *YRegister[T]) Name() string {
+	return r.m.Name
+}
+
+// Set writes value to the register. The value must be encodable as JSON.
+func (r *YRegister[T]) Set(value T) error {
+	v, err := normalizeValue(value)
+	if err != nil {
+		return fmt.Errorf("set register: %w", err)
+	}
+
+	clock, _, _ := r.latest()
+	key := strconv.FormatInt(r.doc.Client(), 10)
+
+	r.doc.Transact(func() {
+		r.doc.blockStore.SetMapValue(r.m, key, &block.ContentAny{Values: []any{[]any{float64(clock + 1), v}}})
+	})
+	return nil
+}
+
+// Get returns the current value of the register and whether it was ever set
+func (r *YRegister[T]) Get() (T, bool) {
+	_, v, ok := r.latest()
+	if !ok {
+		var value T
+		return value, false
+	}
+	return decodeValue[T](v)
+}
+
+// decodeValue decodes a value stored in its JSON form into T the same way
+func decodeValue[T any](v any) (T, bool) {
+	var value T
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return value, false
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// latest returns the winning write, the one with the highest (clock, client)
+func (r *YRegister[T]) latest() (clock int64, value any, ok bool) {
+	return latestWrite(r.writes())
+}
+
+// writes returns the last write of every client by client ID
+func (r *YRegister[T]) writes() map[string]any {
+	writes := make(map[string]any, len(r.m.Map))
+	for key, blk := range r.m.Map {
+		if !blk.IsDeleted {
+			writes[key] = r.doc.mapValue(blk)
+		}
+	}
+	return writes
+}
+
+// latestWrite returns the winning write of the writes stored by client ID
+func latestWrite(writes map[string]any) (clock int64, value any, ok bool) {
+	var client int64
+
+	for key, write := range writes {
+		values, _ := write.([]any)
+		if len(values) != 2 {
+			continue
+		}
+		c, _ := values[0].(float64)
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if !ok || int64(c) > clock || (int64(c) == clock && id > client) {
+			clock, client, value, ok = int64(c), id, values[1], true
+		}
+	}
+	return clock, value, ok
+}
+
+// Observe calls fn after every transaction that changed the value of
+// the register. It returns a function that removes the observer.
+func (r *YRegister[T]) Observe(fn func(*YRegisterEvent[T])) (unobserve func()) {
+	return r.observers.add(fn)
+}
+
+// emit compares the winning write with the one that won
+// with the writes the changed clients had before the transaction
+func (r *YRegister[T]) emit(txn *blockstore.Transaction, keys map[string]struct{}) any {
+	writes := r.writes()
+	_, value, ok := latestWrite(writes)
+
+	for key, change := range r.doc.keyChanges(r.m, txn, keys) {
+		if change.Action == ActionAdd {
+			delete(writes, key)
+		} else {
+			writes[key] = change.OldValue
+		}
+	}
+	_, oldValue, oldOK := latestWrite(writes)
+
+	if ok == oldOK && reflect.DeepEqual(value, oldValue) {
+		return nil
+	}
+
+	e := &YRegisterEvent[T]{Target: r, Local: txn.Local}
+	if oldOK {
+		e.OldValue, _ = decodeValue[T](oldValue)
+	}
+	if ok {
+		e.Value, _ = decodeValue[T](value)
+	}
+	r.observers.call(e)
+	return e
+}
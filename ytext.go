@@ -0,0 +1,342 @@
+package ygo
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// DefaultText is the name of the text used by YDoc.InsertText,
+// YDoc.DeleteText and YDoc.Content. Updates from before documents
+// had named types carry no type name and end up there as well.
+const DefaultText = ""
+
+// YText is a shared text living in a YDoc under a name.
+// All texts of a document share its client ID, state vector and updates.
+type YText struct {
+	doc       *YDoc
+	text      *blockstore.Type
+	observers observers[*YTextEvent]
+}
+
+// YTextEvent is passed to observers of a YText after a transaction changed it
+type YTextEvent struct {
+	Target *YText
+	// Local is true when the change was made through this document
+	// and false when it came from ApplyUpdate
+	Local bool
+	// Delta describes the changes from the start of the text. Inserts
+	// carry their formatting, retains carry the attributes the
+	// transaction changed, nil for removed ones.
+	Delta []TextDelta
+}
+
+// GetText returns the text with the given name, creating it if necessary.
+// Every replica asking for the same name gets the same text.
+// It panics if the name is already used by a different shared type.
+func (yd *YDoc) GetText(name string) *YText {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YText{doc: yd, text: t}
+	})
+
+	text, ok := st.(*YText)
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return text
+}
+
+// root returns the shared type of the root type `name`,
+// creating it with `create` on first use
+func (yd *YDoc) root(name string, create func(*blockstore.Type) sharedType) sharedType {
+	t := yd.blockStore.Root(name)
+	if st, ok := yd.types[t]; ok {
+		return st
+	}
+
+	st := create(t)
+	yd.types[t] = st
+	return st
+}
+
+// Name returns the name the text is stored under in the document
+func (t *YText) Name() string {
+	return t.text.Name
+}
+
+// InsertText inserts text at the given position formatted with `attrs`.
+// With nil attributes the text continues the formatting in effect at the
+// position, an empty map inserts it without any formatting.
+func (t *YText) InsertText(pos int64, text string, attrs map[string]any) error {
+	if pos < 0 || pos > t.Length() {
+		return fmt.Errorf("insert at %d: index out of range [0, %d]", pos, t.Length())
+	}
+
+	attrs, err := normalizeAttributes(attrs)
+	if err != nil {
+		return err
+	}
+
+	t.doc.Transact(func() {
+		err = t.insert(pos, &block.ContentString{Str: text}, attrs)
+	})
+	return err
+}
+
+// InsertEmbed inserts an embedded object such as an image or a mention at
+// the given position. The embed takes up a single position, `attrs` work
+// like for InsertText.
+func (t *YText) InsertEmbed(pos int64, value map[string]any, attrs map[string]any) error {
+	if pos < 0 || pos > t.Length() {
+		return fmt.Errorf("insert at %d: index out of range [0, %d]", pos, t.Length())
+	}
+
+	embed, err := normalizeValue(value)
+	if err != nil {
+		return err
+	}
+	attrs, err = normalizeAttributes(attrs)
+	if err != nil {
+		return err
+	}
+
+	t.doc.Transact(func() {
+		err = t.insert(pos, &block.ContentEmbed{Embed: embed}, attrs)
+	})
+	return err
+}
+
+// Format sets the attributes `attrs` on `length` characters starting at
+// `pos`. Attributes set to nil are removed, attributes that are not
+// mentioned stay as they are.
+func (t *YText) Format(pos, length int64, attrs map[string]any) error {
+	if pos < 0 || length < 0 || pos+length > t.Length() {
+		return fmt.Errorf("format range %d+%d exceeds text length %d", pos, length, t.Length())
+	}
+
+	attrs, err := normalizeAttributes(attrs)
+	if err != nil {
+		return err
+	}
+
+	t.doc.Transact(func() {
+		t.format(pos, length, attrs)
+	})
+	return nil
+}
+
+// DeleteText deletes `length` characters starting at `pos`
+func (t *YText) DeleteText(pos, length int64) (err error) {
+	if pos < 0 || length < 0 || pos+length > t.Length() {
+		return fmt.Errorf("delete [%d, %d): range out of bounds [0, %d)", pos, pos+length, t.Length())
+	}
+
+	t.doc.Transact(func() {
+		err = t.doc.blockStore.Delete(t.text, pos, length)
+	})
+	return err
+}
+
+// Content returns the current text, embeds are left out
+func (t *YText) Content() string {
+	return t.text.Content()
+}
+
+// TextDelta is an operation of a Quill-style delta. ToDelta describes
+// the text as inserts only, each with the attributes it's formatted with.
+type TextDelta struct {
+	Insert     any            `json:"insert,omitempty"`
+	Delete     int64          `json:"delete,omitempty"`
+	Retain     int64          `json:"retain,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// ToDelta returns the text as runs of equally formatted text,
+// every embed is an insert of its own
+func (t *YText) ToDelta() []TextDelta {
+	var delta []TextDelta
+	attrs := make(map[string]any)
+
+	for blk := t.text.Start; blk != nil; blk = blk.Right {
+		if blk.IsDeleted {
+			continue
+		}
+
+		switch c := blk.Content.(type) {
+		case *block.ContentFormat:
+			updateAttributes(attrs, c)
+		case *block.ContentString:
+			if n := len(delta); n > 0 && maps.EqualFunc(delta[n-1].Attributes, attrs, equalAttr) {
+				if str, ok := delta[n-1].Insert.(string); ok {
+					delta[n-1].Insert = str + c.Str
+					continue
+				}
+			}
+
+			run := TextDelta{Insert: c.Str}
+			if len(attrs) > 0 {
+				run.Attributes = maps.Clone(attrs)
+			}
+			delta = append(delta, run)
+		case *block.ContentEmbed:
+			run := TextDelta{Insert: c.Embed}
+			if len(attrs) > 0 {
+				run.Attributes = maps.Clone(attrs)
+			}
+			delta = append(delta, run)
+		}
+	}
+
+	return delta
+}
+
+// Length returns the number of visible characters, an embed counts as one
+func (t *YText) Length() int64 {
+	return int64(t.text.Length)
+}
+
+// Observe calls fn after every transaction that changed the text.
+// It returns a function that removes the observer.
+func (t *YText) Observe(fn func(*YTextEvent)) (unobserve func()) {
+	return t.observers.add(fn)
+}
+
+// emit walks the text once keeping track of the formatting before and
+// after the transaction, like YArray.emit for the content itself
+func (t *YText) emit(txn *blockstore.Transaction, _ map[string]struct{}) any {
+	var delta []TextDelta
+	before := make(map[string]any)
+	after := make(map[string]any)
+
+	add := func(d TextDelta) {
+		if n := len(delta); n > 0 && maps.EqualFunc(delta[n-1].Attributes, d.Attributes, equalAttr) {
+			last := &delta[n-1]
+			switch {
+			case last.Delete > 0 && d.Delete > 0:
+				last.Delete += d.Delete
+				return
+			case last.Retain > 0 && d.Retain > 0:
+				last.Retain += d.Retain
+				return
+			}
+			if str, ok := last.Insert.(string); ok {
+				if next, ok := d.Insert.(string); ok {
+					last.Insert = str + next
+					return
+				}
+			}
+		}
+		delta = append(delta, d)
+	}
+
+	for blk := t.text.Start; blk != nil; blk = blk.Right {
+		added, deleted := txn.Adds(blk), txn.Deletes(blk)
+
+		if f, ok := blk.Content.(*block.ContentFormat); ok {
+			if !added && (!blk.IsDeleted || deleted) {
+				updateAttributes(before, f)
+			}
+			if !blk.IsDeleted {
+				updateAttributes(after, f)
+			}
+			continue
+		}
+		if !blk.Content.Countable() {
+			continue
+		}
+
+		switch {
+		case added:
+			if blk.IsDeleted {
+				continue
+			}
+			var insert any
+			switch c := blk.Content.(type) {
+			case *block.ContentString:
+				insert = c.Str
+			case *block.ContentEmbed:
+				insert = c.Embed
+			default:
+				insert = t.doc.values(blk)[0]
+			}
+			d := TextDelta{Insert: insert}
+			if len(after) > 0 {
+				d.Attributes = maps.Clone(after)
+			}
+			add(d)
+		case blk.IsDeleted:
+			if deleted {
+				add(TextDelta{Delete: int64(blk.Len())})
+			}
+		default:
+			d := TextDelta{Retain: int64(blk.Len())}
+			for key := range keysOf(before, after) {
+				if !equalAttr(before[key], after[key]) {
+					if d.Attributes == nil {
+						d.Attributes = make(map[string]any)
+					}
+					d.Attributes[key] = after[key]
+				}
+			}
+			add(d)
+		}
+	}
+
+	// a trailing retain without formatting carries no information
+	if n := len(delta); n > 0 && delta[n-1].Retain > 0 && delta[n-1].Attributes == nil {
+		delta = delta[:n-1]
+	}
+	if len(delta) == 0 {
+		return nil
+	}
+
+	e := &YTextEvent{
+		Target: t,
+		Local:  txn.Local,
+		Delta:  delta,
+	}
+	t.observers.call(e)
+	return e
+}
+
+// keysOf returns the keys of both attribute maps
+func keysOf(a, b map[string]any) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// cleanupFormatting removes redundant format markers from the texts changed
+// by the transaction, it runs once the transaction is over
+func (yd *YDoc) cleanupFormatting(txn *blockstore.Transaction) {
+	for _, typ := range txn.ChangedTypes {
+		if text, ok := yd.types[typ].(*YText); ok && typ.HasFormatting {
+			text.cleanupFormatting()
+		}
+	}
+}
+
+// normalizeAttributes normalizes every attribute value like a map
+// value, so local values compare equal to the ones decoded from updates
+func normalizeAttributes(attrs map[string]any) (map[string]any, error) {
+	if attrs == nil {
+		return nil, nil
+	}
+
+	normalized := make(map[string]any, len(attrs))
+	for key, value := range attrs {
+		v, err := normalizeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", key, err)
+		}
+		normalized[key] = v
+	}
+	return normalized, nil
+}
@@ -0,0 +1,368 @@
+package ygo_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetText_IndependentTexts tests that named texts don't share positions
+func TestGetText_IndependentTexts(t *testing.T) {
+	doc := ygo.NewYDoc()
+
+	title := doc.GetText("title")
+	body := doc.GetText("body")
+
+	require.NoError(t, title.InsertText(0, "Notes", nil))
+	require.NoError(t, body.InsertText(0, "first line", nil))
+	require.NoError(t, title.InsertText(0, "My ", nil))
+	require.NoError(t, body.DeleteText(0, 6))
+
+	assert.Equal(t, "My Notes", title.Content())
+	assert.Equal(t, "line", body.Content())
+	assert.Equal(t, int64(8), title.Length())
+
+	// the default text is untouched
+	assert.Equal(t, "", doc.Content())
+	assert.Equal(t, "My Notes", doc.GetText("title").Content())
+}
+
+// TestGetText_SharedUpdateStream tests that all texts of a doc sync through one update
+func TestGetText_SharedUpdateStream(t *testing.T) {
+	source := ygo.NewYDoc()
+	target := ygo.NewYDoc()
+
+	require.NoError(t, source.GetText("title").InsertText(0, "Title", nil))
+	require.NoError(t, source.GetText("body").InsertText(0, "Body text", nil))
+	require.NoError(t, source.InsertText(0, "default"))
+
+	update, err := source.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	require.NoError(t, target.ApplyUpdate(update))
+
+	assert.Equal(t, "Title", target.GetText("title").Content())
+	assert.Equal(t, "Body text", target.GetText("body").Content())
+	assert.Equal(t, "default", target.Content())
+
+	// a single client clock covers all three texts
+	assert.Equal(t, map[int64]int64{source.Client(): 21}, target.EncodeStateVector())
+}
+
+// TestGetText_ConcurrentEdits tests concurrent edits to different texts and the same text
+func TestGetText_ConcurrentEdits(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+
+	require.NoError(t, doc1.GetText("title").InsertText(0, "Hello", nil))
+	update, err := doc1.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	require.NoError(t, doc2.ApplyUpdate(update))
+
+	require.NoError(t, doc1.GetText("title").InsertText(5, " World", nil))
+	require.NoError(t, doc2.GetText("title").DeleteText(0, 1))
+	require.NoError(t, doc2.GetText("body").InsertText(0, "Body", nil))
+
+	update1, err := doc1.EncodeStateAsUpdate()
+	require.NoError(t, err)
+	update2, err := doc2.EncodeStateAsUpdate()
+	require.NoError(t, err)
+
+	require.NoError(t, doc1.ApplyUpdate(update2))
+	require.NoError(t, doc2.ApplyUpdate(update1))
+
+	assert.Equal(t, "ello World", doc1.GetText("title").Content())
+	assert.Equal(t, doc1.GetText("title").Content(), doc2.GetText("title").Content())
+	assert.Equal(t, "Body", doc1.GetText("body").Content())
+
+	// positions keep working after remote changes
+	require.NoError(t, doc1.GetText("title").InsertText(4, "!", nil))
+	assert.Equal(t, "ello! World", doc1.GetText("title").Content())
+}
+
+// TestYText_Bounds tests that edits outside of the text are refused
+func TestYText_Bounds(t *testing.T) {
+	doc := ygo.NewYDoc()
+	text := doc.GetText("body")
+	require.NoError(t, text.InsertText(0, "hello", nil))
+
+	assert.Error(t, text.InsertText(-1, "x", nil))
+	assert.Error(t, text.InsertText(6, "x", nil))
+	assert.Error(t, text.InsertEmbed(-1, map[string]any{"image": "a.png"}, nil))
+	assert.Error(t, text.DeleteText(-2, 2))
+	assert.Error(t, text.DeleteText(3, 5))
+	assert.Error(t, text.DeleteText(0, -1))
+	assert.Equal(t, "hello", text.Content())
+
+	// the ends are fine
+	require.NoError(t, text.InsertText(5, "!", nil))
+	require.NoError(t, text.DeleteText(0, 6))
+	assert.Equal(t, "", text.Content())
+}
+
+// TestYText_Format tests formatting a range and reading it back as a delta
+func TestYText_Format(t *testing.T) {
+	doc := ygo.NewYDoc()
+	text := doc.GetText("body")
+
+	require.NoError(t, text.InsertText(0, "Hello World", nil))
+	require.NoError(t, text.Format(0, 5, map[string]any{"bold": true}))
+
+	assert.Equal(t, []ygo.TextDelta{
+		{Insert: "Hello", Attributes: map[string]any{"bold": true}},
+		{Insert: " World"},
+	}, text.ToDelta())
+	assert.Equal(t, "Hello World", text.Content())
+	assert.Equal(t, int64(11), text.Length())
+
+	// removing the attribute again leaves a single run
+	require.NoError(t, text.Format(0, 5, map[string]any{"bold": nil}))
+	assert.Equal(t, []ygo.TextDelta{{Insert: "Hello World"}}, text.ToDelta())
+
+	assert.Error(t, text.Format(8, 5, map[string]any{"bold": true}))
+}
+
+// TestYText_InsertWithAttributes tests how inserted text is formatted
+func TestYText_InsertWithAttributes(t *testing.T) {
+	doc := ygo.NewYDoc()
+	text := doc.GetText("body")
+
+	require.NoError(t, text.InsertText(0, "bold", map[string]any{"bold": true}))
+	// nil attributes continue the formatting to the left
+	require.NoError(t, text.InsertText(4, "er", nil))
+	// an empty map inserts plain text
+	require.NoError(t, text.InsertText(6, " plain", map[string]any{}))
+	require.NoError(t, text.InsertText(0, "link ", map[string]any{"link": "https://example.com"}))
+
+	assert.Equal(t, []ygo.TextDelta{
+		{Insert: "link ", Attributes: map[string]any{"link": "https://example.com"}},
+		{Insert: "bolder", Attributes: map[string]any{"bold": true}},
+		{Insert: " plain"},
+	}, text.ToDelta())
+}
+
+// TestYText_ConcurrentFormatting tests that concurrent formatting of
+// overlapping ranges converges
+func TestYText_ConcurrentFormatting(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+
+	require.NoError(t, doc1.GetText("body").InsertText(0, "Hello World", nil))
+	sync(t, doc1, doc2)
+
+	require.NoError(t, doc1.GetText("body").Format(0, 5, map[string]any{"bold": true}))
+	require.NoError(t, doc2.GetText("body").Format(3, 5, map[string]any{"italic": true}))
+	require.NoError(t, doc2.GetText("body").Format(6, 5, map[string]any{"link": "https://example.com"}))
+	sync(t, doc1, doc2)
+
+	expected := []ygo.TextDelta{
+		{Insert: "Hel", Attributes: map[string]any{"bold": true}},
+		{Insert: "lo", Attributes: map[string]any{"bold": true, "italic": true}},
+		{Insert: " ", Attributes: map[string]any{"italic": true}},
+		{Insert: "Wo", Attributes: map[string]any{"italic": true, "link": "https://example.com"}},
+		{Insert: "rld", Attributes: map[string]any{"link": "https://example.com"}},
+	}
+	assert.Equal(t, expected, doc1.GetText("body").ToDelta())
+	assert.Equal(t, expected, doc2.GetText("body").ToDelta())
+}
+
+// TestYText_ConcurrentSameFormatting tests that applying the same format
+// on two replicas converges and leaves no stray formatting behind
+func TestYText_ConcurrentSameFormatting(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+
+	require.NoError(t, doc1.GetText("body").InsertText(0, "abc", nil))
+	sync(t, doc1, doc2)
+
+	require.NoError(t, doc1.GetText("body").Format(1, 1, map[string]any{"bold": true}))
+	require.NoError(t, doc2.GetText("body").Format(1, 1, map[string]any{"bold": true}))
+	sync(t, doc1, doc2)
+	// the cleanup of both replicas has to reach the other one too
+	sync(t, doc1, doc2)
+
+	expected := []ygo.TextDelta{
+		{Insert: "a"},
+		{Insert: "b", Attributes: map[string]any{"bold": true}},
+		{Insert: "c"},
+	}
+	assert.Equal(t, expected, doc1.GetText("body").ToDelta())
+	assert.Equal(t, expected, doc2.GetText("body").ToDelta())
+
+	// removing the formatted text removes its formatting with it
+	require.NoError(t, doc1.GetText("body").DeleteText(1, 1))
+	require.NoError(t, doc1.GetText("body").InsertText(1, "x", nil))
+	sync(t, doc1, doc2)
+
+	assert.Equal(t, []ygo.TextDelta{{Insert: "axc"}}, doc1.GetText("body").ToDelta())
+	assert.Equal(t, []ygo.TextDelta{{Insert: "axc"}}, doc2.GetText("body").ToDelta())
+}
+
+// TestYText_Embeds tests that embeds take up a single position and
+// survive a round trip through an update
+func TestYText_Embeds(t *testing.T) {
+	doc1 := ygo.NewYDoc()
+	doc2 := ygo.NewYDoc()
+	text := doc1.GetText("body")
+
+	require.NoError(t, text.InsertText(0, "Hi !", nil))
+	require.NoError(t, text.InsertEmbed(3, map[string]any{"mention": "bob"}, nil))
+	require.NoError(t, text.InsertEmbed(5, map[string]any{"image": "cat.png"}, map[string]any{"width": 100}))
+	require.NoError(t, text.InsertText(5, "?", nil))
+
+	assert.Equal(t, int64(7), text.Length())
+	assert.Equal(t, "Hi !?", text.Content())
+
+	sync(t, doc1, doc2)
+
+	expected := []ygo.TextDelta{
+		{Insert: "Hi "},
+		{Insert: map[string]any{"mention": "bob"}},
+		{Insert: "!?"},
+		{Insert: map[string]any{"image": "cat.png"}, Attributes: map[string]any{"width": float64(100)}},
+	}
+	assert.Equal(t, expected, text.ToDelta())
+	assert.Equal(t, expected, doc2.GetText("body").ToDelta())
+
+	// deleting around the embed counts it as one position
+	require.NoError(t, doc2.GetText("body").DeleteText(2, 2))
+	sync(t, doc1, doc2)
+	assert.Equal(t, "Hi!?", text.Content())
+	assert.Equal(t, int64(5), text.Length())
+}
+
+// TestYText_Observe tests the deltas passed to text observers
+func TestYText_Observe(t *testing.T) {
+	doc := ygo.NewYDoc()
+	text := doc.GetText("body")
+	require.NoError(t, text.InsertText(0, "Hello World", nil))
+
+	var deltas [][]ygo.TextDelta
+	unobserve := text.Observe(func(e *ygo.YTextEvent) {
+		assert.True(t, e.Local)
+		deltas = append(deltas, e.Delta)
+	})
+	defer unobserve()
+
+	require.NoError(t, text.InsertText(5, ",", map[string]any{"italic": true}))
+	require.NoError(t, text.Format(7, 5, map[string]any{"bold": true}))
+	require.NoError(t, text.DeleteText(0, 2))
+
+	assert.Equal(t, [][]ygo.TextDelta{
+		{{Retain: 5}, {Insert: ",", Attributes: map[string]any{"italic": true}}},
+		{{Retain: 7}, {Retain: 5, Attributes: map[string]any{"bold": true}}},
+		{{Delete: 2}},
+	}, deltas)
+}
+
+// TestYText_SetContent tests replacing the text with minimal edits
+func TestYText_SetContent(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+	require.NoError(t, docA.SetContent("The quick brown fox"))
+	syncV1(t, docA, docB)
+
+	// only the changed words are edited, the concurrent insert survives
+	require.NoError(t, docA.SetContent("The slow brown fox jumps"))
+	require.NoError(t, docB.InsertText(10, "very "))
+	syncV1(t, docA, docB)
+	assert.Equal(t, "The slow very brown fox jumps", docA.Content())
+	assert.Equal(t, docA.Content(), docB.Content())
+
+	// positions are counted in UTF-16 code units, embeds are deleted
+	text := docA.GetText("emoji")
+	require.NoError(t, text.InsertText(0, "a😀b", nil))
+	require.NoError(t, text.InsertEmbed(1, map[string]any{"image": "x.png"}, nil))
+	require.NoError(t, text.SetContent("a😀c😀"))
+	assert.Equal(t, "a😀c😀", text.Content())
+	assert.Equal(t, int64(6), text.Length())
+	assert.Len(t, text.ToDelta(), 1)
+
+	require.NoError(t, text.SetContent(""))
+	assert.Equal(t, int64(0), text.Length())
+}
+
+// TestYText_SetContentScattered tests that many scattered changes
+// keep the text around them instead of replacing all of it
+func TestYText_SetContentScattered(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	original := make([]rune, 20000)
+	for i := range original {
+		original[i] = rune('a' + rng.Intn(26))
+	}
+	changed := slices.Clone(original)
+	for i := 0; i < 1500; i++ {
+		pos := rng.Intn(len(changed))
+		changed[pos] = 'a' + (changed[pos]-'a'+1+rune(rng.Intn(25)))%26
+	}
+
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+	require.NoError(t, docA.GetText("text").InsertText(0, string(original), nil))
+	syncV1(t, docA, docB)
+	text := docB.GetText("text")
+	require.NoError(t, text.SetContent(string(changed)))
+	assert.Equal(t, string(changed), text.Content())
+
+	kept := 0
+	for _, run := range text.Blame() {
+		if run.Client == docA.Client() {
+			kept += len(run.Text)
+		}
+	}
+	assert.Greater(t, kept, len(original)-1600)
+}
+
+// TestYText_ApplyDelta tests applying Quill-style deltas
+func TestYText_ApplyDelta(t *testing.T) {
+	doc := ygo.NewYDoc()
+	text := doc.GetText("text")
+	require.NoError(t, text.InsertText(0, "Hello World", nil))
+
+	var events []*ygo.YTextEvent
+	text.Observe(func(e *ygo.YTextEvent) { events = append(events, e) })
+
+	require.NoError(t, text.ApplyDelta([]ygo.TextDelta{
+		{Retain: 6},
+		{Retain: 5, Attributes: map[string]any{"bold": true}},
+		{Insert: "!", Attributes: map[string]any{"italic": true}},
+		{Insert: map[string]any{"image": "x.png"}},
+	}))
+	assert.Equal(t, []ygo.TextDelta{
+		{Insert: "Hello "},
+		{Insert: "World", Attributes: map[string]any{"bold": true}},
+		{Insert: "!", Attributes: map[string]any{"italic": true}},
+		{Insert: map[string]any{"image": "x.png"}},
+	}, text.ToDelta())
+	assert.Len(t, events, 1)
+
+	require.NoError(t, text.ApplyDelta([]ygo.TextDelta{
+		{Delete: 6},
+		{Retain: 5, Attributes: map[string]any{"bold": nil}},
+		{Delete: 2},
+		{Insert: "s"},
+	}))
+	assert.Equal(t, []ygo.TextDelta{{Insert: "Worlds"}}, text.ToDelta())
+
+	// invalid deltas change nothing
+	for _, delta := range [][]ygo.TextDelta{
+		{{Retain: 7}},
+		{{Delete: 3}, {Retain: 4}},
+		{{Insert: 42}},
+		{{Insert: "x", Retain: 1}},
+		{{Retain: -1}},
+	} {
+		assert.ErrorIs(t, text.ApplyDelta(delta), ygo.ErrInvalidDelta)
+	}
+	assert.Equal(t, "Worlds", text.Content())
+
+	// the deltas observers get apply to other texts
+	docB := ygo.NewYDoc()
+	require.NoError(t, docB.GetText("text").InsertText(0, "Hello World", nil))
+	require.NoError(t, docB.GetText("text").ApplyDelta(events[0].Delta))
+	assert.Equal(t, "Hello World!", docB.GetText("text").Content())
+}
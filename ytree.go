@@ -0,0 +1,634 @@
+package ygo
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/amoghyermalkar123/ygo/internal/block"
+	"github.com/amoghyermalkar123/ygo/internal/blockstore"
+)
+
+// A tree is stored as a log of operations, each creating, moving or deleting
+// a single node, appended to the type like the values of an array. The tree
+// is what replaying the log in (lamport, client, clock) order yields, where
+// a move that would make a node its own ancestor is skipped. Every replica
+// replays the same log in the same order, so all of them agree on the tree
+// even when nodes were moved into each other concurrently.
+//
+// Siblings are ordered by fractional keys. When a key would get too long,
+// the siblings around it get new keys through rekey operations, which only
+// change the key of a node that's still below the same parent.
+
+var (
+	// ErrNodeNotFound is returned for nodes that don't exist or were deleted
+	ErrNodeNotFound = errors.New("tree node not found")
+	// ErrTreeCycle is returned when moving a node below itself
+	ErrTreeCycle = errors.New("tree node can't be moved below itself")
+)
+
+// NodeID identifies a node of a YTree, it's the ID of the
+// block that created it. The zero NodeID is the root.
+type NodeID struct {
+	Client int64
+	Clock  int64
+}
+
+// TreeRoot is the root of every tree, it always exists
+var TreeRoot = NodeID{}
+
+// trash is the parent of deleted nodes
+var trash = NodeID{Client: -1, Clock: -1}
+
+func (id NodeID) String() string {
+	switch id {
+	case TreeRoot:
+		return ""
+	case trash:
+		return "trash"
+	}
+	return fmt.Sprintf("%d:%d", id.Client, id.Clock)
+}
+
+func parseNodeID(s string) (NodeID, error) {
+	switch s {
+	case "":
+		return TreeRoot, nil
+	case "trash":
+		return trash, nil
+	}
+
+	client, clock, ok := strings.Cut(s, ":")
+	if !ok {
+		return NodeID{}, fmt.Errorf("invalid node id %q", s)
+	}
+	var id NodeID
+	var err error
+	if id.Client, err = strconv.ParseInt(client, 10, 64); err != nil {
+		return NodeID{}, fmt.Errorf("invalid node id %q: %w", s, err)
+	}
+	if id.Clock, err = strconv.ParseInt(clock, 10, 64); err != nil {
+		return NodeID{}, fmt.Errorf("invalid node id %q: %w", s, err)
+	}
+	return id, nil
+}
+
+// YTree is a shared tree of nodes living in a YDoc under a name.
+// Nodes can be moved around concurrently without ever forming cycles.
+type YTree struct {
+	doc *YDoc
+	t   *blockstore.Type
+
+	// cache is the tree as of the version of the type in
+	// cacheVersion, seen is the state vector it was built at
+	cache        *treeState
+	cacheVersion uint64
+	seen         map[int64]int64
+}
+
+// treeOp is a single entry of the log
+type treeOp struct {
+	id      NodeID
+	lamport int64
+	// node is the node the operation is about, the
+	// created node is identified by the operation itself
+	node   NodeID
+	create bool
+	// rekey only changes the key of the node,
+	// as long as it's still a child of parent
+	rekey  bool
+	parent NodeID
+	// key orders the node among its siblings
+	key string
+}
+
+// compare orders operations the way they are replayed
+func (op treeOp) compare(other treeOp) int {
+	return cmp.Or(
+		cmp.Compare(op.lamport, other.lamport),
+		cmp.Compare(op.id.Client, other.id.Client),
+		cmp.Compare(op.id.Clock, other.id.Clock),
+	)
+}
+
+// treeState is the tree after replaying the log up to last
+type treeState struct {
+	parent map[NodeID]NodeID
+	key    map[NodeID]string
+	// children holds the children of every node in order,
+	// the ones of deleted nodes and the trash included
+	children map[NodeID][]NodeID
+	lamport  int64
+	last     treeOp
+	// length is the number of log entries replayed
+	length int
+}
+
+// GetTree returns the tree with the given name, creating it if necessary.
+// It panics if the name is already used by a different shared type.
+func (yd *YDoc) GetTree(name string) *YTree {
+	st := yd.root(name, func(t *blockstore.Type) sharedType {
+		return &YTree{doc: yd, t: t}
+	})
+
+	tree, ok := st.(*YTree)
+	if !ok {
+		panic(fmt.Sprintf("ygo: %q is already defined as a %T", name, st))
+	}
+	return tree
+}
+
+// Name returns the name the tree is stored under in the document
+func (tr *YTree) Name() string {
+	return tr.t.Name
+}
+
+// CreateNode creates a node as the child at index of parent and returns it
+func (tr *YTree) CreateNode(parent NodeID, index int64) (NodeID, error) {
+	st := tr.state()
+	if !st.exists(parent) {
+		return NodeID{}, fmt.Errorf("create node in %v: %w", parent, ErrNodeNotFound)
+	}
+
+	key, rekeys, err := st.keyAt(parent, index, nil)
+	if err != nil {
+		return NodeID{}, err
+	}
+
+	return tr.append(st.lamport+1, append(rekeys, map[string]any{
+		"parent": parent.String(),
+		"key":    key,
+	}))
+}
+
+// Move makes node the child at index of newParent, index counts the
+// children of newParent without node itself
+func (tr *YTree) Move(node, newParent NodeID, index int64) error {
+	st := tr.state()
+	if node == TreeRoot || !st.exists(node) {
+		return fmt.Errorf("move node %v: %w", node, ErrNodeNotFound)
+	}
+	if !st.exists(newParent) {
+		return fmt.Errorf("move node %v to %v: %w", node, newParent, ErrNodeNotFound)
+	}
+	if st.isAncestor(node, newParent) {
+		return fmt.Errorf("move node %v to %v: %w", node, newParent, ErrTreeCycle)
+	}
+
+	key, rekeys, err := st.keyAt(newParent, index, &node)
+	if err != nil {
+		return err
+	}
+
+	_, err = tr.append(st.lamport+1, append(rekeys, map[string]any{
+		"node":   node.String(),
+		"parent": newParent.String(),
+		"key":    key,
+	}))
+	return err
+}
+
+// Delete deletes node along with all of its descendants
+func (tr *YTree) Delete(node NodeID) error {
+	st := tr.state()
+	if node == TreeRoot || !st.exists(node) {
+		return fmt.Errorf("delete node %v: %w", node, ErrNodeNotFound)
+	}
+
+	_, err := tr.append(st.lamport+1, []map[string]any{{
+		"node":   node.String(),
+		"parent": trash.String(),
+	}})
+	return err
+}
+
+// Children returns the children of node in order,
+// nil if the node doesn't exist
+func (tr *YTree) Children(node NodeID) []NodeID {
+	st := tr.state()
+	if !st.exists(node) {
+		return nil
+	}
+	return slices.Clone(st.children[node])
+}
+
+// Parent returns the parent of node and whether the node exists
+func (tr *YTree) Parent(node NodeID) (NodeID, bool) {
+	st := tr.state()
+	if node == TreeRoot || !st.exists(node) {
+		return NodeID{}, false
+	}
+	return st.parent[node], true
+}
+
+// Exists reports whether node exists and isn't deleted
+func (tr *YTree) Exists(node NodeID) bool {
+	return tr.state().exists(node)
+}
+
+// append adds operations with the same lamport clock to the
+// log and returns the ID of the last one
+func (tr *YTree) append(lamport int64, ops []map[string]any) (id NodeID, err error) {
+	values := make([]any, len(ops))
+	for i, op := range ops {
+		op["lamport"] = float64(lamport)
+		values[i] = op
+	}
+
+	tr.doc.Transact(func() {
+		var pos *block.BlockTextListPosition
+		if pos, err = tr.doc.blockStore.FindPosition(tr.t, int64(tr.t.Length)); err != nil {
+			return
+		}
+		blk := tr.doc.blockStore.InsertAt(tr.t, pos, &block.ContentAny{Values: values})
+		id = NodeID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(len(values)) - 1}
+	})
+	return id, err
+}
+
+// state returns the tree as of the current version of the log. Operations
+// added since the last call that sort after every replayed one are
+// applied to the cached tree, otherwise the whole log is replayed.
+func (tr *YTree) state() *treeState {
+	if tr.cache != nil && tr.cacheVersion == tr.t.Version {
+		return tr.cache
+	}
+
+	if tr.cache != nil {
+		ops, length := tr.ops(tr.seen)
+		slices.SortFunc(ops, treeOp.compare)
+		if tr.cache.length+length == tr.t.Length && (len(ops) == 0 || ops[0].compare(tr.cache.last) > 0) {
+			for _, op := range ops {
+				tr.cache.apply(op)
+			}
+			tr.cache.length = tr.t.Length
+			tr.cached(tr.cache)
+			return tr.cache
+		}
+	}
+
+	st := &treeState{
+		parent:   make(map[NodeID]NodeID),
+		key:      make(map[NodeID]string),
+		children: make(map[NodeID][]NodeID),
+		length:   tr.t.Length,
+	}
+	ops, _ := tr.ops(nil)
+	slices.SortFunc(ops, treeOp.compare)
+	for _, op := range ops {
+		st.apply(op)
+	}
+
+	tr.cached(st)
+	return st
+}
+
+// cached remembers st as the tree as of the current version
+func (tr *YTree) cached(st *treeState) {
+	tr.cache, tr.cacheVersion = st, tr.t.Version
+	tr.seen = maps.Clone(tr.doc.blockStore.StateVector)
+}
+
+// ops reads the entries of the log that aren't covered by the state
+// vector `seen` along with their number, malformed entries are skipped
+// but counted
+func (tr *YTree) ops(seen map[int64]int64) (ops []treeOp, length int) {
+	for blk := tr.t.Start; blk != nil; blk = blk.Right {
+		if !blk.Visible() || blk.ID.Clock+int64(blk.Len()) <= seen[blk.ID.Client] {
+			continue
+		}
+		for i, v := range tr.doc.values(blk) {
+			id := NodeID{Client: blk.ID.Client, Clock: blk.ID.Clock + int64(i)}
+			if id.Clock < seen[id.Client] {
+				continue
+			}
+			length++
+
+			entry, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			op := treeOp{id: id}
+			lamport, _ := entry["lamport"].(float64)
+			op.lamport = int64(lamport)
+			op.key, _ = entry["key"].(string)
+			op.rekey, _ = entry["rekey"].(bool)
+
+			parent, _ := entry["parent"].(string)
+			var err error
+			if op.parent, err = parseNodeID(parent); err != nil {
+				continue
+			}
+
+			if node, ok := entry["node"].(string); ok {
+				if op.node, err = parseNodeID(node); err != nil || op.node == TreeRoot {
+					continue
+				}
+			} else if op.rekey {
+				continue
+			} else {
+				op.node, op.create = op.id, true
+			}
+
+			ops = append(ops, op)
+		}
+	}
+	return ops, length
+}
+
+// apply replays a single operation
+func (st *treeState) apply(op treeOp) {
+	st.lamport = max(st.lamport, op.lamport)
+	st.last = op
+
+	if op.create {
+		st.parent[op.node] = op.parent
+		st.key[op.node] = op.key
+		st.addChild(op.node)
+		return
+	}
+	parent, ok := st.parent[op.node]
+	if !ok {
+		return
+	}
+	if op.rekey {
+		if parent == op.parent {
+			st.removeChild(op.node)
+			st.key[op.node] = op.key
+			st.addChild(op.node)
+		}
+		return
+	}
+	// the move that would close a cycle is the one that loses
+	if op.parent != trash && st.isAncestor(op.node, op.parent) {
+		return
+	}
+
+	st.removeChild(op.node)
+	st.parent[op.node] = op.parent
+	if op.parent != trash {
+		st.key[op.node] = op.key
+	}
+	st.addChild(op.node)
+}
+
+// compareSiblings orders siblings by their key,
+// the ones sharing a key by their ID
+func (st *treeState) compareSiblings(a, b NodeID) int {
+	return cmp.Or(
+		strings.Compare(st.key[a], st.key[b]),
+		cmp.Compare(a.Client, b.Client),
+		cmp.Compare(a.Clock, b.Clock),
+	)
+}
+
+// addChild inserts node among the children of its parent
+func (st *treeState) addChild(node NodeID) {
+	parent := st.parent[node]
+	children := st.children[parent]
+	i, _ := slices.BinarySearchFunc(children, node, st.compareSiblings)
+	st.children[parent] = slices.Insert(children, i, node)
+}
+
+// removeChild removes node from the children of its parent
+func (st *treeState) removeChild(node NodeID) {
+	parent := st.parent[node]
+	children := st.children[parent]
+	if i, ok := slices.BinarySearchFunc(children, node, st.compareSiblings); ok {
+		st.children[parent] = slices.Delete(children, i, i+1)
+	}
+}
+
+// exists reports whether the node is connected to the root
+func (st *treeState) exists(node NodeID) bool {
+	for node != TreeRoot {
+		parent, ok := st.parent[node]
+		if !ok || parent == trash {
+			return false
+		}
+		node = parent
+	}
+	return true
+}
+
+// isAncestor reports whether `ancestor` is node or one of its ancestors
+func (st *treeState) isAncestor(ancestor, node NodeID) bool {
+	for {
+		if node == ancestor {
+			return true
+		}
+		parent, ok := st.parent[node]
+		if !ok || node == TreeRoot {
+			return false
+		}
+		node = parent
+	}
+}
+
+// keyAt returns a key placing a node at index among the children of
+// parent, leaving out `skip` if it's one of them. Once keys would grow
+// longer than maxKeyLen digits, the siblings around index get new keys
+// as well, they are returned as rekey operations.
+func (st *treeState) keyAt(parent NodeID, index int64, skip *NodeID) (string, []map[string]any, error) {
+	siblings := st.children[parent]
+	if skip != nil {
+		siblings = slices.DeleteFunc(slices.Clone(siblings), func(n NodeID) bool { return n == *skip })
+	}
+	if index < 0 || index > int64(len(siblings)) {
+		return "", nil, fmt.Errorf("index %d out of range [0, %d]", index, len(siblings))
+	}
+
+	var before, after string
+	if index > 0 {
+		before = st.key[siblings[index-1]]
+	}
+	if index < int64(len(siblings)) {
+		after = st.key[siblings[index]]
+	}
+	// siblings created concurrently may share a key,
+	// the node then goes right after them
+	if after != "" && after <= before {
+		after = ""
+	}
+
+	// nodes added at either end keep a fixed distance to the last
+	// one, so the keys don't get longer with every node added there
+	room := pow62(keyRoom)
+	var key string
+	switch {
+	case after == "" && before != "" && keyValue(before) < keyScale-room:
+		key = keyString(keyValue(before) + room)
+	case before == "" && after != "" && keyValue(after) > room:
+		key = keyString(keyValue(after) - room)
+	default:
+		key = keyBetween(before, after)
+	}
+	if len(key) <= maxKeyLen {
+		return key, nil, nil
+	}
+
+	keys, from := st.spread(siblings, int(index))
+	if keys == nil {
+		return key, nil, nil
+	}
+	var rekeys []map[string]any
+	for i, k := range keys {
+		switch n := from + i; {
+		case n < int(index):
+			rekeys = append(rekeys, rekey(siblings[n], parent, k))
+		case n == int(index):
+			key = k
+		default:
+			rekeys = append(rekeys, rekey(siblings[n-1], parent, k))
+		}
+	}
+	return key, rekeys, nil
+}
+
+func rekey(node, parent NodeID, key string) map[string]any {
+	return map[string]any{
+		"rekey":  true,
+		"node":   node.String(),
+		"parent": parent.String(),
+		"key":    key,
+	}
+}
+
+const (
+	// maxKeyLen is the length keys are kept to, spread keys
+	// are a whole number of keyUnits apart
+	maxKeyLen = 10
+	// keyRoom is the number of digits left between spread keys,
+	// roughly 6 nodes fit in the room of a digit before the
+	// keys get too long again
+	keyRoom = 3
+)
+
+// keyScale is the number of keys with maxKeyLen digits
+var keyScale = pow62(maxKeyLen)
+
+// spread finds the smallest window of siblings around index that can be
+// given keys leaving keyRoom digits between each other, along with a new
+// node at index. It returns the keys of the window in order and the index
+// of its first sibling, nil if not even all siblings fit. Keys of a window
+// at either end of the siblings are at most 62 times the room apart and
+// are kept close to the inner siblings, so there's room for more nodes
+// at that end.
+func (st *treeState) spread(siblings []NodeID, index int) ([]string, int) {
+	room := pow62(keyRoom)
+	for w := 1; ; w *= 2 {
+		from, to := max(0, index-w), min(len(siblings), index+w)
+
+		lo, hi := uint64(0), keyScale
+		if from > 0 {
+			lo = keyValue(st.key[siblings[from-1]])
+		}
+		if to < len(siblings) {
+			hi = keyValue(st.key[siblings[to]])
+		}
+
+		n := uint64(to-from) + 1
+		if hi > lo && (hi-lo)/(n+1) >= room {
+			step := (hi - lo) / (n + 1)
+			switch {
+			case from == 0 && to < len(siblings):
+				step = min(step, room*uint64(len(keyDigits)))
+				lo = hi - step*(n+1)
+			case to == len(siblings) && from > 0:
+				step = min(step, room*uint64(len(keyDigits)))
+			}
+			keys := make([]string, n)
+			for i := range keys {
+				keys[i] = keyString(lo + step*uint64(i+1))
+			}
+			return keys, from
+		}
+		if from == 0 && to == len(siblings) {
+			return nil, 0
+		}
+	}
+}
+
+func pow62(n int) uint64 {
+	p := uint64(1)
+	for range n {
+		p *= uint64(len(keyDigits))
+	}
+	return p
+}
+
+// keyValue returns the first maxKeyLen digits of a key as a number
+func keyValue(key string) uint64 {
+	var v uint64
+	for i := range maxKeyLen {
+		v *= uint64(len(keyDigits))
+		if i < len(key) {
+			v += uint64(strings.IndexByte(keyDigits, key[i]))
+		}
+	}
+	return v
+}
+
+// keyString is the inverse of keyValue, trailing zeros are left out
+func keyString(v uint64) string {
+	digits := make([]byte, maxKeyLen)
+	for i := maxKeyLen - 1; i >= 0; i-- {
+		digits[i] = keyDigits[v%uint64(len(keyDigits))]
+		v /= uint64(len(keyDigits))
+	}
+	return strings.TrimRight(string(digits), "0")
+}
+
+// digits of the keys ordering siblings, in ascending byte order
+const keyDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// keyBetween returns a key sorting between a and b, a fraction written in
+// base 62 without its leading "0.". Empty a is the lowest key, empty b
+// stands for no upper bound. Keys never end in '0' so there always is
+// room between two of them.
+func keyBetween(a, b string) string {
+	// the common prefix, a is padded with zeros
+	var prefix string
+	if b != "" {
+		n := 0
+		for n < len(b) {
+			digit := byte('0')
+			if n < len(a) {
+				digit = a[n]
+			}
+			if digit != b[n] {
+				break
+			}
+			n++
+		}
+		prefix, a, b = b[:n], a[min(n, len(a)):], b[n:]
+	}
+
+	lo, hi := 0, len(keyDigits)
+	if a != "" {
+		lo = strings.IndexByte(keyDigits, a[0])
+	}
+	if b != "" {
+		hi = strings.IndexByte(keyDigits, b[0])
+	}
+
+	if hi-lo > 1 {
+		return prefix + string(keyDigits[(lo+hi)/2])
+	}
+	// the first digits are consecutive, b without its tail still is above a
+	if len(b) > 1 {
+		return prefix + b[:1]
+	}
+	var rest string
+	if a != "" {
+		rest = a[1:]
+	}
+	return prefix + string(keyDigits[lo]) + keyBetween(rest, "")
+}
+
+func (tr *YTree) emit(*blockstore.Transaction, map[string]struct{}) any { return nil }
@@ -0,0 +1,173 @@
+package ygo_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/amoghyermalkar123/ygo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestYTree_Operations tests creating, moving and deleting nodes on a single doc
+func TestYTree_Operations(t *testing.T) {
+	doc := ygo.NewYDoc()
+	tree := doc.GetTree("outline")
+
+	a, err := tree.CreateNode(ygo.TreeRoot, 0)
+	require.NoError(t, err)
+	b, err := tree.CreateNode(ygo.TreeRoot, 1)
+	require.NoError(t, err)
+	c, err := tree.CreateNode(a, 0)
+	require.NoError(t, err)
+	d, err := tree.CreateNode(ygo.TreeRoot, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, []ygo.NodeID{a, d, b}, tree.Children(ygo.TreeRoot))
+	assert.Equal(t, []ygo.NodeID{c}, tree.Children(a))
+	parent, ok := tree.Parent(c)
+	require.True(t, ok)
+	assert.Equal(t, a, parent)
+
+	// moving a node below itself is refused
+	assert.ErrorIs(t, tree.Move(a, c, 0), ygo.ErrTreeCycle)
+	assert.ErrorIs(t, tree.Move(a, a, 0), ygo.ErrTreeCycle)
+
+	require.NoError(t, tree.Move(c, ygo.TreeRoot, 3))
+	assert.Equal(t, []ygo.NodeID{a, d, b, c}, tree.Children(ygo.TreeRoot))
+	require.NoError(t, tree.Move(a, ygo.TreeRoot, 3))
+	assert.Equal(t, []ygo.NodeID{d, b, c, a}, tree.Children(ygo.TreeRoot))
+	require.NoError(t, tree.Move(b, c, 0))
+
+	// deleting a node deletes its subtree
+	require.NoError(t, tree.Delete(c))
+	assert.False(t, tree.Exists(c))
+	assert.False(t, tree.Exists(b))
+	assert.Equal(t, []ygo.NodeID{d, a}, tree.Children(ygo.TreeRoot))
+	assert.ErrorIs(t, tree.Move(b, ygo.TreeRoot, 0), ygo.ErrNodeNotFound)
+	assert.ErrorIs(t, tree.Delete(ygo.TreeRoot), ygo.ErrNodeNotFound)
+
+	other := ygo.NewYDoc()
+	syncV1(t, doc, other)
+	assert.Equal(t, tree.Children(ygo.TreeRoot), other.GetTree("outline").Children(ygo.TreeRoot))
+}
+
+// TestYTree_Order tests that children stay in the order they were inserted in
+func TestYTree_Order(t *testing.T) {
+	doc := ygo.NewYDoc()
+	tree := doc.GetTree("outline")
+	rnd := rand.New(rand.NewSource(1))
+
+	var want []ygo.NodeID
+	for i := 0; i < 300; i++ {
+		index := rnd.Intn(len(want) + 1)
+		node, err := tree.CreateNode(ygo.TreeRoot, int64(index))
+		require.NoError(t, err)
+		want = slices.Insert(want, index, node)
+	}
+	assert.Equal(t, want, tree.Children(ygo.TreeRoot))
+}
+
+// TestYTree_ConcurrentCycle tests that concurrent moves forming a cycle resolve the same everywhere
+func TestYTree_ConcurrentCycle(t *testing.T) {
+	docA := ygo.NewYDoc()
+	docB := ygo.NewYDoc()
+	treeA, treeB := docA.GetTree("outline"), docB.GetTree("outline")
+
+	x, err := treeA.CreateNode(ygo.TreeRoot, 0)
+	require.NoError(t, err)
+	y, err := treeA.CreateNode(ygo.TreeRoot, 1)
+	require.NoError(t, err)
+	sync(t, docA, docB)
+
+	// each moves one node below the other
+	require.NoError(t, treeA.Move(x, y, 0))
+	require.NoError(t, treeB.Move(y, x, 0))
+	syncV1(t, docA, docB)
+
+	for _, tree := range []*ygo.YTree{treeA, treeB} {
+		roots := tree.Children(ygo.TreeRoot)
+		require.Len(t, roots, 1)
+		assert.Len(t, tree.Children(roots[0]), 1)
+	}
+	assert.Equal(t, treeA.Children(ygo.TreeRoot), treeB.Children(ygo.TreeRoot))
+	assert.Equal(t, treeA.Children(x), treeB.Children(x))
+
+	// a node moved out of a subtree that's deleted concurrently survives
+	z, err := treeA.CreateNode(x, 0)
+	require.NoError(t, err)
+	sync(t, docA, docB)
+	root := treeA.Children(ygo.TreeRoot)[0]
+	require.NoError(t, treeA.Move(z, ygo.TreeRoot, 0))
+	require.NoError(t, treeB.Delete(root))
+	sync(t, docA, docB)
+
+	assert.Equal(t, []ygo.NodeID{z}, treeA.Children(ygo.TreeRoot))
+	assert.Equal(t, []ygo.NodeID{z}, treeB.Children(ygo.TreeRoot))
+}
+
+// TestYTree_KeyLength tests that nodes added at the same spot over and
+// over keep the log small and in order on every replica
+func TestYTree_KeyLength(t *testing.T) {
+	for name, index := range map[string]func(n int) int{
+		"front":  func(int) int { return 0 },
+		"end":    func(n int) int { return n },
+		"middle": func(n int) int { return min(n, 3) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc := ygo.NewYDoc()
+			tree := doc.GetTree("outline")
+
+			var want []ygo.NodeID
+			for n := 0; n < 2000; n++ {
+				node, err := tree.CreateNode(ygo.TreeRoot, int64(index(n)))
+				require.NoError(t, err)
+				want = slices.Insert(want, index(n), node)
+			}
+			assert.Equal(t, want, tree.Children(ygo.TreeRoot))
+
+			// an entry of the log takes a few dozen bytes when keys stay short
+			update, err := doc.EncodeStateAsUpdateV1(nil)
+			require.NoError(t, err)
+			assert.Less(t, len(update), 2000*64)
+
+			other := ygo.NewYDoc()
+			require.NoError(t, other.ApplyUpdateV1(update))
+			assert.Equal(t, want, other.GetTree("outline").Children(ygo.TreeRoot))
+		})
+	}
+}
+
+// TestYTree_Cache tests that reads see every change, made in the same
+// transaction or arriving from other replicas in any order
+func TestYTree_Cache(t *testing.T) {
+	docA := ygo.NewYDoc(ygo.WithClientID(1))
+	docB := ygo.NewYDoc(ygo.WithClientID(2))
+	treeA, treeB := docA.GetTree("outline"), docB.GetTree("outline")
+
+	docA.Transact(func() {
+		x, err := treeA.CreateNode(ygo.TreeRoot, 0)
+		require.NoError(t, err)
+		y, err := treeA.CreateNode(x, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []ygo.NodeID{y}, treeA.Children(x))
+		require.NoError(t, treeA.Move(y, ygo.TreeRoot, 0))
+		assert.Equal(t, []ygo.NodeID{y, x}, treeA.Children(ygo.TreeRoot))
+	})
+	sync(t, docA, docB)
+	roots := treeB.Children(ygo.TreeRoot)
+	require.Len(t, roots, 2)
+	y, x := roots[0], roots[1]
+
+	// B's move sorts before A's later one, A replays its log
+	require.NoError(t, treeB.Move(y, x, 0))
+	require.NoError(t, treeA.Move(x, ygo.TreeRoot, 0))
+	require.NoError(t, treeA.Move(y, ygo.TreeRoot, 0))
+	assert.Equal(t, []ygo.NodeID{y, x}, treeA.Children(ygo.TreeRoot))
+	sync(t, docA, docB)
+
+	assert.Equal(t, []ygo.NodeID{y, x}, treeA.Children(ygo.TreeRoot))
+	assert.Equal(t, treeA.Children(ygo.TreeRoot), treeB.Children(ygo.TreeRoot))
+	assert.Empty(t, treeB.Children(x))
+}